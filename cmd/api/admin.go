@@ -0,0 +1,266 @@
+// This contains handlers for superusers to manage user accounts
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roshanlc/soe-backend/internal/data"
+	"github.com/roshanlc/soe-backend/internal/validator"
+)
+
+// listUsersHandler returns a page of user accounts
+// Handler for GET "/v1/admin/users?role=&activated=&expired=&program_id=&semester_id=&search=&page=&page_size="
+func (app *application) listUsersHandler(c *gin.Context) {
+
+	// list of errors
+	var errBox data.ErrorBox
+
+	var filters data.UserFilters
+
+	pagination, ok := app.readPagination(c)
+	if !ok {
+		return
+	}
+	filters.Filters = pagination
+
+	filters.Role = c.Query("role")
+	filters.Search = c.Query("search")
+
+	v := validator.New()
+
+	if filters.Role != "" {
+		v.Check(validator.In(filters.Role, "student", "teacher", "superuser"), "role", "must be one of student, teacher or superuser")
+	}
+
+	// activated and expired are optional boolean filters
+	if val, exists := c.GetQuery("activated"); exists {
+		activated, err := strconv.ParseBool(val)
+		v.Check(err == nil, "activated", "must be true or false")
+		filters.Activated = &activated
+	}
+
+	if val, exists := c.GetQuery("expired"); exists {
+		expired, err := strconv.ParseBool(val)
+		v.Check(err == nil, "expired", "must be true or false")
+		filters.Expired = &expired
+	}
+
+	if val, exists := c.GetQuery("program_id"); exists {
+		programID, err := strconv.Atoi(val)
+		v.Check(err == nil && programID > 0, "program_id", "must be a positive integer")
+		filters.ProgramID = programID
+	}
+
+	if val, exists := c.GetQuery("semester_id"); exists {
+		semesterID, err := strconv.Atoi(val)
+		v.Check(err == nil && semesterID > 0, "semester_id", "must be a positive integer")
+		filters.SemesterID = semesterID
+	}
+
+	if !v.Valid() {
+		for key := range v.Errors {
+			errBox.Add(data.BadRequestResponse(v.KeyValuePair(key)))
+		}
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	users, metadata, err := app.models.Users.ListUsers(filters)
+
+	if err != nil {
+		app.logger.PrintError(err, nil)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": users, "metadata": metadata})
+}
+
+// activateUserByAdminHandler manually activates a user account
+// Handler for PUT "/v1/admin/users/:user_id/activate"
+func (app *application) activateUserByAdminHandler(c *gin.Context) {
+
+	// list of errors
+	var errBox data.ErrorBox
+
+	userID, ok := app.readIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	// Superuser accounts are managed separately
+	if !app.notASuperUser(c, userID) {
+		return
+	}
+
+	err := app.models.Users.SetActivated(userID, true)
+
+	if err != nil {
+		app.userActionErrorResponse(c, err)
+		return
+	}
+
+	// The pending activation links are of no use now
+	err = app.models.Tokens.DeleteAllForUser(data.ScopeActivation, userID)
+
+	if err != nil {
+		app.logger.PrintError(err, nil)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("User Activated", "The user account was activated successfully."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// expireUserHandler expires a user account, so that the user can no longer login
+// Handler for PUT "/v1/admin/users/:user_id/expire"
+func (app *application) expireUserHandler(c *gin.Context) {
+
+	// list of errors
+	var errBox data.ErrorBox
+
+	userID, ok := app.readIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	// Superuser accounts are managed separately
+	if !app.notASuperUser(c, userID) {
+		return
+	}
+
+	err := app.models.Users.SetExpired(userID, true)
+
+	if err != nil {
+		app.userActionErrorResponse(c, err)
+		return
+	}
+
+	// Revoke all the sessions and links of the user
+	err = app.models.Tokens.DeleteAllTokensForUser(userID)
+
+	if err != nil {
+		app.logger.PrintError(err, nil)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("User Expired", "The user account was expired successfully."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// reactivateUserHandler removes the expiry of a user account
+// Handler for PUT "/v1/admin/users/:user_id/reactivate"
+func (app *application) reactivateUserHandler(c *gin.Context) {
+
+	userID, ok := app.readIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	// Superuser accounts are managed separately
+	if !app.notASuperUser(c, userID) {
+		return
+	}
+
+	err := app.models.Users.SetExpired(userID, false)
+
+	if err != nil {
+		app.userActionErrorResponse(c, err)
+		return
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("User Reactivated", "The user account was reactivated successfully."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// deleteUserHandler deletes a student or teacher account
+// Handler for DELETE "/v1/admin/users/:user_id"
+func (app *application) deleteUserHandler(c *gin.Context) {
+
+	userID, ok := app.readIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	paths, err := app.models.Users.DeleteUser(userID)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrUserHasRecords):
+			var errBox data.ErrorBox
+			errBox.Add(data.CustomErrorResponse("Operation Not Permitted",
+				"The user has marks, exam forms or library loans. Expire the account with PUT /v1/admin/users/"+strconv.FormatInt(userID, 10)+"/expire instead."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+		default:
+			app.userActionErrorResponse(c, err)
+		}
+		return
+	}
+
+	// The attachments of the deleted issues are removed once the deletion is committed
+	removeIssueAttachments(paths)
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("User Deleted", "The user account was deleted successfully."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// notASuperUser checks that the user is not a superuser.
+// Incase the user is a superuser, it writes the error response and returns false.
+func (app *application) notASuperUser(c *gin.Context, userID int64) bool {
+
+	var errBox data.ErrorBox
+
+	role, err := app.models.Roles.GetUserRole(userID)
+
+	if err != nil {
+		switch {
+		// a user without role is not a superuser
+		case errors.Is(err, data.ErrNoRecords):
+			return true
+		default:
+			app.logger.PrintError(err, nil)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return false
+		}
+	}
+
+	if role.Role.Name == "superuser" {
+		errBox.Add(data.CustomErrorResponse("Operation Not Permitted", "Superuser accounts cannot be modified through this endpoint."))
+		app.ErrorResponse(c, http.StatusConflict, errBox)
+		return false
+	}
+
+	return true
+}
+
+// userActionErrorResponse writes the error response for failed account actions
+func (app *application) userActionErrorResponse(c *gin.Context, err error) {
+
+	var errBox data.ErrorBox
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		errBox.Add(data.ResourceNotFoundResponse("The provided user_id does not exist."))
+		app.ErrorResponse(c, http.StatusNotFound, errBox)
+	case errors.Is(err, data.ErrNotPermitted):
+		errBox.Add(data.CustomErrorResponse("Operation Not Permitted", "Superuser accounts cannot be modified through this endpoint."))
+		app.ErrorResponse(c, http.StatusConflict, errBox)
+	default:
+		app.logger.PrintError(err, nil)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+	}
+}
@@ -0,0 +1,441 @@
+// This contains handlers for the public enquiries and applications for admission,
+// and for superusers to take applicants through the admission pipeline
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roshanlc/soe-backend/internal/data"
+	"github.com/roshanlc/soe-backend/internal/validator"
+)
+
+// allowAdmissionForm limits the public forms to one submission per client within the throttle interval.
+// Incase the limit is reached, it writes the error response and returns false.
+func (app *application) allowAdmissionForm(c *gin.Context) bool {
+
+	var errBox data.ErrorBox
+
+	if !app.admissionThrottle.allow(c.ClientIP()) {
+		errBox.Add(data.CustomErrorResponse("Too Many Requests", "A form was submitted recently. Please wait a minute before trying again."))
+		app.ErrorResponse(c, http.StatusTooManyRequests, errBox)
+		return false
+	}
+
+	return true
+}
+
+// validAdmissionProgram checks that the program of a form exists
+// Incase of invalid program, it writes the error response and returns false.
+func (app *application) validAdmissionProgram(c *gin.Context, programID int) bool {
+
+	var errBox data.ErrorBox
+
+	_, err := app.models.Programs.GetProgram(programID)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.BadRequestResponse("The provided program_id does not exist."))
+			app.ErrorResponse(c, http.StatusBadRequest, errBox)
+			return false
+		default:
+			app.writeInternalError(c, err)
+			return false
+		}
+	}
+
+	return true
+}
+
+// validContactEmail checks the email of a form
+// Incase of invalid email, it writes the error response and returns false.
+func (app *application) validContactEmail(c *gin.Context, email string) bool {
+
+	var errBox data.ErrorBox
+
+	v := validator.New()
+
+	if data.ValidateEmail(v, strings.TrimSpace(email)); !v.Valid() {
+		errBox.Add(data.CustomErrorResponse("Invalid Email", "Please provide a valid email address."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return false
+	}
+
+	return true
+}
+
+// submitEnquiryHandler adds an enquiry about a program from the public
+// Handler for POST "/v1/admissions/enquiries"
+func (app *application) submitEnquiryHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	var input data.EnquiryInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	// The form is throttled only once it is valid, so that a mistake does not use up the limit
+	if !app.validContactEmail(c, input.Email) || !app.validAdmissionProgram(c, input.ProgramID) || !app.allowAdmissionForm(c) {
+		return
+	}
+
+	_, err = app.models.Admissions.InsertEnquiry(&input)
+
+	if err != nil {
+		switch err {
+		case data.ErrInvalidReference:
+			errBox.Add(data.BadRequestResponse("The provided program_id does not exist."))
+			app.ErrorResponse(c, http.StatusBadRequest, errBox)
+			return
+		default:
+			app.writeInternalError(c, err)
+			return
+		}
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Enquiry Received", "Thank you for your enquiry. We will get back to you by email or phone."))
+	c.JSON(http.StatusCreated, gin.H{"messages": msgBox})
+}
+
+// listEnquiriesHandler lists the enquiries, latest first
+// Handler for GET "/v1/admissions/enquiries?program_id=&page=&page_size="
+func (app *application) listEnquiriesHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	pagination, ok := app.readPagination(c)
+	if !ok {
+		return
+	}
+
+	filters := data.EnquiryFilters{Filters: pagination}
+	var err error
+
+	if val, exists := c.GetQuery("program_id"); exists {
+		if filters.ProgramID, err = strconv.Atoi(val); err != nil || filters.ProgramID <= 0 {
+			errBox.Add(data.BadRequestResponse("Please provide a valid program_id value."))
+			app.ErrorResponse(c, http.StatusBadRequest, errBox)
+			return
+		}
+	}
+
+	enquiries, metadata, err := app.models.Admissions.GetEnquiries(filters)
+	if err != nil {
+		app.writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"enquiries": enquiries, "metadata": metadata})
+}
+
+// submitApplicationHandler adds an application for admission into a program from the public
+// Handler for POST "/v1/admissions/applicants"
+func (app *application) submitApplicationHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	var input data.ApplicantInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	// The form is throttled only once it is valid, so that a mistake does not use up the limit
+	if !app.validContactEmail(c, input.Email) || !app.validAdmissionProgram(c, input.ProgramID) || !app.allowAdmissionForm(c) {
+		return
+	}
+
+	applicantID, err := app.models.Admissions.InsertApplicant(&input)
+
+	if err != nil {
+		switch err {
+		case data.ErrInvalidReference:
+			errBox.Add(data.BadRequestResponse("The provided program_id does not exist."))
+			app.ErrorResponse(c, http.StatusBadRequest, errBox)
+			return
+		case data.ErrDuplicateEntry:
+			errBox.Add(data.CustomErrorResponse("Conflict", "An application with the provided email is already under review for the program."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		default:
+			app.writeInternalError(c, err)
+			return
+		}
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Application Received",
+		fmt.Sprintf("Your application has been received with the reference number %d. We will contact you by email.", applicantID)))
+	c.JSON(http.StatusCreated, gin.H{"messages": msgBox})
+}
+
+// listApplicantsHandler lists the applicants filtered by program_id and status, latest first
+// Handler for GET "/v1/admissions/applicants?program_id=&status=&page=&page_size="
+func (app *application) listApplicantsHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	pagination, ok := app.readPagination(c)
+	if !ok {
+		return
+	}
+
+	filters := data.ApplicantFilters{Filters: pagination}
+	var err error
+
+	if val, exists := c.GetQuery("program_id"); exists {
+		if filters.ProgramID, err = strconv.Atoi(val); err != nil || filters.ProgramID <= 0 {
+			errBox.Add(data.BadRequestResponse("Please provide a valid program_id value."))
+		}
+	}
+
+	filters.Status = strings.ToLower(c.Query("status"))
+	if filters.Status != "" && !validator.In(filters.Status, data.ApplicantReceived, data.ApplicantShortlisted,
+		data.ApplicantAdmitted, data.ApplicantRejected) {
+		errBox.Add(data.BadRequestResponse("status must be one of received, shortlisted, admitted or rejected."))
+	}
+
+	if len(errBox) != 0 {
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	applicants, metadata, err := app.models.Admissions.GetApplicants(filters)
+	if err != nil {
+		app.writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"applicants": applicants, "metadata": metadata})
+}
+
+// showApplicantHandler returns an applicant
+// Handler for GET "/v1/admissions/applicants/:applicant_id"
+func (app *application) showApplicantHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	applicantID, ok := app.readIDParam(c, "applicant_id")
+	if !ok {
+		return
+	}
+
+	applicant, err := app.models.Admissions.GetApplicant(applicantID)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The requested applicant does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			app.writeInternalError(c, err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"applicant": applicant})
+}
+
+// setApplicantStatusHandler moves an applicant to the received, shortlisted or rejected status
+// Handler for PUT "/v1/admissions/applicants/:applicant_id/status"
+func (app *application) setApplicantStatusHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	applicantID, ok := app.readIDParam(c, "applicant_id")
+	if !ok {
+		return
+	}
+
+	var input data.ApplicantStatusInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	err = app.models.Admissions.SetStatus(applicantID, &input)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The requested applicant does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		case data.ErrNotPermitted:
+			errBox.Add(data.CustomErrorResponse("Conflict", "The applicant has already been admitted."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		case data.ErrDuplicateEntry:
+			errBox.Add(data.CustomErrorResponse("Conflict", "Another application with the same email is already under review for the program."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		default:
+			app.writeInternalError(c, err)
+			return
+		}
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Status Updated", "The applicant has been moved to "+input.Status+"."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// admitApplicantHandler admits a shortlisted applicant by registering a student account
+// the same way as registerStudentHandler, and mails the activation link to the applicant
+// Handler for POST "/v1/admissions/applicants/:applicant_id/admit"
+func (app *application) admitApplicantHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	applicantID, ok := app.readIDParam(c, "applicant_id")
+	if !ok {
+		return
+	}
+
+	var input data.AdmitInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	enrolledAt := time.Now()
+
+	if input.EnrolledAt != "" {
+		if enrolledAt, err = time.Parse("2006-01-02", input.EnrolledAt); err != nil {
+			errBox.Add(data.BadRequestResponse("Please provide the enrolled_at in YYYY-MM-DD format."))
+			app.ErrorResponse(c, http.StatusBadRequest, errBox)
+			return
+		}
+	}
+
+	applicant, err := app.models.Admissions.GetApplicant(applicantID)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The requested applicant does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			app.writeInternalError(c, err)
+			return
+		}
+	}
+
+	if applicant.Status != data.ApplicantShortlisted {
+		errBox.Add(data.CustomErrorResponse("Conflict", "Only shortlisted applicants can be admitted."))
+		app.ErrorResponse(c, http.StatusConflict, errBox)
+		return
+	}
+
+	// The student sets a password with the password reset after activation
+	password, err := randomPassword()
+	if err != nil {
+		app.writeInternalError(c, err)
+		return
+	}
+
+	pw := data.Password{}
+	pw.Set(password)
+
+	student := data.StudentRegistration{
+		Email:      applicant.Email,
+		Name:       applicant.Name,
+		Password:   pw.Hash(),
+		SymbolNo:   input.SymbolNo,
+		PURegdNo:   strings.TrimSpace(input.PURegdNo),
+		ContactNo:  applicant.ContactNo,
+		ProgramID:  applicant.ProgramID,
+		EnrolledAt: enrolledAt,
+		Semester:   input.Semester,
+	}
+
+	userID, err := app.models.Admissions.Admit(applicantID, &student)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			errBox.Add(data.ResourceNotFoundResponse("The requested applicant does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		case errors.Is(err, data.ErrNotPermitted):
+			errBox.Add(data.CustomErrorResponse("Conflict", "Only shortlisted applicants can be admitted."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		case errors.Is(err, data.ErrDuplicateEntry):
+			errBox.Add(data.CustomErrorResponse("Conflict", "The provided symbol_no or pu_regd_no already belongs to a student."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		case errors.Is(err, data.ErrDuplicateEmail):
+			errBox.Add(data.CustomErrorResponse("Duplicate Email", "The email of the applicant is already registered."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		default:
+			app.writeInternalError(c, err)
+			return
+		}
+	}
+
+	token, err := app.models.Tokens.GenAndInsertActivationToken(student.Email, 24*time.Hour)
+	if err != nil {
+		app.writeInternalError(c, err)
+		return
+	}
+
+	link := app.config.Domain + "/v1/users/activate?token=" + token.Hash
+
+	mailDetails := MailingContent{from: app.config.Mail.Sender, to: student.Email,
+		subject: "Admission Confirmed: Activate Your Student Portal Account",
+		content: generateAdmissionEmail(applicant.Program, link),
+	}
+
+	go app.mailHandler.SendMail(&mailDetails)
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Applicant Admitted", "The student account has been created and the activation link has been mailed to the applicant."))
+	c.JSON(http.StatusCreated, gin.H{"messages": msgBox, "user_id": userID})
+}
+
+// randomPassword returns a random password for accounts created on behalf of users
+func randomPassword() (string, error) {
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// Generate admission email content
+func generateAdmissionEmail(program, link string) string {
+
+	part1 := fmt.Sprintf("Congratulations! You have been admitted to %s. Your Online Student Portal account has been created, please click on following link to activate it.", program)
+	part2 := "The activation link is valid for 24 hours. After activating the account, please use the forgot password option to set your password."
+	part3 := "Much love from OSP team."
+
+	return fmt.Sprintf("%s\n%v\n\n%s\n\n%s", part1, link, part2, part3)
+}
@@ -0,0 +1,259 @@
+// This contains handlers for superusers to manage the assignments of teachers to courses
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roshanlc/soe-backend/internal/data"
+	"github.com/roshanlc/soe-backend/internal/validator"
+)
+
+// readExpiryDate reads an optional yyyy-mm-dd expiry date, which must be a future date.
+// Incase of invalid value, it writes the error response and returns false.
+func (app *application) readExpiryDate(c *gin.Context, value string) (*time.Time, bool) {
+
+	var errBox data.ErrorBox
+
+	if value == "" {
+		return nil, true
+	}
+
+	expiresAt, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse("Please provide the expires_at in YYYY-MM-DD format."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return nil, false
+	}
+
+	if !expiresAt.After(time.Now()) {
+		errBox.Add(data.BadRequestResponse("The expires_at must be a future date."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return nil, false
+	}
+
+	return &expiresAt, true
+}
+
+// assignTeacherHandler assigns a teacher to a course
+// Handler for POST "/v1/admin/teacher-courses"
+func (app *application) assignTeacherHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	var input data.AssignmentInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	expiresAt, ok := app.readExpiryDate(c, input.ExpiresAt)
+	if !ok {
+		return
+	}
+
+	if expiresAt == nil {
+		defaultExpiry := time.Now().AddDate(0, data.AssignmentMonths, 0)
+		expiresAt = &defaultExpiry
+	}
+
+	err = app.models.Assignments.Assign(&input, *expiresAt)
+
+	if err != nil {
+		switch err {
+		case data.ErrInvalidTeacher:
+			errBox.Add(data.ResourceNotFoundResponse("The provided user_id is not a teacher."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		case data.ErrInvalidCourse:
+			errBox.Add(data.BadRequestResponse("The course is not offered in the provided program and semester."))
+			app.ErrorResponse(c, http.StatusBadRequest, errBox)
+			return
+		case data.ErrDuplicateEntry:
+			errBox.Add(data.CustomErrorResponse("Conflict", "The teacher is already assigned to the course."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Teacher Assigned",
+		fmt.Sprintf("The teacher has been assigned to the course till %s.", expiresAt.Format("2006-01-02"))))
+	c.JSON(http.StatusCreated, gin.H{"messages": msgBox})
+}
+
+// listAssignmentsHandler lists the assignments filtered by user_id, course_id, program_id, semester_id and status
+// Handler for GET "/v1/admin/teacher-courses"
+func (app *application) listAssignmentsHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	var filters data.AssignmentFilters
+	var err error
+
+	if val, exists := c.GetQuery("user_id"); exists {
+		if filters.UserID, err = strconv.ParseInt(val, 10, 64); err != nil || filters.UserID <= 0 {
+			errBox.Add(data.BadRequestResponse("Please provide a valid user_id value."))
+		}
+	}
+
+	if val, exists := c.GetQuery("course_id"); exists {
+		if filters.CourseID, err = strconv.Atoi(val); err != nil || filters.CourseID <= 0 {
+			errBox.Add(data.BadRequestResponse("Please provide a valid course_id value."))
+		}
+	}
+
+	if val, exists := c.GetQuery("program_id"); exists {
+		if filters.ProgramID, err = strconv.Atoi(val); err != nil || filters.ProgramID <= 0 {
+			errBox.Add(data.BadRequestResponse("Please provide a valid program_id value."))
+		}
+	}
+
+	if val, exists := c.GetQuery("semester_id"); exists {
+		if filters.SemesterID, err = strconv.Atoi(val); err != nil || filters.SemesterID <= 0 {
+			errBox.Add(data.BadRequestResponse("Please provide a valid semester_id value."))
+		}
+	}
+
+	filters.Status = strings.ToLower(c.Query("status"))
+	if filters.Status != "" && !validator.In(filters.Status, "current", "expired") {
+		errBox.Add(data.BadRequestResponse("status must be one of current or expired."))
+	}
+
+	if len(errBox) != 0 {
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	assignments, err := app.models.Assignments.GetAssignments(filters)
+
+	if err != nil && err != data.ErrNoRecords {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"assignments": assignments})
+}
+
+// renewAssignmentHandler extends the assignment of a teacher to a course
+// Handler for PUT "/v1/admin/teacher-courses/:user_id/:course_id/renew"
+func (app *application) renewAssignmentHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	userID, ok := app.readIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	courseID, ok := app.readIDParam(c, "course_id")
+	if !ok {
+		return
+	}
+
+	// The body is optional
+	var input data.AssignmentRenewal
+
+	if c.Request.ContentLength != 0 {
+		err := c.ShouldBindJSON(&input)
+		if err != nil {
+			errBox.Add(data.BadRequestResponse(err.Error()))
+			app.ErrorResponse(c, http.StatusBadRequest, errBox)
+			return
+		}
+	}
+
+	expiresAt, ok := app.readExpiryDate(c, input.ExpiresAt)
+	if !ok {
+		return
+	}
+
+	err := app.models.Assignments.Renew(userID, int(courseID), expiresAt)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The teacher has never been assigned to the course."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Assignment Renewed", "The assignment of the teacher to the course has been renewed."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// endAssignmentHandler ends the assignment of a teacher to a course
+// Handler for DELETE "/v1/admin/teacher-courses/:user_id/:course_id"
+func (app *application) endAssignmentHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	userID, ok := app.readIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	courseID, ok := app.readIDParam(c, "course_id")
+	if !ok {
+		return
+	}
+
+	err := app.models.Assignments.End(userID, int(courseID))
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The teacher is not currently assigned to the course."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Assignment Ended", "The assignment of the teacher to the course has ended."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// Generate assignment expiry warning email content
+func generateExpiryWarningEmail(assignments *[]data.Assignment) string {
+
+	part1 := fmt.Sprintf("The following assignments of teachers to courses will expire within %d days.", data.ExpiryWarningDays)
+
+	var list strings.Builder
+	for _, a := range *assignments {
+		fmt.Fprintf(&list, "- %s: %s (%s), %s semester %d, expires on %s\n", a.Teacher, a.CourseTitle,
+			a.CourseCode, a.Program, a.SemesterID, a.ExpiresAt.Format("January 2, 2006"))
+	}
+
+	part2 := "Please renew the assignments that should continue. Expired assignments are no longer able to take attendance, upload marks or edit lesson plans."
+	part3 := "Much love from OSP team."
+
+	return fmt.Sprintf("%s\n\n%s\n%s\n\n%s", part1, list.String(), part2, part3)
+}
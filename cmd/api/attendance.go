@@ -0,0 +1,145 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roshanlc/soe-backend/internal/data"
+)
+
+// markAttendanceHandler records the attendance of a class
+// Only a teacher currently teaching the course can mark the attendance
+// Handler for POST "/v1/attendance"
+func (app *application) markAttendanceHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	token := app.currentToken(c)
+	if token == nil {
+		return
+	}
+
+	var input data.AttendanceInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	heldOn, err := time.Parse("2006-01-02", input.Date)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse("Please provide the date in YYYY-MM-DD format."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	if heldOn.After(time.Now()) {
+		errBox.Add(data.BadRequestResponse("Attendance cannot be marked for a future date."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	// The date must fall on the day of the slot
+	input.Day = strings.ToUpper(input.Day)
+	if strings.ToUpper(heldOn.Weekday().String()) != input.Day {
+		errBox.Add(data.BadRequestResponse("The provided date does not fall on the provided day."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	// A student can be marked only once in a session
+	seen := make(map[int64]bool)
+	for _, record := range input.Records {
+		if seen[record.StudentID] {
+			errBox.Add(data.BadRequestResponse("A student_id has been repeated in the records."))
+			app.ErrorResponse(c, http.StatusBadRequest, errBox)
+			return
+		}
+		seen[record.StudentID] = true
+	}
+
+	sessionID, err := app.models.Attendance.MarkAttendance(token.UserID, &input, heldOn)
+
+	if err != nil {
+		switch err {
+		case data.ErrNotPermitted:
+			errBox.Add(data.AuthorizationErrorResponse("You are not teaching the provided course."))
+			app.ErrorResponse(c, http.StatusForbidden, errBox)
+			return
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The provided slot does not exist in the schedule."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		case data.ErrInvalidStudent:
+			errBox.Add(data.BadRequestResponse("Every student_id must be a student of the provided program_id and semester_id."))
+			app.ErrorResponse(c, http.StatusBadRequest, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Attendance Marked", "The attendance has been recorded successfully."))
+	c.JSON(http.StatusCreated, gin.H{"session_id": sessionID, "messages": msgBox})
+}
+
+// This returns the attendance of a student in the courses of the current semester
+// Handler For GET "/v1/students/:user_id/attendance"
+func (app *application) showStudentAttendanceHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	// Check if token matches with provided user ID
+	val, token := app.DoesTokenMatchesUserID(c)
+
+	// If user id does not match with token
+	if !val {
+		return
+	}
+
+	attendance, err := app.models.Attendance.GetStudentAttendance(token.UserID)
+
+	if err != nil && err != data.ErrNoRecords {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"attendance": attendance})
+}
+
+// This returns the attendance of students in the courses taught by a teacher
+// Handler For GET "/v1/teachers/:user_id/attendance"
+func (app *application) showTeacherAttendanceHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	// Check if token matches with provided user ID
+	val, token := app.DoesTokenMatchesUserID(c)
+
+	// If user id does not match with token
+	if !val {
+		return
+	}
+
+	attendance, err := app.models.Attendance.GetTeacherAttendance(token.UserID)
+
+	if err != nil && err != data.ErrNoRecords {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"attendance": attendance})
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roshanlc/soe-backend/internal/data"
+)
+
+// listBooksHandler returns a page of books matching the title and author
+// Handler for GET "/v1/books?title=&author=&page=&page_size="
+func (app *application) listBooksHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	pagination, ok := app.readPagination(c)
+	if !ok {
+		return
+	}
+
+	filters := data.BookFilters{
+		Title:   c.Query("title"),
+		Author:  c.Query("author"),
+		Filters: pagination,
+	}
+
+	books, metadata, err := app.models.Books.Search(filters)
+
+	if err != nil {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"books": books, "metadata": metadata})
+}
+
+// showBookHandler returns a book
+// Handler for GET "/v1/books/:book_id"
+func (app *application) showBookHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	bookID, ok := app.readIDParam(c, "book_id")
+	if !ok {
+		return
+	}
+
+	book, err := app.models.Books.Get(bookID)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The requested book does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"book": book})
+}
+
+// createBookHandler adds a new book
+// Handler for POST "/v1/books"
+func (app *application) createBookHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	var input data.BookInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	book, err := app.models.Books.Insert(&input)
+
+	if err != nil {
+		switch err {
+		case data.ErrDuplicateEntry:
+			errBox.Add(data.CustomErrorResponse("Conflict", "A book with the provided title already exists."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"book": book})
+}
+
+// updateBookHandler replaces the details of a book
+// Handler for PUT "/v1/books/:book_id"
+func (app *application) updateBookHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	bookID, ok := app.readIDParam(c, "book_id")
+	if !ok {
+		return
+	}
+
+	var input data.BookInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	book, err := app.models.Books.Update(bookID, &input)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The requested book does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		case data.ErrDuplicateEntry:
+			errBox.Add(data.CustomErrorResponse("Conflict", "A book with the provided title already exists."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"book": book})
+}
+
+// deleteBookHandler deletes a book
+// Handler for DELETE "/v1/books/:book_id"
+func (app *application) deleteBookHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	bookID, ok := app.readIDParam(c, "book_id")
+	if !ok {
+		return
+	}
+
+	err := app.models.Books.Delete(bookID)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The requested book does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		case data.ErrNotPermitted:
+			errBox.Add(data.CustomErrorResponse("Conflict", "The book has copies in the library and cannot be deleted."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Book Deleted", "The book has been deleted."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// attachCourseBookHandler assigns a book to a course as a text or reference book
+// Handler for POST "/v1/courses/:course_code/books"
+func (app *application) attachCourseBookHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	var input data.CourseBookInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	err = app.models.Books.AttachToCourse(c.Param("course_code"), &input)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The requested course does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		case data.ErrInvalidBook:
+			errBox.Add(data.ResourceNotFoundResponse("The provided book does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	var msgBox data.MessageBox
+	if *input.TextBook {
+		msgBox.Add(data.MessageResponse("Book Assigned", "The book has been assigned as a text book of the course."))
+	} else {
+		msgBox.Add(data.MessageResponse("Book Assigned", "The book has been assigned as a reference book of the course."))
+	}
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// detachCourseBookHandler removes a book from a course
+// Handler for DELETE "/v1/courses/:course_code/books/:book_id"
+func (app *application) detachCourseBookHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	bookID, ok := app.readIDParam(c, "book_id")
+	if !ok {
+		return
+	}
+
+	err := app.models.Books.DetachFromCourse(c.Param("course_code"), bookID)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The requested course does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		case data.ErrInvalidBook:
+			errBox.Add(data.ResourceNotFoundResponse("The book is not assigned to the course."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Book Removed", "The book has been removed from the course."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
@@ -0,0 +1,336 @@
+// This contains the export of the weekly schedules of students and teachers as iCalendar (.ics) feeds
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roshanlc/soe-backend/internal/data"
+)
+
+// Offset from sunday and the iCalendar code of the days of the week
+var calendarDays = map[string]struct {
+	offset int
+	code   string
+}{
+	"SUNDAY":    {0, "SU"},
+	"MONDAY":    {1, "MO"},
+	"TUESDAY":   {2, "TU"},
+	"WEDNESDAY": {3, "WE"},
+	"THURSDAY":  {4, "TH"},
+	"FRIDAY":    {5, "FR"},
+	"SATURDAY":  {6, "SA"},
+}
+
+// A weekly class of a schedule, rendered as a recurring event
+type calendarClass struct {
+	day         string
+	interval    string // such as 10:15-11:05
+	courseID    int
+	summary     string
+	description string
+}
+
+// studentCalendarClasses returns the classes of the schedule of a student
+func studentCalendarClasses(schedule *data.StudentSchedule) []calendarClass {
+
+	var classes []calendarClass
+
+	for _, day := range schedule.Days {
+		for _, interval := range day.Intervals {
+			description := "Teacher: " + interval.TeacherName
+			if interval.Description != "" {
+				description += "\n" + interval.Description
+			}
+
+			classes = append(classes, calendarClass{
+				day:         day.Day,
+				interval:    interval.Interval,
+				courseID:    interval.CourseID,
+				summary:     fmt.Sprintf("%s (%s)", interval.CourseTitle, interval.CourseCode),
+				description: description,
+			})
+		}
+	}
+
+	return classes
+}
+
+// teacherCalendarClasses returns the classes of the schedule of a teacher
+func teacherCalendarClasses(schedule *data.TeacherSchedule) []calendarClass {
+
+	var classes []calendarClass
+
+	for _, day := range schedule.Days {
+		for _, interval := range day.Intervals {
+			classes = append(classes, calendarClass{
+				day:         day.Day,
+				interval:    interval.Interval,
+				courseID:    interval.CourseID,
+				summary:     fmt.Sprintf("%s (%s)", interval.CourseTitle, interval.CourseCode),
+				description: interval.Description,
+			})
+		}
+	}
+
+	return classes
+}
+
+// escapeCalendarText escapes a TEXT value of an iCalendar
+func escapeCalendarText(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(text)
+}
+
+// writeCalendarLine writes a content line folded at 75 octets, as required by RFC 5545
+func writeCalendarLine(b *strings.Builder, line string) {
+
+	limit := 75
+
+	for len(line) > limit {
+		// do not split a multi byte character
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+
+		// the leading space of the continuation line counts towards its length
+		limit = 74
+	}
+
+	b.WriteString(line + "\r\n")
+}
+
+// isRuneStart reports whether the byte starts a utf-8 encoded character
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// classTimes returns the start and end of a class in the week starting at the sunday
+func classTimes(sunday time.Time, day, interval string) (time.Time, time.Time, bool) {
+
+	weekDay, exists := calendarDays[day]
+	if !exists {
+		return time.Time{}, time.Time{}, false
+	}
+
+	parts := strings.Split(interval, "-")
+	if len(parts) != 2 {
+		return time.Time{}, time.Time{}, false
+	}
+
+	start, err1 := time.Parse("15:04", strings.TrimSpace(parts[0]))
+	end, err2 := time.Parse("15:04", strings.TrimSpace(parts[1]))
+	if err1 != nil || err2 != nil {
+		return time.Time{}, time.Time{}, false
+	}
+
+	date := sunday.AddDate(0, 0, weekDay.offset)
+
+	return date.Add(time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute),
+		date.Add(time.Duration(end.Hour())*time.Hour + time.Duration(end.Minute())*time.Minute), true
+}
+
+// renderCalendar renders the classes as weekly recurring events of an iCalendar
+// Times are floating (without a timezone), so they are shown as the local time of the college.
+func (app *application) renderCalendar(name string, userID int64, classes []calendarClass) []byte {
+
+	now := time.Now()
+	sunday := time.Date(now.Year(), now.Month(), now.Day()-int(now.Weekday()), 0, 0, 0, 0, time.UTC)
+
+	host := app.config.Domain
+	if u, err := url.Parse(app.config.Domain); err == nil && u.Host != "" {
+		host = u.Host
+	}
+
+	var b strings.Builder
+
+	writeCalendarLine(&b, "BEGIN:VCALENDAR")
+	writeCalendarLine(&b, "VERSION:2.0")
+	writeCalendarLine(&b, "PRODID:-//OSP//Student Portal Schedule//EN")
+	writeCalendarLine(&b, "CALSCALE:GREGORIAN")
+	writeCalendarLine(&b, "METHOD:PUBLISH")
+	writeCalendarLine(&b, "X-WR-CALNAME:"+escapeCalendarText(name))
+
+	for _, class := range classes {
+		start, end, ok := classTimes(sunday, class.day, class.interval)
+		if !ok {
+			continue
+		}
+
+		writeCalendarLine(&b, "BEGIN:VEVENT")
+		writeCalendarLine(&b, fmt.Sprintf("UID:schedule-%d-%s-%s-%d@%s", userID, class.day,
+			strings.ReplaceAll(class.interval, ":", ""), class.courseID, host))
+		writeCalendarLine(&b, "DTSTAMP:"+now.UTC().Format("20060102T150405Z"))
+		writeCalendarLine(&b, "DTSTART:"+start.Format("20060102T150405"))
+		writeCalendarLine(&b, "DTEND:"+end.Format("20060102T150405"))
+		writeCalendarLine(&b, "RRULE:FREQ=WEEKLY;BYDAY="+calendarDays[class.day].code)
+		writeCalendarLine(&b, "SUMMARY:"+escapeCalendarText(class.summary))
+		if class.description != "" {
+			writeCalendarLine(&b, "DESCRIPTION:"+escapeCalendarText(class.description))
+		}
+		writeCalendarLine(&b, "END:VEVENT")
+	}
+
+	writeCalendarLine(&b, "END:VCALENDAR")
+
+	return []byte(b.String())
+}
+
+// calendarFeedURL returns the secret url at which calendar clients can subscribe to the schedule of a user
+// The random feed key of the user is signed in, so that regenerating the key revokes the url
+func (app *application) calendarFeedURL(userID int64, key string) string {
+	id := strconv.FormatInt(userID, 10)
+	return fmt.Sprintf("%s/v1/calendars/%s/schedule.ics?signature=%s", app.config.Domain, id, app.sign("schedule-feed", id, key))
+}
+
+// writeCalendar writes the schedule of a student or a teacher as an iCalendar
+func (app *application) writeCalendar(c *gin.Context, role string, userID int64) {
+
+	var errBox data.ErrorBox
+	var classes []calendarClass
+	var err error
+
+	switch role {
+	case "student":
+		var schedule *data.StudentSchedule
+		schedule, err = app.models.Schedule.GetStudentSchedule(int(userID))
+		if err == nil {
+			classes = studentCalendarClasses(schedule)
+		}
+	case "teacher":
+		var schedule *data.TeacherSchedule
+		schedule, err = app.models.Schedule.GetTeacherSchedule(int(userID))
+		if err == nil {
+			classes = teacherCalendarClasses(schedule)
+		}
+	default:
+		errBox.Add(data.ResourceNotFoundResponse("Only students and teachers have schedules."))
+		app.ErrorResponse(c, http.StatusNotFound, errBox)
+		return
+	}
+
+	// An empty calendar is returned if there are no classes
+	if err != nil && err != data.ErrNoRecords {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	c.Header("Content-Disposition", `inline; filename="schedule.ics"`)
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", app.renderCalendar("Class Schedule", userID, classes))
+}
+
+// showScheduleCalendarHandler returns the schedule of the student or teacher as an iCalendar
+// Handler for GET "/v1/students/:user_id/schedule.ics" and GET "/v1/teachers/:user_id/schedule.ics"
+func (app *application) showScheduleCalendarHandler(c *gin.Context) {
+
+	val, token := app.DoesTokenMatchesUserID(c)
+	if !val {
+		return
+	}
+
+	role, ok := app.currentRole(c, token)
+	if !ok {
+		return
+	}
+
+	app.writeCalendar(c, role, token.UserID)
+}
+
+// showCalendarSubscriptionHandler returns the secret url to subscribe to the schedule from calendar clients
+// Handler for GET "/v1/students/:user_id/schedule/subscription" and GET "/v1/teachers/:user_id/schedule/subscription"
+func (app *application) showCalendarSubscriptionHandler(c *gin.Context) {
+
+	val, token := app.DoesTokenMatchesUserID(c)
+	if !val {
+		return
+	}
+
+	key, err := app.models.Schedule.GetFeedKey(token.UserID)
+	if err != nil {
+		app.writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"subscription": gin.H{
+		"url":  app.calendarFeedURL(token.UserID, key),
+		"note": "Keep this url secret, anyone with it can view your schedule. Regenerate it if it has been leaked.",
+	}})
+}
+
+// regenerateCalendarSubscriptionHandler replaces the secret subscription url, the old url stops working
+// Handler for POST "/v1/students/:user_id/schedule/subscription" and POST "/v1/teachers/:user_id/schedule/subscription"
+func (app *application) regenerateCalendarSubscriptionHandler(c *gin.Context) {
+
+	val, token := app.DoesTokenMatchesUserID(c)
+	if !val {
+		return
+	}
+
+	key, err := app.models.Schedule.RegenerateFeedKey(token.UserID)
+	if err != nil {
+		app.writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"subscription": gin.H{
+		"url":  app.calendarFeedURL(token.UserID, key),
+		"note": "The previous url no longer works, please subscribe again with this url.",
+	}})
+}
+
+// calendarFeedHandler returns the schedule of a user as an iCalendar for calendar clients,
+// which cannot send the Authorization header. The signature of the url authenticates the request.
+// Feeds of expired or unactivated accounts are not served.
+// Handler for GET "/v1/calendars/:user_id/schedule.ics?signature="
+func (app *application) calendarFeedHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	userID, ok := app.readIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	feed, err := app.models.Schedule.GetFeed(userID)
+
+	if err != nil && err != data.ErrRecordNotFound {
+		app.writeInternalError(c, err)
+		return
+	}
+
+	if err == data.ErrRecordNotFound || !feed.Activated || feed.Expired ||
+		!app.validSignature(c.Query("signature"), "schedule-feed", strconv.FormatInt(userID, 10), feed.Key) {
+		errBox.Add(data.ResourceNotFoundResponse("The requested calendar does not exist."))
+		app.ErrorResponse(c, http.StatusNotFound, errBox)
+		return
+	}
+
+	role, err := app.models.Roles.GetUserRole(userID)
+
+	if err != nil {
+		switch err {
+		case data.ErrNoRecords:
+			errBox.Add(data.ResourceNotFoundResponse("The requested calendar does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	app.writeCalendar(c, role.Role.Name, userID)
+}
@@ -0,0 +1,446 @@
+// This contains handlers for superusers to manage the academic catalogue
+// (faculties, departments, programs, courses and the courses offered by programs)
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roshanlc/soe-backend/internal/data"
+)
+
+// Messages reported for the errors of catalogue changes
+type catalogueMessages struct {
+	notFound  string // the entry does not exist
+	duplicate string // a unique value is repeated
+	inUse     string // the entry is still referred by other records
+	reference string // the entry refers to records that do not exist
+}
+
+// catalogueErrorResponse writes the error response of catalogue changes
+func (app *application) catalogueErrorResponse(c *gin.Context, err error, msgs catalogueMessages) {
+
+	var errBox data.ErrorBox
+
+	switch err {
+	case data.ErrRecordNotFound:
+		errBox.Add(data.ResourceNotFoundResponse(msgs.notFound))
+		app.ErrorResponse(c, http.StatusNotFound, errBox)
+	case data.ErrDuplicateEntry:
+		errBox.Add(data.CustomErrorResponse("Conflict", msgs.duplicate))
+		app.ErrorResponse(c, http.StatusConflict, errBox)
+	case data.ErrNotPermitted:
+		errBox.Add(data.CustomErrorResponse("Conflict", msgs.inUse))
+		app.ErrorResponse(c, http.StatusConflict, errBox)
+	case data.ErrInvalidReference:
+		errBox.Add(data.CustomErrorResponse("Conflict", msgs.reference))
+		app.ErrorResponse(c, http.StatusConflict, errBox)
+	default:
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+	}
+}
+
+var (
+	facultyMessages = catalogueMessages{
+		notFound:  "The requested faculty does not exist.",
+		duplicate: "A faculty with the provided name already exists.",
+		inUse:     "The faculty still has departments and cannot be deleted.",
+	}
+	departmentMessages = catalogueMessages{
+		notFound:  "The requested department does not exist.",
+		duplicate: "A department with the provided name already exists.",
+		inUse:     "The department still has programs and cannot be deleted.",
+		reference: "The provided faculty_id does not exist.",
+	}
+	programMessages = catalogueMessages{
+		notFound:  "The requested program does not exist.",
+		duplicate: "A program with the provided name already exists.",
+		inUse:     "The program still has courses, running semesters or students and cannot be deleted.",
+		reference: "The provided department_id or level_id does not exist.",
+	}
+	courseMessages = catalogueMessages{
+		notFound:  "The requested course does not exist.",
+		duplicate: "A course with the provided course_code or title already exists.",
+		inUse:     "The course is offered by a program, taught by teachers or has records of students and cannot be deleted.",
+	}
+	programCourseMessages = catalogueMessages{
+		notFound:  "The course is not offered by the program.",
+		duplicate: "The course is already offered by a program.",
+		reference: "The provided program_id, course_id or semester_id does not exist.",
+	}
+)
+
+// writeInternalError writes the response of an unexpected error
+func (app *application) writeInternalError(c *gin.Context, err error) {
+
+	var errBox data.ErrorBox
+
+	log.Println(err)
+	errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+	app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+}
+
+// bindCatalogueInput binds the json body of a catalogue change.
+// Incase of invalid body, it writes the error response and returns false.
+func (app *application) bindCatalogueInput(c *gin.Context, input interface{}) bool {
+
+	var errBox data.ErrorBox
+
+	err := c.ShouldBindJSON(input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return false
+	}
+
+	return true
+}
+
+// createFacultyHandler adds a new faculty
+// Handler for POST "/v1/faculties"
+func (app *application) createFacultyHandler(c *gin.Context) {
+
+	var input data.FacultyInput
+
+	if !app.bindCatalogueInput(c, &input) {
+		return
+	}
+
+	facultyID, err := app.models.Programs.InsertFaculty(&input)
+	if err != nil {
+		app.catalogueErrorResponse(c, err, facultyMessages)
+		return
+	}
+
+	faculty, err := app.models.Programs.GetAFaculty(facultyID)
+	if err != nil {
+		app.writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"faculty": faculty})
+}
+
+// updateFacultyHandler replaces the details of a faculty
+// Handler for PUT "/v1/faculties/:faculty_id"
+func (app *application) updateFacultyHandler(c *gin.Context) {
+
+	facultyID, ok := app.readIDParam(c, "faculty_id")
+	if !ok {
+		return
+	}
+
+	var input data.FacultyInput
+
+	if !app.bindCatalogueInput(c, &input) {
+		return
+	}
+
+	err := app.models.Programs.UpdateFaculty(int(facultyID), &input)
+	if err != nil {
+		app.catalogueErrorResponse(c, err, facultyMessages)
+		return
+	}
+
+	faculty, err := app.models.Programs.GetAFaculty(int(facultyID))
+	if err != nil {
+		app.writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"faculty": faculty})
+}
+
+// deleteFacultyHandler deletes a faculty
+// Handler for DELETE "/v1/faculties/:faculty_id"
+func (app *application) deleteFacultyHandler(c *gin.Context) {
+
+	facultyID, ok := app.readIDParam(c, "faculty_id")
+	if !ok {
+		return
+	}
+
+	err := app.models.Programs.DeleteFaculty(int(facultyID))
+	if err != nil {
+		app.catalogueErrorResponse(c, err, facultyMessages)
+		return
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Faculty Deleted", "The faculty has been deleted."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// createDepartmentHandler adds a new department
+// Handler for POST "/v1/departments"
+func (app *application) createDepartmentHandler(c *gin.Context) {
+
+	var input data.DepartmentInput
+
+	if !app.bindCatalogueInput(c, &input) {
+		return
+	}
+
+	departmentID, err := app.models.Programs.InsertDepartment(&input)
+	if err != nil {
+		app.catalogueErrorResponse(c, err, departmentMessages)
+		return
+	}
+
+	department, err := app.models.Programs.GetDepartment(departmentID)
+	if err != nil {
+		app.writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"department": department})
+}
+
+// updateDepartmentHandler replaces the details of a department
+// Handler for PUT "/v1/departments/:department_id"
+func (app *application) updateDepartmentHandler(c *gin.Context) {
+
+	departmentID, ok := app.readIDParam(c, "department_id")
+	if !ok {
+		return
+	}
+
+	var input data.DepartmentInput
+
+	if !app.bindCatalogueInput(c, &input) {
+		return
+	}
+
+	err := app.models.Programs.UpdateDepartment(int(departmentID), &input)
+	if err != nil {
+		app.catalogueErrorResponse(c, err, departmentMessages)
+		return
+	}
+
+	department, err := app.models.Programs.GetDepartment(int(departmentID))
+	if err != nil {
+		app.writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"department": department})
+}
+
+// deleteDepartmentHandler deletes a department
+// Handler for DELETE "/v1/departments/:department_id"
+func (app *application) deleteDepartmentHandler(c *gin.Context) {
+
+	departmentID, ok := app.readIDParam(c, "department_id")
+	if !ok {
+		return
+	}
+
+	err := app.models.Programs.DeleteDepartment(int(departmentID))
+	if err != nil {
+		app.catalogueErrorResponse(c, err, departmentMessages)
+		return
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Department Deleted", "The department has been deleted."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// createProgramHandler adds a new program
+// Handler for POST "/v1/programs"
+func (app *application) createProgramHandler(c *gin.Context) {
+
+	var input data.ProgramInput
+
+	if !app.bindCatalogueInput(c, &input) {
+		return
+	}
+
+	programID, err := app.models.Programs.InsertProgram(&input)
+	if err != nil {
+		app.catalogueErrorResponse(c, err, programMessages)
+		return
+	}
+
+	program, err := app.models.Programs.GetProgram(programID)
+	if err != nil {
+		app.writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"program": program})
+}
+
+// updateProgramHandler replaces the details of a program
+// Handler for PUT "/v1/programs/:program_id"
+func (app *application) updateProgramHandler(c *gin.Context) {
+
+	programID, ok := app.readIDParam(c, "program_id")
+	if !ok {
+		return
+	}
+
+	var input data.ProgramInput
+
+	if !app.bindCatalogueInput(c, &input) {
+		return
+	}
+
+	err := app.models.Programs.UpdateProgram(int(programID), &input)
+	if err != nil {
+		app.catalogueErrorResponse(c, err, programMessages)
+		return
+	}
+
+	program, err := app.models.Programs.GetProgram(int(programID))
+	if err != nil {
+		app.writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"program": program})
+}
+
+// deleteProgramHandler deletes a program
+// Handler for DELETE "/v1/programs/:program_id"
+func (app *application) deleteProgramHandler(c *gin.Context) {
+
+	programID, ok := app.readIDParam(c, "program_id")
+	if !ok {
+		return
+	}
+
+	err := app.models.Programs.DeleteProgram(int(programID))
+	if err != nil {
+		app.catalogueErrorResponse(c, err, programMessages)
+		return
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Program Deleted", "The program has been deleted."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// addProgramCourseHandler offers a course in a semester of a program
+// Handler for POST "/v1/programs/:program_id/courses"
+func (app *application) addProgramCourseHandler(c *gin.Context) {
+
+	programID, ok := app.readIDParam(c, "program_id")
+	if !ok {
+		return
+	}
+
+	var input data.ProgramCourseInput
+
+	if !app.bindCatalogueInput(c, &input) {
+		return
+	}
+
+	err := app.models.Programs.AddProgramCourse(int(programID), &input)
+	if err != nil {
+		app.catalogueErrorResponse(c, err, programCourseMessages)
+		return
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Course Added", "The course has been added to the program."))
+	c.JSON(http.StatusCreated, gin.H{"messages": msgBox})
+}
+
+// removeProgramCourseHandler stops offering a course in a program
+// Handler for DELETE "/v1/programs/:program_id/courses/:course_id"
+func (app *application) removeProgramCourseHandler(c *gin.Context) {
+
+	programID, ok := app.readIDParam(c, "program_id")
+	if !ok {
+		return
+	}
+
+	courseID, ok := app.readIDParam(c, "course_id")
+	if !ok {
+		return
+	}
+
+	err := app.models.Programs.RemoveProgramCourse(int(programID), courseID)
+	if err != nil {
+		app.catalogueErrorResponse(c, err, programCourseMessages)
+		return
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Course Removed", "The course has been removed from the program."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// createCourseHandler adds a new course
+// Handler for POST "/v1/courses"
+func (app *application) createCourseHandler(c *gin.Context) {
+
+	var input data.CourseInput
+
+	if !app.bindCatalogueInput(c, &input) {
+		return
+	}
+
+	input.CourseCode = strings.TrimSpace(input.CourseCode)
+
+	err := app.models.Courses.Insert(&input)
+	if err != nil {
+		app.catalogueErrorResponse(c, err, courseMessages)
+		return
+	}
+
+	course, err := app.models.Courses.Get(input.CourseCode)
+	if err != nil {
+		app.writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"course": course})
+}
+
+// updateCourseHandler replaces the details of a course
+// Handler for PUT "/v1/courses/:course_code"
+func (app *application) updateCourseHandler(c *gin.Context) {
+
+	var input data.CourseInput
+
+	if !app.bindCatalogueInput(c, &input) {
+		return
+	}
+
+	input.CourseCode = strings.TrimSpace(input.CourseCode)
+
+	err := app.models.Courses.Update(c.Param("course_code"), &input)
+	if err != nil {
+		app.catalogueErrorResponse(c, err, courseMessages)
+		return
+	}
+
+	course, err := app.models.Courses.Get(input.CourseCode)
+	if err != nil {
+		app.writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"course": course})
+}
+
+// deleteCourseHandler deletes a course
+// Handler for DELETE "/v1/courses/:course_code"
+func (app *application) deleteCourseHandler(c *gin.Context) {
+
+	err := app.models.Courses.Delete(c.Param("course_code"))
+	if err != nil {
+		app.catalogueErrorResponse(c, err, courseMessages)
+		return
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Course Deleted", "The course has been deleted."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
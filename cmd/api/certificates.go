@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"image/png"
+	"log"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pdf/fpdf"
+	"github.com/roshanlc/soe-backend/internal/data"
+)
+
+// Templates of the body of certificates
+var certificateTemplates = map[string]*template.Template{
+	"character": template.Must(template.New("character").Parse(
+		`This is to certify that {{.Name}}, bearing symbol number {{.SymbolNo}} and Pokhara University ` +
+			`registration number {{.PURegdNo}}, is a student of {{.Program}} ({{.Level}}) at this institution. ` +
+			`To the best of our knowledge, {{.Name}} bears a good moral character and has not been involved ` +
+			`in any activity against the rules of the institution.`)),
+	"completion": template.Must(template.New("completion").Parse(
+		`This is to certify that {{.Name}}, bearing symbol number {{.SymbolNo}} and Pokhara University ` +
+			`registration number {{.PURegdNo}}, has successfully completed the {{.Program}} ({{.Level}}) ` +
+			`program at this institution, having enrolled on {{.EnrolledAt.Format "January 2, 2006"}}.`)),
+	"enrollment": template.Must(template.New("enrollment").Parse(
+		`This is to certify that {{.Name}}, bearing symbol number {{.SymbolNo}} and Pokhara University ` +
+			`registration number {{.PURegdNo}}, is currently enrolled in semester {{.Semester}} of the ` +
+			`{{.Program}} ({{.Level}}) program at this institution since {{.EnrolledAt.Format "January 2, 2006"}}.`)),
+}
+
+// generateSerial returns a random certificate serial such as SOE-2022-3FA85F6457174562B3FC2C963F66AFA6
+// The serial is the only key to the public verification, so it must not be guessable
+func generateSerial() (string, error) {
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("SOE-%d-%s", time.Now().Year(), strings.ToUpper(hex.EncodeToString(b))), nil
+}
+
+// certificateURL returns the public verification url of a certificate
+func (app *application) certificateURL(serial string) string {
+	return fmt.Sprintf("%s/v1/certificates/verify/%s", app.config.Domain, serial)
+}
+
+// renderCertificatePDF renders a certificate as an A4 pdf
+func (app *application) renderCertificatePDF(cert *data.Certificate) ([]byte, error) {
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pdf.SetMargins(25, 25, 25)
+	pdf.AddPage()
+
+	// Header
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(0, 10, institutionName, "", 1, "C", false, 0, "")
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.CellFormat(0, 7, universityName, "", 1, "C", false, 0, "")
+	pdf.Ln(4)
+	pdf.Line(25, pdf.GetY(), 185, pdf.GetY())
+	pdf.Ln(6)
+
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(80, 6, "Serial No: "+cert.Serial, "", 0, "L", false, 0, "")
+	pdf.CellFormat(0, 6, "Date: "+cert.IssuedAt.Format("January 2, 2006"), "", 1, "R", false, 0, "")
+	pdf.Ln(14)
+
+	// Title and body
+	pdf.SetFont("Helvetica", "BU", 16)
+	pdf.CellFormat(0, 10, strings.ToUpper(cert.Title), "", 1, "C", false, 0, "")
+	pdf.Ln(10)
+
+	pdf.SetFont("Helvetica", "", 12)
+	for _, paragraph := range strings.Split(cert.Body, "\n") {
+		pdf.MultiCell(0, 7, tr(paragraph), "", "J", false)
+		pdf.Ln(4)
+	}
+
+	// Signatory
+	pdf.Ln(25)
+	pdf.Line(130, pdf.GetY(), 185, pdf.GetY())
+	pdf.SetX(130)
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.CellFormat(55, 7, "Campus Chief", "", 1, "C", false, 0, "")
+
+	// Verification details at the bottom
+	code, err := qrCode(app.certificateURL(cert.Serial), 300)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, code); err != nil {
+		return nil, err
+	}
+
+	options := fpdf.ImageOptions{ImageType: "PNG"}
+	pdf.RegisterImageOptionsReader("qr", options, &buf)
+	pdf.ImageOptions("qr", 25, 242, 30, 30, false, options, 0, "")
+
+	pdf.SetFont("Helvetica", "", 8)
+	pdf.SetXY(58, 250)
+	pdf.MultiCell(127, 4, "Verify the authenticity of this certificate at "+app.certificateURL(cert.Serial)+
+		"\nSignature: "+cert.Signature[:32], "", "L", false)
+
+	if err := pdf.Error(); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := pdf.Output(&out); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// issueCertificateHandler issues a certificate to a student from a template
+// Handler for POST "/v1/certificates"
+func (app *application) issueCertificateHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	token := app.currentToken(c)
+	if token == nil {
+		return
+	}
+
+	var input data.CertificateInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	student, err := app.models.Users.GetStudentDetails(input.UserID)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The provided user_id is not a student."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	var body bytes.Buffer
+	err = certificateTemplates[input.Type].Execute(&body, student)
+	if err != nil {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	if remarks := strings.TrimSpace(input.Remarks); remarks != "" {
+		body.WriteString("\n" + remarks)
+	}
+
+	cert := data.Certificate{
+		Type:     input.Type,
+		Title:    data.CertificateTitles[input.Type],
+		UserID:   student.UserID,
+		Name:     student.Name,
+		SymbolNo: student.SymbolNo,
+		PURegdNo: student.PURegdNo,
+		Program:  student.Program,
+		Level:    student.Level,
+		Body:     body.String(),
+		IssuedAt: time.Now().UTC().Truncate(time.Second),
+	}
+
+	// Retry incase of a serial collision
+	for attempt := 0; attempt < 3; attempt++ {
+		cert.Serial, err = generateSerial()
+		if err != nil {
+			break
+		}
+
+		cert.Signature = app.sign(cert.SigningPayload()...)
+
+		err = app.models.Certificates.Insert(&cert, student.StudentID, token.UserID)
+		if err != data.ErrDuplicateEntry {
+			break
+		}
+	}
+
+	if err != nil {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"certificate": cert})
+}
+
+// listCertificatesHandler lists the issued certificates
+// Handler for GET "/v1/certificates"
+func (app *application) listCertificatesHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	certificates, err := app.models.Certificates.GetAll(0)
+
+	if err != nil && err != data.ErrNoRecords {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"certificates": certificates})
+}
+
+// This returns the certificates issued to a student
+// Handler For GET "/v1/students/:user_id/certificates"
+func (app *application) listStudentCertificatesHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	// Check if token matches with provided user ID
+	val, token := app.DoesTokenMatchesUserID(c)
+
+	// If user id does not match with token
+	if !val {
+		return
+	}
+
+	certificates, err := app.models.Certificates.GetAll(token.UserID)
+
+	if err != nil && err != data.ErrNoRecords {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"certificates": certificates})
+}
+
+// showCertificatePDFHandler returns a certificate as pdf
+// Superusers can view any certificate, students only their own
+// Handler for GET "/v1/certificates/:serial/pdf"
+func (app *application) showCertificatePDFHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	token := app.currentToken(c)
+	if token == nil {
+		return
+	}
+
+	role, ok := app.currentRole(c, token)
+	if !ok {
+		return
+	}
+
+	cert, err := app.models.Certificates.GetBySerial(c.Param("serial"))
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The certificate does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	if role != "superuser" && cert.UserID != token.UserID {
+		errBox.Add(data.ResourceNotFoundResponse("The certificate does not exist."))
+		app.ErrorResponse(c, http.StatusNotFound, errBox)
+		return
+	}
+
+	if cert.Revoked {
+		errBox.Add(data.CustomErrorResponse("Gone", "The certificate has been revoked."))
+		app.ErrorResponse(c, http.StatusGone, errBox)
+		return
+	}
+
+	content, err := app.renderCertificatePDF(cert)
+	if err != nil {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s.pdf"`, cert.Serial))
+	c.Data(http.StatusOK, "application/pdf", content)
+}
+
+// revokeCertificateHandler revokes a certificate
+// Handler for PUT "/v1/certificates/:serial/revoke"
+func (app *application) revokeCertificateHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	err := app.models.Certificates.Revoke(c.Param("serial"))
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The certificate does not exist or has already been revoked."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Certificate Revoked", "The certificate has been revoked."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// verifyCertificateHandler verifies the authenticity of a certificate
+// It is public so that anyone holding a certificate can verify it
+// Handler for GET "/v1/certificates/verify/:serial"
+func (app *application) verifyCertificateHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	// Limit the lookups per client, so that serials cannot be enumerated
+	if !app.verifyThrottle.allow(c.ClientIP()) {
+		errBox.Add(data.CustomErrorResponse("Too Many Requests", "Too many verifications were requested. Please wait a few seconds before trying again."))
+		app.ErrorResponse(c, http.StatusTooManyRequests, errBox)
+		return
+	}
+
+	cert, err := app.models.Certificates.GetBySerial(c.Param("serial"))
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("No certificate has been issued with the provided serial."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	// A signature mismatch means the stored record has been altered
+	authentic := app.validSignature(cert.Signature, cert.SigningPayload()...)
+
+	if !authentic {
+		app.logger.PrintError(fmt.Errorf("signature mismatch of certificate %s", cert.Serial), nil)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"valid":   authentic && !cert.Revoked,
+		"revoked": cert.Revoked,
+		"certificate": gin.H{
+			"serial":     cert.Serial,
+			"title":      cert.Title,
+			"name":       cert.Name,
+			"symbol_no":  cert.SymbolNo,
+			"pu_regd_no": cert.PURegdNo,
+			"program":    cert.Program,
+			"level":      cert.Level,
+			"body":       cert.Body,
+			"issued_at":  cert.IssuedAt,
+		},
+	})
+}
@@ -1,7 +1,12 @@
-// This file contains method for cronjob to remove expired tokens
+// This file contains methods for cronjobs (expired tokens removal, overdue book reminders,
+// assignment expiry warnings)
 package main
 
-import "log"
+import (
+	"log"
+
+	"github.com/roshanlc/soe-backend/internal/data"
+)
 
 // This function will remove expired tokens
 func (app *application) expiredTokenRemoval() {
@@ -14,3 +19,96 @@ func (app *application) expiredTokenRemoval() {
 	}
 
 }
+
+// This function will mail reminders to the borrowers of overdue books
+// A borrower is reminded only once a day even if the job runs more often.
+// Only the loans whose reminder was sent are marked, the rest are retried on the next run.
+func (app *application) overdueLoanReminder() {
+
+	log.Println("Sending overdue book reminders..")
+	loans, err := app.models.Library.GetUnremindedOverdueLoans()
+
+	if err != nil {
+		if err != data.ErrNoRecords {
+			log.Println("Error Occured while fetching overdue loans, ", err)
+		}
+		return
+	}
+
+	loanIDs := make([]int64, 0, len(*loans))
+
+	for i := range *loans {
+		loan := &(*loans)[i]
+
+		mailDetails := MailingContent{from: app.config.Mail.Sender, to: loan.Email,
+			subject: "Overdue Library Book: " + loan.Title,
+			content: generateOverdueReminderEmail(loan),
+		}
+
+		if err := app.mailHandler.SendMail(&mailDetails); err != nil {
+			continue
+		}
+
+		loanIDs = append(loanIDs, loan.LoanID)
+	}
+
+	if len(loanIDs) == 0 {
+		return
+	}
+
+	err = app.models.Library.MarkReminded(loanIDs)
+
+	if err != nil {
+		log.Println("Error Occured while marking reminded loans, ", err)
+	}
+
+}
+
+// This function will warn superusers by mail of the assignments of teachers
+// to courses that are about to expire. Each assignment is warned only once,
+// the assignments are warned again on the next run if no superuser could be mailed.
+func (app *application) assignmentExpiryWarning() {
+
+	log.Println("Sending assignment expiry warnings..")
+	assignments, err := app.models.Assignments.GetUnwarnedExpiringAssignments()
+
+	if err != nil {
+		if err != data.ErrNoRecords {
+			log.Println("Error Occured while fetching expiring assignments, ", err)
+		}
+		return
+	}
+
+	superusers, err := app.models.Users.GetAllSuperUsers()
+
+	if err != nil {
+		log.Println("Error Occured while fetching superusers, ", err)
+		return
+	}
+
+	content := generateExpiryWarningEmail(assignments)
+
+	warned := false
+
+	for _, su := range superusers {
+		mailDetails := MailingContent{from: app.config.Mail.Sender, to: su.Email,
+			subject: "Teacher Course Assignments Expiring Soon",
+			content: content,
+		}
+
+		if err := app.mailHandler.SendMail(&mailDetails); err == nil {
+			warned = true
+		}
+	}
+
+	if !warned {
+		return
+	}
+
+	err = app.models.Assignments.MarkWarned(assignments)
+
+	if err != nil {
+		log.Println("Error Occured while marking warned assignments, ", err)
+	}
+
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roshanlc/soe-backend/internal/data"
+	"github.com/roshanlc/soe-backend/internal/validator"
+)
+
+// submitExamFormHandler submits the exam form of a student for the current semester
+// Handler for POST "/v1/students/:user_id/exam-forms"
+func (app *application) submitExamFormHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	// Check if token matches with provided user ID
+	val, token := app.DoesTokenMatchesUserID(c)
+
+	// If user id does not match with token
+	if !val {
+		return
+	}
+
+	var input data.ExamFormInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateExamFormInput(v, &input); !v.Valid() {
+		for key := range v.Errors {
+			errBox.Add(data.BadRequestResponse(v.KeyValuePair(key)))
+		}
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	formID, err := app.models.ExamForms.SubmitForm(token.UserID, &input)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The student does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		case data.ErrInvalidCourse:
+			errBox.Add(data.BadRequestResponse("Electives must be elective courses of the current semester and back papers must be courses of previous semesters of the program."))
+			app.ErrorResponse(c, http.StatusBadRequest, errBox)
+			return
+		case data.ErrDuplicateEntry:
+			errBox.Add(data.CustomErrorResponse("Conflict", "An exam form has already been submitted for the current semester."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		case data.ErrNoRecords:
+			errBox.Add(data.BadRequestResponse("The exam form does not contain any course."))
+			app.ErrorResponse(c, http.StatusBadRequest, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	form, err := app.models.ExamForms.GetForm(formID)
+	if err != nil {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"exam_form": form})
+}
+
+// This returns the exam forms submitted by a student
+// Handler For GET "/v1/students/:user_id/exam-forms"
+func (app *application) listStudentExamFormsHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	// Check if token matches with provided user ID
+	val, token := app.DoesTokenMatchesUserID(c)
+
+	// If user id does not match with token
+	if !val {
+		return
+	}
+
+	forms, err := app.models.ExamForms.GetStudentForms(token.UserID)
+
+	if err != nil && err != data.ErrNoRecords {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"exam_forms": forms})
+}
+
+// listExamFormsHandler lists the exam forms filtered by program_id, semester_id and status
+// Handler for GET "/v1/exam-forms"
+func (app *application) listExamFormsHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	var filters data.ExamFormFilters
+	var err error
+
+	if val, exists := c.GetQuery("program_id"); exists {
+		if filters.ProgramID, err = strconv.Atoi(val); err != nil || filters.ProgramID <= 0 {
+			errBox.Add(data.BadRequestResponse("Please provide a valid program_id value."))
+		}
+	}
+
+	if val, exists := c.GetQuery("semester_id"); exists {
+		if filters.SemesterID, err = strconv.Atoi(val); err != nil || filters.SemesterID <= 0 {
+			errBox.Add(data.BadRequestResponse("Please provide a valid semester_id value."))
+		}
+	}
+
+	filters.Status = c.Query("status")
+	if filters.Status != "" && !validator.In(filters.Status, "pending", "verified", "rejected") {
+		errBox.Add(data.BadRequestResponse("status must be one of pending, verified or rejected."))
+	}
+
+	if len(errBox) != 0 {
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	forms, err := app.models.ExamForms.ListForms(filters)
+
+	if err != nil && err != data.ErrNoRecords {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"exam_forms": forms})
+}
+
+// showExamFormHandler returns an exam form
+// Handler for GET "/v1/exam-forms/:form_id"
+func (app *application) showExamFormHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	formID, ok := app.readIDParam(c, "form_id")
+	if !ok {
+		return
+	}
+
+	form, err := app.models.ExamForms.GetForm(formID)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The provided form_id does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"exam_form": form})
+}
+
+// reviewExamFormHandler verifies or rejects a pending exam form with remarks
+// Handler for PUT "/v1/exam-forms/:form_id"
+func (app *application) reviewExamFormHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	formID, ok := app.readIDParam(c, "form_id")
+	if !ok {
+		return
+	}
+
+	token := app.currentToken(c)
+	if token == nil {
+		return
+	}
+
+	var input data.ExamFormReview
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateExamFormReview(v, &input); !v.Valid() {
+		for key := range v.Errors {
+			errBox.Add(data.BadRequestResponse(v.KeyValuePair(key)))
+		}
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	err = app.models.ExamForms.ReviewForm(formID, &input, token.UserID)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The provided form_id does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		case data.ErrNotPermitted:
+			errBox.Add(data.CustomErrorResponse("Conflict", "The exam form has already been reviewed."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	form, err := app.models.ExamForms.GetForm(formID)
+	if err != nil {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"exam_form": form})
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roshanlc/soe-backend/internal/data"
+	"github.com/roshanlc/soe-backend/internal/validator"
+)
+
+// showGradingScaleHandler returns the grading scale of a level
+// Handler for GET "/v1/levels/:level_id/grading-scale"
+func (app *application) showGradingScaleHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	levelID, ok := app.readIDParam(c, "level_id")
+	if !ok {
+		return
+	}
+
+	scale, err := app.models.Grades.GetGradingScale(int(levelID))
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The provided level_id does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"grading_scale": scale})
+}
+
+// setGradingScaleHandler replaces the grading scale of a level
+// Handler for PUT "/v1/levels/:level_id/grading-scale"
+func (app *application) setGradingScaleHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	levelID, ok := app.readIDParam(c, "level_id")
+	if !ok {
+		return
+	}
+
+	var input data.GradingScaleInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateGradingScale(v, input.Grades); !v.Valid() {
+		for key := range v.Errors {
+			errBox.Add(data.BadRequestResponse(v.KeyValuePair(key)))
+		}
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	err = app.models.Grades.SetGradingScale(int(levelID), input.Grades)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The provided level_id does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	scale, err := app.models.Grades.GetGradingScale(int(levelID))
+	if err != nil {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"grading_scale": scale})
+}
+
+// This returns the transcript of a student with grades, SGPA and CGPA
+// Handler For GET "/v1/students/:user_id/transcript"
+func (app *application) showTranscriptHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	// Check if token matches with provided user ID
+	val, token := app.DoesTokenMatchesUserID(c)
+
+	// If user id does not match with token
+	if !val {
+		return
+	}
+
+	transcript, err := app.models.Grades.GetTranscript(token.UserID)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The student does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		case data.ErrNoGradingScale:
+			errBox.Add(data.CustomErrorResponse("Conflict", "The grading scale of the level of the program does not grade every published result."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"transcript": transcript})
+}
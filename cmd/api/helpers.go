@@ -1,12 +1,15 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/roshanlc/soe-backend/internal/data"
+	"github.com/roshanlc/soe-backend/internal/validator"
 )
 
 // Returns an error response back to user
@@ -34,8 +37,154 @@ func (app *application) NoRouteResponse(c *gin.Context) {
 	c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"errors": errBox})
 }
 
+// readIDParam reads a positive integer id from the url params.
+// Incase of invalid value, it writes the error response and returns false.
+func (app *application) readIDParam(c *gin.Context, name string) (int64, bool) {
+
+	var errBox data.ErrorBox
+
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+
+	if err != nil || id <= 0 {
+		errBox.Add(data.BadRequestResponse(fmt.Sprintf("Please provide a valid %s value.", name)))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return 0, false
+	}
+
+	return id, true
+}
+
+// readPagination reads the page and page_size query strings with defaults 1 and 20.
+// Incase of invalid values, it writes the error response and returns false.
+func (app *application) readPagination(c *gin.Context) (data.Filters, bool) {
+
+	var errBox data.ErrorBox
+
+	filters := data.Filters{Page: 1, PageSize: 20}
+
+	var err error
+
+	if val, exists := c.GetQuery("page"); exists {
+		if filters.Page, err = strconv.Atoi(val); err != nil {
+			filters.Page = 0
+		}
+	}
+
+	if val, exists := c.GetQuery("page_size"); exists {
+		if filters.PageSize, err = strconv.Atoi(val); err != nil {
+			filters.PageSize = 0
+		}
+	}
+
+	v := validator.New()
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		for key := range v.Errors {
+			errBox.Add(data.BadRequestResponse(v.KeyValuePair(key)))
+		}
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return filters, false
+	}
+
+	return filters, true
+}
+
+// currentToken returns the details of token in the Authorization header.
+// Incase of error, it writes the error response and returns nil.
+func (app *application) currentToken(c *gin.Context) *data.Token {
+
+	var errBox data.ErrorBox
+
+	token, err := app.models.Tokens.GetTokenDetails(extractToken(c.GetHeader("Authorization")))
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			errBox.Add(data.AuthorizationErrorResponse("Invalid or expired token."))
+			app.ErrorResponse(c, http.StatusUnauthorized, errBox)
+			return nil
+		default:
+			app.logger.PrintError(err, nil)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing this request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return nil
+		}
+	}
+
+	return token
+}
+
+// currentRole returns the role name of the token owner.
+// Incase of error, it writes the error response and returns false.
+func (app *application) currentRole(c *gin.Context, token *data.Token) (string, bool) {
+
+	var errBox data.ErrorBox
+
+	role, err := app.models.Roles.GetUserRole(token.UserID)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecords):
+			errBox.Add(data.AuthorizationErrorResponse("You donot have authorization access this resource."))
+			app.ErrorResponse(c, http.StatusForbidden, errBox)
+			return "", false
+		default:
+			app.logger.PrintError(err, nil)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing this request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return "", false
+		}
+	}
+
+	return role.Role.Name, true
+}
+
 func (app *application) GetHostAddress() {
 
 	fmt.Println(os.Hostname())
 
 }
+
+// readOfferingQuery reads the program_id, semester_id, course_id and the optional session query strings.
+// Incase of missing or invalid values, it writes the error response and returns false.
+func (app *application) readOfferingQuery(c *gin.Context) (data.Offering, bool) {
+
+	var errBox data.ErrorBox
+	var offering data.Offering
+
+	params := []struct {
+		key  string
+		dest *int
+	}{
+		{"program_id", &offering.ProgramID},
+		{"semester_id", &offering.SemesterID},
+		{"course_id", &offering.CourseID},
+	}
+
+	for _, param := range params {
+		val, err := strconv.Atoi(c.Query(param.key))
+		if err != nil || val <= 0 {
+			errBox.Add(data.BadRequestResponse(fmt.Sprintf("Please provide a valid %s value.", param.key)))
+			continue
+		}
+		*param.dest = val
+	}
+
+	// The session is optional, the current session is used otherwise
+	offering.Session = data.CurrentSession()
+
+	if session := c.Query("session"); session != "" {
+		val, err := strconv.Atoi(session)
+		if err != nil || val < 2000 || val > 2100 {
+			errBox.Add(data.BadRequestResponse("Please provide a valid session value."))
+		}
+		offering.Session = val
+	}
+
+	if len(errBox) != 0 {
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return offering, false
+	}
+
+	return offering, true
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pdf/fpdf"
+	"github.com/roshanlc/soe-backend/internal/data"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Texts printed on the id cards and certificates
+const (
+	institutionName = "SCHOOL OF ENGINEERING"
+	universityName  = "Pokhara University"
+	idCardTitle     = "STUDENT IDENTITY CARD"
+)
+
+// Colour of the header band of id cards
+var idCardColor = color.RGBA{R: 0, G: 51, B: 102, A: 255}
+
+// idCardFields returns the label and value pairs printed on an id card
+func idCardFields(student *data.Student) [][2]string {
+	return [][2]string{
+		{"Name", student.Name},
+		{"Symbol No", strconv.FormatInt(student.SymbolNo, 10)},
+		{"PU Regd No", student.PURegdNo},
+		{"Program", student.Program},
+		{"Level", student.Level},
+		{"Enrolled", student.EnrolledAt.Format("2006-01-02")},
+	}
+}
+
+// idCardURL returns the signed verification url of the id card of a student
+// The random card key of the student is signed in, so that reissuing the card revokes the url
+func (app *application) idCardURL(userID int64, key string) string {
+	id := strconv.FormatInt(userID, 10)
+	return fmt.Sprintf("%s/v1/id-cards/verify/%s?signature=%s", app.config.Domain, id, app.sign("id-card", id, key))
+}
+
+// qrCode returns a QR code of the content scaled to size x size pixels
+func qrCode(content string, size int) (image.Image, error) {
+
+	code, err := qr.Encode(content, qr.M, qr.Auto)
+	if err != nil {
+		return nil, err
+	}
+
+	scaled, err := barcode.Scale(code, size, size)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert to 8-bit grayscale, fpdf does not support 16-bit pngs
+	gray := image.NewGray(scaled.Bounds())
+	draw.Draw(gray, gray.Bounds(), scaled, scaled.Bounds().Min, draw.Src)
+
+	return gray, nil
+}
+
+// addIDCardPage adds a page with the id card of a student to the pdf
+func (app *application) addIDCardPage(pdf *fpdf.Fpdf, student *data.Student, key string) error {
+
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pdf.AddPage()
+
+	// Header band
+	pdf.SetFillColor(int(idCardColor.R), int(idCardColor.G), int(idCardColor.B))
+	pdf.Rect(0, 0, 85.6, 11, "F")
+
+	pdf.SetTextColor(255, 255, 255)
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetXY(0, 1.5)
+	pdf.CellFormat(85.6, 4.5, institutionName, "", 1, "C", false, 0, "")
+	pdf.SetFont("Helvetica", "", 6)
+	pdf.CellFormat(85.6, 3.5, universityName, "", 1, "C", false, 0, "")
+
+	pdf.SetTextColor(int(idCardColor.R), int(idCardColor.G), int(idCardColor.B))
+	pdf.SetFont("Helvetica", "B", 6.5)
+	pdf.SetXY(0, 12)
+	pdf.CellFormat(85.6, 4, idCardTitle, "", 1, "C", false, 0, "")
+
+	// Student details
+	pdf.SetTextColor(0, 0, 0)
+	y := 17.5
+	for _, field := range idCardFields(student) {
+		pdf.SetXY(3, y)
+		pdf.SetFont("Helvetica", "B", 6)
+		pdf.CellFormat(15, 4.5, field[0], "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 6)
+		pdf.CellFormat(40, 4.5, tr(field[1]), "", 0, "L", false, 0, "")
+		y += 5
+	}
+
+	// QR code with the verification url
+	code, err := qrCode(app.idCardURL(student.UserID, key), 300)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, code); err != nil {
+		return err
+	}
+
+	name := "qr-" + strconv.FormatInt(student.UserID, 10)
+	options := fpdf.ImageOptions{ImageType: "PNG"}
+	pdf.RegisterImageOptionsReader(name, options, &buf)
+	pdf.ImageOptions(name, 60.6, 18, 22, 22, false, options, 0, "")
+
+	pdf.SetFont("Helvetica", "", 5)
+	pdf.SetXY(60.6, 40.5)
+	pdf.CellFormat(22, 3, "Scan to verify", "", 0, "C", false, 0, "")
+
+	return pdf.Error()
+}
+
+// renderIDCardsPDF renders the id cards of students, one card per page
+func (app *application) renderIDCardsPDF(students []data.Student) ([]byte, error) {
+
+	userIDs := make([]int64, len(students))
+	for i := range students {
+		userIDs[i] = students[i].UserID
+	}
+
+	keys, err := app.models.Users.GetIDCardKeys(userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// CR80 card size
+	pdf := fpdf.NewCustom(&fpdf.InitType{
+		OrientationStr: "P",
+		UnitStr:        "mm",
+		Size:           fpdf.SizeType{Wd: 85.6, Ht: 54},
+	})
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+
+	for i := range students {
+		if err := app.addIDCardPage(pdf, &students[i], keys[students[i].UserID]); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// renderIDCardPNG renders the id card of a student as a 856x540 png
+// The card is drawn at half the size and scaled up to keep the bitmap font readable
+func (app *application) renderIDCardPNG(student *data.Student) ([]byte, error) {
+
+	keys, err := app.models.Users.GetIDCardKeys([]int64{student.UserID})
+	if err != nil {
+		return nil, err
+	}
+
+	card := image.NewRGBA(image.Rect(0, 0, 428, 270))
+	draw.Draw(card, card.Bounds(), image.White, image.Point{}, draw.Src)
+
+	// Header band
+	draw.Draw(card, image.Rect(0, 0, 428, 52), image.NewUniform(idCardColor), image.Point{}, draw.Src)
+
+	drawText := func(text string, x, y int, col color.Color) {
+		d := font.Drawer{
+			Dst:  card,
+			Src:  image.NewUniform(col),
+			Face: basicfont.Face7x13,
+			Dot:  fixed.P(x, y),
+		}
+		d.DrawString(text)
+	}
+
+	// centerX returns the x position to center a text
+	centerX := func(text string) int {
+		return (428 - len(text)*7) / 2
+	}
+
+	drawText(institutionName, centerX(institutionName), 22, color.White)
+	drawText(universityName, centerX(universityName), 40, color.White)
+	drawText(idCardTitle, centerX(idCardTitle), 72, idCardColor)
+
+	// Student details, long values are cut to stay clear of the QR code
+	y := 100
+	for _, field := range idCardFields(student) {
+		value := field[1]
+		if runes := []rune(value); len(runes) > 28 {
+			value = string(runes[:27]) + "."
+		}
+		drawText(field[0], 14, y, color.Black)
+		drawText(value, 96, y, color.Black)
+		y += 26
+	}
+
+	// QR code with the verification url
+	code, err := qrCode(app.idCardURL(student.UserID, keys[student.UserID]), 110)
+	if err != nil {
+		return nil, err
+	}
+
+	draw.Draw(card, image.Rect(304, 90, 414, 200), code, image.Point{}, draw.Src)
+	drawText("Scan to verify", 310, 218, color.Black)
+
+	// Scale up to the final size
+	scaled := image.NewRGBA(image.Rect(0, 0, 856, 540))
+	xdraw.NearestNeighbor.Scale(scaled, scaled.Bounds(), card, card.Bounds(), xdraw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, scaled); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// showIDCardHandler returns the id card of a student as pdf or png (format=png)
+// Handler For GET "/v1/students/:user_id/id-card"
+func (app *application) showIDCardHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	// Check if token matches with provided user ID
+	val, token := app.DoesTokenMatchesUserID(c)
+
+	// If user id does not match with token
+	if !val {
+		return
+	}
+
+	format := c.DefaultQuery("format", "pdf")
+	if format != "pdf" && format != "png" {
+		errBox.Add(data.BadRequestResponse("format must be either pdf or png."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	student, err := app.models.Users.GetStudentDetails(token.UserID)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The student does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	var content []byte
+	contentType := "application/pdf"
+
+	if format == "png" {
+		content, err = app.renderIDCardPNG(student)
+		contentType = "image/png"
+	} else {
+		content, err = app.renderIDCardsPDF([]data.Student{*student})
+	}
+
+	if err != nil {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="id-card-%d.%s"`, student.SymbolNo, format))
+	c.Data(http.StatusOK, contentType, content)
+}
+
+// listIDCardsHandler returns the id cards of all students of a semester of a program in a single pdf
+// Handler For GET "/v1/id-cards"
+func (app *application) listIDCardsHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	programID, err := strconv.Atoi(c.Query("program_id"))
+	if err != nil || programID <= 0 {
+		errBox.Add(data.BadRequestResponse("Please provide a valid program_id value."))
+	}
+
+	semesterID, err := strconv.Atoi(c.Query("semester_id"))
+	if err != nil || semesterID <= 0 {
+		errBox.Add(data.BadRequestResponse("Please provide a valid semester_id value."))
+	}
+
+	if len(errBox) != 0 {
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	students, err := app.models.Users.GetStudentsOf(programID, semesterID)
+
+	if err != nil {
+		switch err {
+		case data.ErrNoRecords:
+			errBox.Add(data.ResourceNotFoundResponse("There are no students in the provided program_id and semester_id."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	content, err := app.renderIDCardsPDF(*students)
+	if err != nil {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="id-cards-%d-%d.pdf"`, programID, semesterID))
+	c.Data(http.StatusOK, "application/pdf", content)
+}
+
+// reissueIDCardHandler reissues the id card of a student, the QR code of the old card stops verifying
+// Handler For POST "/v1/id-cards/:user_id/reissue"
+func (app *application) reissueIDCardHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	userID, ok := app.readIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	_, err := app.models.Users.ReissueIDCard(userID)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The student does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			app.writeInternalError(c, err)
+			return
+		}
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("ID Card Reissued", "The old id card no longer verifies, please print the id card again."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// verifyIDCardHandler verifies the QR code of an id card
+// Cards of expired or unactivated accounts and reissued cards do not verify.
+// Handler For GET "/v1/id-cards/verify/:user_id"
+func (app *application) verifyIDCardHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	userID, ok := app.readIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	card, err := app.models.Users.GetIDCard(userID)
+
+	if err != nil && err != data.ErrRecordNotFound {
+		app.writeInternalError(c, err)
+		return
+	}
+
+	if err == data.ErrRecordNotFound || !card.Activated || card.Expired ||
+		!app.validSignature(c.Query("signature"), "id-card", strconv.FormatInt(userID, 10), card.Key) {
+		errBox.Add(data.ResourceNotFoundResponse("The id card could not be verified."))
+		app.ErrorResponse(c, http.StatusNotFound, errBox)
+		return
+	}
+
+	student, err := app.models.Users.GetStudentDetails(userID)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The id card could not be verified."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	// Only the details printed on the card are disclosed
+	c.JSON(http.StatusOK, gin.H{
+		"valid": true,
+		"student": gin.H{
+			"name":        student.Name,
+			"symbol_no":   student.SymbolNo,
+			"pu_regd_no":  student.PURegdNo,
+			"program":     student.Program,
+			"level":       student.Level,
+			"enrolled_at": student.EnrolledAt,
+		},
+	})
+}
@@ -1,34 +1,72 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"log"
+	"mime/multipart"
 	"net/http"
+	"os"
+	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/roshanlc/soe-backend/internal/data"
+	"github.com/roshanlc/soe-backend/internal/validator"
 )
 
+// Relative path to folder where attachments of issues will be stored, they are not served statically
+const PathToIssuesStorage = "./uploads/issues/"
+
+// The maximum number of files that can be attached to an issue
+const maxIssueAttachments = 3
+
+// Extensions of the stored attachments by their mime types
+var attachmentExtensions = map[string]string{"application/pdf": ".pdf", "image/png": ".png", "image/jpeg": ".jpg"}
+
 // listIssuesHandler returns the list of issues
-// Handler for "GET" /v1/issues?filter=read/unread
+// Handler for "GET" /v1/issues?filter=read/unread&status=&category=&priority=
 func (app *application) listIssuesHandler(c *gin.Context) {
 
 	var errBox data.ErrorBox
 
 	filter := c.Query("filter")
 
-	all, onlyRead := true, false
+	var filters data.IssueFilters
 
 	if strings.EqualFold(filter, "read") {
-		all = false
-		onlyRead = true
+		onlyRead := true
+		filters.Read = &onlyRead
 	} else if strings.EqualFold(filter, "unread") {
-		all = false
-		onlyRead = false
+		onlyRead := false
+		filters.Read = &onlyRead
+	}
+
+	filters.Status = strings.ToLower(c.Query("status"))
+	if filters.Status != "" && !validator.In(filters.Status, data.IssueOpen, data.IssueInProgress,
+		data.IssueResolved, data.IssueRejected) {
+		errBox.Add(data.BadRequestResponse("status must be one of open, in-progress, resolved or rejected."))
 	}
 
-	issues, err := app.models.Issues.GetAllIssues(all, onlyRead)
+	filters.Category = strings.ToLower(c.Query("category"))
+	if filters.Category != "" && !validator.In(filters.Category, data.IssueCategories...) {
+		errBox.Add(data.BadRequestResponse("category must be one of " + strings.Join(data.IssueCategories, ", ") + "."))
+	}
+
+	filters.Priority = strings.ToLower(c.Query("priority"))
+	if filters.Priority != "" && !validator.In(filters.Priority, data.IssuePriorities...) {
+		errBox.Add(data.BadRequestResponse("priority must be one of " + strings.Join(data.IssuePriorities, ", ") + "."))
+	}
+
+	if len(errBox) != 0 {
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	issues, err := app.models.Issues.GetAllIssues(filters)
 
 	if err != nil {
 		switch err {
@@ -48,7 +86,8 @@ func (app *application) listIssuesHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"issues": issues})
 }
 
-// registerIssueHandler creates a new issue
+// registerIssueHandler creates a new issue, up to 3 pdf, jpeg/jpg or png files can be attached as attachments
+// With anonymous=true, the issue is stored without the user and a tracking code is returned instead
 // Handler for "POST" /v1/issues
 func (app *application) registerIssueHandler(c *gin.Context) {
 
@@ -56,6 +95,8 @@ func (app *application) registerIssueHandler(c *gin.Context) {
 
 	issue := c.PostForm("issue")
 
+	category := strings.ToLower(c.DefaultPostForm("category", "general"))
+
 	token := extractToken(c.GetHeader("Authorization"))
 
 	// If issue field is empty
@@ -73,10 +114,58 @@ func (app *application) registerIssueHandler(c *gin.Context) {
 		return
 	}
 
-	err := app.models.Issues.RegisterIssue(issue, token)
+	if !validator.In(category, data.IssueCategories...) {
+		errBox.Add(data.BadRequestResponse("category must be one of " + strings.Join(data.IssueCategories, ", ") + "."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	anonymous := false
+
+	if val, exists := c.GetPostForm("anonymous"); exists {
+		var err error
+		if anonymous, err = strconv.ParseBool(val); err != nil {
+			errBox.Add(data.BadRequestResponse("anonymous must be either true or false."))
+			app.ErrorResponse(c, http.StatusBadRequest, errBox)
+			return
+		}
+	}
+
+	// The form has already been parsed by PostForm
+	var files []*multipart.FileHeader
+	if c.Request.MultipartForm != nil {
+		files = c.Request.MultipartForm.File["attachments"]
+	}
+
+	attachments, folder, ok := app.saveIssueAttachments(c, files)
+	if !ok {
+		return
+	}
+
+	if anonymous {
+		// The uploaded names could reveal the reporter
+		for i := range attachments {
+			attachments[i].FileName = "attachment-" + path.Base(attachments[i].Path)
+		}
+
+		code, err := app.models.Issues.RegisterAnonymousIssue(issue, category, token, attachments)
+		if err != nil {
+			deleteFolder(folder)
+			app.writeInternalError(c, err)
+			return
+		}
+
+		var msgBox data.MessageBox
+		msgBox.Add(data.MessageResponse("Issue Registered", "The issue was registered anonymously. Keep the tracking code safe, it is the only way to follow the issue and it can not be recovered."))
+		c.JSON(http.StatusCreated, gin.H{"messages": msgBox, "tracking_code": code})
+		return
+	}
+
+	err := app.models.Issues.RegisterIssue(issue, category, token, attachments)
 
 	if err != nil {
 
+		deleteFolder(folder)
 		log.Println(err)
 		errBox.Add(data.InternalServerErrorResponse("The server had problem while processing the request."))
 		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
@@ -91,6 +180,219 @@ func (app *application) registerIssueHandler(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"messages": msgBox})
 }
 
+// saveIssueAttachments checks the attached files and saves them into a new folder under the issues storage
+// It returns the attachments and the folder, which is empty if there are no files.
+// Incase of invalid files, it writes the error response and returns false.
+func (app *application) saveIssueAttachments(c *gin.Context, files []*multipart.FileHeader) ([]data.IssueAttachment, string, bool) {
+
+	var errBox data.ErrorBox
+
+	if len(files) == 0 {
+		return nil, "", true
+	}
+
+	maxSize := 10_048_576 // 10 MB
+
+	// Check the total number of files uploaded
+	if len(files) > maxIssueAttachments {
+		errBox.Add(data.CustomErrorResponse("Too Many Payload Files", fmt.Sprintf("The maximum number of files that can be attached is %d.", maxIssueAttachments)))
+		app.ErrorResponse(c, http.StatusRequestEntityTooLarge, errBox)
+		return nil, "", false
+	}
+
+	// Check if a file exceeds 10MB or is unsupported
+	for _, file := range files {
+
+		if file.Size > int64(maxSize) {
+			errBox.Add(data.CustomErrorResponse("Payload Too Large", "The maximum size of an attachment is 10 MB."))
+			app.ErrorResponse(c, http.StatusRequestEntityTooLarge, errBox)
+			return nil, "", false
+		}
+
+		right, err := validContentType(file)
+		if err != nil {
+			app.writeInternalError(c, err)
+			return nil, "", false
+		}
+
+		// Incase of invalid content type
+		if !right {
+			errBox.Add(data.CustomErrorResponse("Unsupported Media Type", "The supported media types are pdf, jpeg/jpg and png."))
+			app.ErrorResponse(c, http.StatusUnsupportedMediaType, errBox)
+			return nil, "", false
+		}
+	}
+
+	// random folder name, so that the attachments of different issues never collide
+	randomBytes := make([]byte, 16)
+	if _, err := rand.Read(randomBytes); err != nil {
+		app.writeInternalError(c, err)
+		return nil, "", false
+	}
+
+	foldername := hex.EncodeToString(randomBytes)
+	folder := PathToIssuesStorage + foldername
+
+	if err := os.Mkdir(folder, 0777); err != nil {
+		app.writeInternalError(c, err)
+		return nil, "", false
+	}
+
+	attachments := make([]data.IssueAttachment, 0, len(files))
+
+	for i, file := range files {
+
+		contentType, err := detectContentType(file)
+		if err != nil {
+			deleteFolder(folder)
+			app.writeInternalError(c, err)
+			return nil, "", false
+		}
+
+		// The uploaded name is only kept for downloads, the file is stored under its position
+		stored := foldername + "/" + strconv.Itoa(i+1) + attachmentExtensions[contentType]
+
+		err = saveFile(file, filepath.Join(PathToIssuesStorage, filepath.FromSlash(stored)))
+		if err != nil {
+			deleteFolder(folder)
+			app.writeInternalError(c, err)
+			return nil, "", false
+		}
+
+		attachments = append(attachments, data.IssueAttachment{
+			FileName:    filepath.Base(file.Filename),
+			ContentType: contentType,
+			Size:        file.Size,
+			Path:        stored,
+		})
+	}
+
+	return attachments, folder, true
+}
+
+// removeIssueAttachments removes the folders of the stored attachments of deleted issues
+func removeIssueAttachments(paths []string) {
+
+	for _, path := range paths {
+
+		folder := filepath.Dir(filepath.FromSlash(path))
+
+		// Never remove the issues uploads folder itself
+		if folder == "." || folder == string(filepath.Separator) {
+			continue
+		}
+
+		deleteFolder(filepath.Join(PathToIssuesStorage, folder))
+	}
+}
+
+// writeIssueAttachment writes an attachment of an issue as a download
+func (app *application) writeIssueAttachment(c *gin.Context, issue *data.Issue) {
+
+	var errBox data.ErrorBox
+
+	attachmentID, ok := app.readIDParam(c, "attachment_id")
+	if !ok {
+		return
+	}
+
+	for _, attachment := range issue.Attachments {
+		if attachment.AttachmentID != attachmentID {
+			continue
+		}
+
+		fullName := filepath.Join(PathToIssuesStorage, filepath.FromSlash(path.Clean("/"+attachment.Path)))
+
+		// Attachments are private, so they must not be cached by shared caches
+		c.Header("Cache-Control", "private, no-store")
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.FileAttachment(fullName, attachment.FileName)
+		return
+	}
+
+	errBox.Add(data.ResourceNotFoundResponse("The attachment_id does not exist."))
+	app.ErrorResponse(c, http.StatusNotFound, errBox)
+}
+
+// showIssueAttachmentHandler returns an attachment of an issue to its reporter or a superuser
+// Handler for GET "/v1/issues/:issue_id/attachments/:attachment_id"
+func (app *application) showIssueAttachmentHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	issueID, ok := app.readIDParam(c, "issue_id")
+	if !ok {
+		return
+	}
+
+	token := app.currentToken(c)
+	if token == nil {
+		return
+	}
+
+	role, ok := app.currentRole(c, token)
+	if !ok {
+		return
+	}
+
+	issue, err := app.models.Issues.GetIssue(int(issueID))
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The issue_id does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			app.writeInternalError(c, err)
+			return
+		}
+	}
+
+	// The reporter of an anonymous issue downloads the attachments with the tracking code instead
+	reporter := issue.UserID != nil && int64(*issue.UserID) == token.UserID
+
+	if role != "superuser" && !reporter {
+		errBox.Add(data.AuthorizationErrorResponse("You donot have authorization access this resource."))
+		app.ErrorResponse(c, http.StatusForbidden, errBox)
+		return
+	}
+
+	app.writeIssueAttachment(c, issue)
+}
+
+// trackedIssueAttachmentHandler returns an attachment of an anonymous issue by its tracking code
+// Handler for POST "/v1/issues/track/attachments/:attachment_id"
+func (app *application) trackedIssueAttachmentHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	var input data.IssueTrackingInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	issue, err := app.models.Issues.GetIssueByTrackingCode(input.TrackingCode)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("No issue was found for the tracking code."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			app.writeInternalError(c, err)
+			return
+		}
+	}
+
+	app.writeIssueAttachment(c, issue)
+}
+
 // Marks an issue as read
 // Handler For GET "/v1/issues/:issue_id"
 func (app *application) markIssueAsReadHandler(c *gin.Context) {
@@ -138,3 +440,237 @@ func (app *application) markIssueAsReadHandler(c *gin.Context) {
 	// send success msg
 	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
 }
+
+// showIssueHandler returns an issue along with its replies
+// Handler for GET "/v1/issues/:issue_id"
+func (app *application) showIssueHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	issueID, ok := app.readIDParam(c, "issue_id")
+	if !ok {
+		return
+	}
+
+	issue, err := app.models.Issues.GetIssue(int(issueID))
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The issue_id does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			app.writeInternalError(c, err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"issue": issue})
+}
+
+// setIssueStatusHandler moves an issue to another status along with an optional reply,
+// and mails the reporter about the change
+// Handler for PUT "/v1/issues/:issue_id/status"
+func (app *application) setIssueStatusHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	issueID, ok := app.readIDParam(c, "issue_id")
+	if !ok {
+		return
+	}
+
+	var input data.IssueStatusInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	token := app.currentToken(c)
+	if token == nil {
+		return
+	}
+
+	issue, err := app.models.Issues.SetStatus(int(issueID), token.UserID, &input)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The issue_id does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		case data.ErrNotUpdated:
+			errBox.Add(data.CustomErrorResponse("Conflict", "The issue is already "+input.Status+"."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		default:
+			app.writeInternalError(c, err)
+			return
+		}
+	}
+
+	var msgBox data.MessageBox
+
+	// The reporter of an anonymous issue follows it with the tracking code instead
+	if issue.Anonymous {
+		msgBox.Add(data.MessageResponse("Status Updated", "The issue has been moved to "+input.Status+"."))
+		c.JSON(http.StatusOK, gin.H{"messages": msgBox, "issue": issue})
+		return
+	}
+
+	mailDetails := MailingContent{from: app.config.Mail.Sender, to: issue.ReporterEmail,
+		subject: "Update On Your Issue: " + issue.Status,
+		content: generateIssueStatusEmail(issue, strings.TrimSpace(input.Reply)),
+	}
+
+	go app.mailHandler.SendMail(&mailDetails)
+
+	msgBox.Add(data.MessageResponse("Status Updated", "The issue has been moved to "+input.Status+" and the reporter has been notified."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox, "issue": issue})
+}
+
+// classifyIssueHandler sets the category and/or the priority of an issue
+// Handler for PUT "/v1/issues/:issue_id/classification"
+func (app *application) classifyIssueHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	issueID, ok := app.readIDParam(c, "issue_id")
+	if !ok {
+		return
+	}
+
+	var input data.IssueClassificationInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	if input.Category == "" && input.Priority == "" {
+		errBox.Add(data.BadRequestResponse("Please provide the category and/or the priority."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	err = app.models.Issues.Classify(int(issueID), &input)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The issue_id does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			app.writeInternalError(c, err)
+			return
+		}
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Issue Classified", "The issue was classified successfully."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// replyIssueHandler adds a reply to an issue, visible to the reporter
+// Handler for POST "/v1/issues/:issue_id/replies"
+func (app *application) replyIssueHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	issueID, ok := app.readIDParam(c, "issue_id")
+	if !ok {
+		return
+	}
+
+	var input data.IssueReplyInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	if strings.TrimSpace(input.Reply) == "" {
+		errBox.Add(data.BadRequestResponse("reply field must not be empty."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	token := app.currentToken(c)
+	if token == nil {
+		return
+	}
+
+	reply, err := app.models.Issues.AddReply(int(issueID), token.UserID, input.Reply)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The issue_id does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			app.writeInternalError(c, err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"reply": reply})
+}
+
+// trackIssueHandler returns an anonymous issue along with its status and replies by its tracking code
+// The code is read from the body, so that it does not end up in the access logs
+// Handler for POST "/v1/issues/track"
+func (app *application) trackIssueHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	var input data.IssueTrackingInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	issue, err := app.models.Issues.GetIssueByTrackingCode(input.TrackingCode)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("No issue was found for the tracking code."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			app.writeInternalError(c, err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"issue": issue})
+}
+
+// Generate issue status change email content
+func generateIssueStatusEmail(issue *data.Issue, reply string) string {
+
+	part1 := fmt.Sprintf("The status of the issue you lodged on %s has been changed to %s.",
+		issue.CreatedAt.Format("January 2, 2006"), issue.Status)
+	part2 := fmt.Sprintf("Issue: %s", issue.Issue)
+	part3 := "You can follow the issue and its replies from your issues page on the Online Student Portal."
+	part4 := "Much love from OSP team."
+
+	if reply != "" {
+		part3 = fmt.Sprintf("Reply: %s\n\n%s", reply, part3)
+	}
+
+	return fmt.Sprintf("%s\n\n%s\n\n%s\n\n%s", part1, part2, part3, part4)
+}
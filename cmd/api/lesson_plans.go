@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roshanlc/soe-backend/internal/data"
+	"github.com/roshanlc/soe-backend/internal/validator"
+)
+
+// Struct to read completion status of a week
+type LessonCompletionInput struct {
+	Completed *bool `json:"completed" binding:"required"`
+}
+
+// readWeekParam reads the week url param of a lesson plan
+func (app *application) readWeekParam(c *gin.Context) (int, bool) {
+
+	week, ok := app.readIDParam(c, "week")
+	if !ok {
+		return 0, false
+	}
+
+	if week > data.MaxLessonWeeks {
+		var errBox data.ErrorBox
+		errBox.Add(data.BadRequestResponse(fmt.Sprintf("week must not be more than %d.", data.MaxLessonWeeks)))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return 0, false
+	}
+
+	return int(week), true
+}
+
+// lessonPlanErrorResponse writes the error response of lesson plan changes
+func (app *application) lessonPlanErrorResponse(c *gin.Context, err error) {
+
+	var errBox data.ErrorBox
+
+	switch err {
+	case data.ErrRecordNotFound:
+		errBox.Add(data.ResourceNotFoundResponse("The requested course or week does not exist."))
+		app.ErrorResponse(c, http.StatusNotFound, errBox)
+	case data.ErrNotPermitted:
+		errBox.Add(data.AuthorizationErrorResponse("You are not teaching the provided course."))
+		app.ErrorResponse(c, http.StatusForbidden, errBox)
+	case data.ErrInvalidBook:
+		errBox.Add(data.BadRequestResponse("Every referred book must be a text or reference book of the course."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+	default:
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+	}
+}
+
+// showLessonPlanHandler returns the lesson plan of a course
+// Handler for GET "/v1/courses/:course_code/lesson-plan"
+func (app *application) showLessonPlanHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	token := app.currentToken(c)
+	if token == nil {
+		return
+	}
+
+	role, ok := app.currentRole(c, token)
+	if !ok {
+		return
+	}
+
+	courseCode := c.Param("course_code")
+
+	allowed, err := app.models.LessonPlans.CanViewPlan(token.UserID, role, courseCode)
+	if err != nil {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	if !allowed {
+		errBox.Add(data.AuthorizationErrorResponse("You do not have authorization to access this resource."))
+		app.ErrorResponse(c, http.StatusForbidden, errBox)
+		return
+	}
+
+	plan, err := app.models.LessonPlans.GetPlan(courseCode)
+	if err != nil {
+		app.lessonPlanErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"lesson_plan": plan})
+}
+
+// setLessonWeekHandler creates or replaces a week of the lesson plan of a course
+// Handler for PUT "/v1/courses/:course_code/lesson-plan/:week"
+func (app *application) setLessonWeekHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	week, ok := app.readWeekParam(c)
+	if !ok {
+		return
+	}
+
+	token := app.currentToken(c)
+	if token == nil {
+		return
+	}
+
+	var input data.LessonWeekInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateLessonWeek(v, &input); !v.Valid() {
+		for key := range v.Errors {
+			errBox.Add(data.BadRequestResponse(v.KeyValuePair(key)))
+		}
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	err = app.models.LessonPlans.SetWeek(token.UserID, c.Param("course_code"), week, &input)
+	if err != nil {
+		app.lessonPlanErrorResponse(c, err)
+		return
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Lesson Plan Saved", fmt.Sprintf("Week %d of the lesson plan has been saved.", week)))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// deleteLessonWeekHandler removes a week from the lesson plan of a course
+// Handler for DELETE "/v1/courses/:course_code/lesson-plan/:week"
+func (app *application) deleteLessonWeekHandler(c *gin.Context) {
+
+	week, ok := app.readWeekParam(c)
+	if !ok {
+		return
+	}
+
+	token := app.currentToken(c)
+	if token == nil {
+		return
+	}
+
+	err := app.models.LessonPlans.DeleteWeek(token.UserID, c.Param("course_code"), week)
+	if err != nil {
+		app.lessonPlanErrorResponse(c, err)
+		return
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Lesson Plan Deleted", fmt.Sprintf("Week %d of the lesson plan has been deleted.", week)))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// completeLessonWeekHandler marks a week of the lesson plan as completed or not
+// Handler for PUT "/v1/courses/:course_code/lesson-plan/:week/completion"
+func (app *application) completeLessonWeekHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	week, ok := app.readWeekParam(c)
+	if !ok {
+		return
+	}
+
+	token := app.currentToken(c)
+	if token == nil {
+		return
+	}
+
+	var input LessonCompletionInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	err = app.models.LessonPlans.SetCompleted(token.UserID, c.Param("course_code"), week, *input.Completed)
+	if err != nil {
+		app.lessonPlanErrorResponse(c, err)
+		return
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Lesson Plan Updated", fmt.Sprintf("The completion status of week %d has been updated.", week)))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// listLessonPlanStatusHandler returns the completion status of lesson plans of all courses
+// Handler for GET "/v1/lesson-plans/status"
+func (app *application) listLessonPlanStatusHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	statuses, err := app.models.LessonPlans.GetCompletionStatus()
+
+	if err != nil && err != data.ErrNoRecords {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"lesson_plans": statuses})
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roshanlc/soe-backend/internal/data"
+	"github.com/roshanlc/soe-backend/internal/validator"
+)
+
+// listInventoryHandler returns the number of available copies of every book
+// Handler for GET "/v1/library/books"
+func (app *application) listInventoryHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	inventory, err := app.models.Library.GetInventory()
+
+	if err != nil && err != data.ErrNoRecords {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"books": inventory})
+}
+
+// listBookCopiesHandler returns the copies of a book
+// Handler for GET "/v1/library/books/:book_id/copies"
+func (app *application) listBookCopiesHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	bookID, ok := app.readIDParam(c, "book_id")
+	if !ok {
+		return
+	}
+
+	copies, err := app.models.Library.GetCopies(bookID)
+
+	if err != nil && err != data.ErrNoRecords {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"copies": copies})
+}
+
+// addBookCopyHandler adds a new copy of a book to the library
+// Handler for POST "/v1/library/books/:book_id/copies"
+func (app *application) addBookCopyHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	bookID, ok := app.readIDParam(c, "book_id")
+	if !ok {
+		return
+	}
+
+	var input data.BookCopyInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	bookCopy, err := app.models.Library.AddCopy(bookID, &input)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The requested book does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		case data.ErrDuplicateEntry:
+			errBox.Add(data.CustomErrorResponse("Conflict", "A copy with the provided accession_no already exists."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"copy": bookCopy})
+}
+
+// withdrawBookCopyHandler withdraws a lost or damaged copy from circulation
+// Handler for DELETE "/v1/library/copies/:copy_id"
+func (app *application) withdrawBookCopyHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	copyID, ok := app.readIDParam(c, "copy_id")
+	if !ok {
+		return
+	}
+
+	err := app.models.Library.WithdrawCopy(copyID)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The requested copy does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		case data.ErrCopyUnavailable:
+			errBox.Add(data.CustomErrorResponse("Conflict", "The copy is currently issued or has already been withdrawn."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Copy Withdrawn", "The copy has been withdrawn from circulation."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// issueBookCopyHandler issues a copy to a student or teacher
+// Handler for POST "/v1/library/loans"
+func (app *application) issueBookCopyHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	token := app.currentToken(c)
+	if token == nil {
+		return
+	}
+
+	var input data.BookLoanInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	dueDate := time.Now().AddDate(0, 0, data.LoanPeriodDays)
+
+	if input.DueDate != "" {
+		dueDate, err = time.Parse("2006-01-02", input.DueDate)
+		if err != nil {
+			errBox.Add(data.BadRequestResponse("Please provide the due_date in YYYY-MM-DD format."))
+			app.ErrorResponse(c, http.StatusBadRequest, errBox)
+			return
+		}
+
+		if !dueDate.After(time.Now()) {
+			errBox.Add(data.BadRequestResponse("The due_date must be a future date."))
+			app.ErrorResponse(c, http.StatusBadRequest, errBox)
+			return
+		}
+	}
+
+	loan, err := app.models.Library.IssueCopy(&input, dueDate, token.UserID)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The requested copy does not exist."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		case data.ErrInvalidBorrower:
+			errBox.Add(data.BadRequestResponse("Copies can only be issued to activated students and teachers."))
+			app.ErrorResponse(c, http.StatusBadRequest, errBox)
+			return
+		case data.ErrCopyUnavailable:
+			errBox.Add(data.CustomErrorResponse("Conflict", "The copy is already issued or has been withdrawn."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		case data.ErrLoanLimitReached:
+			errBox.Add(data.CustomErrorResponse("Conflict", fmt.Sprintf("The user already holds %d copies.", data.MaxOpenLoans)))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"loan": loan})
+}
+
+// returnBookCopyHandler records the return of an issued copy
+// Handler for PUT "/v1/library/loans/:loan_id/return"
+func (app *application) returnBookCopyHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	loanID, ok := app.readIDParam(c, "loan_id")
+	if !ok {
+		return
+	}
+
+	loan, err := app.models.Library.ReturnCopy(loanID)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The loan does not exist or the copy has already been returned."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"loan": loan})
+}
+
+// payFineHandler records the payment of the fine of a returned copy
+// Handler for PUT "/v1/library/loans/:loan_id/fine"
+func (app *application) payFineHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	loanID, ok := app.readIDParam(c, "loan_id")
+	if !ok {
+		return
+	}
+
+	err := app.models.Library.PayFine(loanID)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The loan does not exist or has no unpaid fine."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Fine Paid", "The fine has been marked as paid."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// listLoansHandler lists the loans, optionally filtered by user_id and status
+// Handler for GET "/v1/library/loans"
+func (app *application) listLoansHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	var filters data.BookLoanFilters
+	var err error
+
+	if val, exists := c.GetQuery("user_id"); exists {
+		if filters.UserID, err = strconv.ParseInt(val, 10, 64); err != nil || filters.UserID <= 0 {
+			errBox.Add(data.BadRequestResponse("Please provide a valid user_id value."))
+		}
+	}
+
+	filters.Status = strings.ToLower(c.Query("status"))
+	if filters.Status != "" && !validator.In(filters.Status, "open", "overdue", "returned") {
+		errBox.Add(data.BadRequestResponse("status must be one of open, overdue or returned."))
+	}
+
+	if len(errBox) != 0 {
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	loans, err := app.models.Library.GetLoans(filters)
+
+	if err != nil && err != data.ErrNoRecords {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"loans": loans})
+}
+
+// listUserLoansHandler returns the loans of a student or teacher
+// Handler for GET "/v1/students/:user_id/loans" and GET "/v1/teachers/:user_id/loans"
+func (app *application) listUserLoansHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	// Check if token matches with provided user ID
+	val, token := app.DoesTokenMatchesUserID(c)
+
+	// If user id does not match with token
+	if !val {
+		return
+	}
+
+	loans, err := app.models.Library.GetLoans(data.BookLoanFilters{UserID: token.UserID})
+
+	if err != nil && err != data.ErrNoRecords {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"loans": loans})
+}
+
+// Generate overdue reminder email content
+func generateOverdueReminderEmail(loan *data.BookLoan) string {
+
+	part1 := fmt.Sprintf("Dear %s, the following book issued to you from the library is overdue.", loan.Borrower)
+	part2 := fmt.Sprintf("Book: %s (Accession No: %s)\nDue Date: %s\nDays Overdue: %d\nFine So Far: Rs. %.2f",
+		loan.Title, loan.AccessionNo, loan.DueDate.Format("January 2, 2006"), loan.DaysOverdue, loan.Fine)
+	part3 := fmt.Sprintf("Please return the book as soon as possible. A fine of Rs. %.2f is charged for each day after the due date.", data.FinePerDay)
+	part4 := "Much love from OSP team."
+
+	return fmt.Sprintf("%s\n\n%s\n\n%s\n\n%s", part1, part2, part3, part4)
+}
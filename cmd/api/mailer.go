@@ -47,7 +47,9 @@ func (m *MailingContainer) Authenticate(config *data.Config) error {
 
 }
 
-func (m *MailingContainer) SendMail(obj *MailingContent) {
+// SendMail sends the mail, failures are logged and returned
+// so that the jobs can retry the mails which were not sent
+func (m *MailingContainer) SendMail(obj *MailingContent) error {
 
 	email := mail.NewMsg()
 
@@ -62,8 +64,10 @@ func (m *MailingContainer) SendMail(obj *MailingContent) {
 	// log the error
 	if err != nil {
 		log.Println(err)
+		return err
 	}
 
+	return nil
 }
 
 func NewMailer() *MailingContainer {
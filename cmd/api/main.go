@@ -2,6 +2,7 @@ package main
 
 import (
 	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -22,10 +23,14 @@ const version = "1.0.0"
 // such as handlers, middlewares, database connection and so on.
 // It can grow as needed.
 type application struct {
-	config      *data.Config
-	logger      *jsonlog.Logger
-	models      data.Models
-	mailHandler *MailingContainer
+	config            *data.Config
+	logger            *jsonlog.Logger
+	models            data.Models
+	mailHandler       *MailingContainer
+	resendThrottle    *throttle // limits activation email resends per email
+	resetThrottle     *throttle // limits password reset emails per email
+	admissionThrottle *throttle // limits public enquiry and application submissions per client ip
+	verifyThrottle    *throttle // limits public certificate verifications per client ip
 }
 
 func main() {
@@ -63,6 +68,16 @@ func main() {
 
 	logger.PrintInfo("Config file has been loaded.", nil)
 
+	// The secret is used to sign verification urls
+	if len(cfg.Secret) < 32 {
+		logger.PrintFatal(errors.New("the secret in config.toml must be at least 32 characters long"), nil)
+	}
+
+	// The placeholder once shipped in config_example.toml is publicly known
+	if cfg.Secret == "change-me-to-a-long-random-string" {
+		logger.PrintFatal(errors.New("the secret in config.toml must be changed from the example value"), nil)
+	}
+
 	// setupFolders
 	setupFolders()
 
@@ -83,10 +98,14 @@ func main() {
 	logger.PrintInfo("database connection pool established", nil)
 
 	app := &application{
-		config:      cfg,
-		logger:      logger,
-		models:      data.NewModels(db),
-		mailHandler: NewMailer(),
+		config:            cfg,
+		logger:            logger,
+		models:            data.NewModels(db),
+		mailHandler:       NewMailer(),
+		resendThrottle:    newThrottle(5 * time.Minute),
+		resetThrottle:     newThrottle(5 * time.Minute),
+		admissionThrottle: newThrottle(time.Minute),
+		verifyThrottle:    newThrottle(2 * time.Second),
 	}
 
 	// Start mailer
@@ -162,4 +181,7 @@ func setupFolders() {
 
 	// Create directories
 	os.MkdirAll(uploadsFolder, 0777) // IDK why but 0777 is the only permission that allows creating new files/dirs inside it
+
+	// Attachments of issues are not served statically
+	os.MkdirAll(PathToIssuesStorage, 0777)
 }
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roshanlc/soe-backend/internal/data"
+	"github.com/roshanlc/soe-backend/internal/validator"
+)
+
+// parseMarksCSV reads marks from a csv with a header row
+// The symbol_no column is required, internal and final columns are optional
+// An empty cell keeps the previously uploaded value
+func parseMarksCSV(r io.Reader) ([]data.MarkEntry, error) {
+
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("the csv must contain a header row")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	if _, exists := columns["symbol_no"]; !exists {
+		return nil, errors.New("the csv must contain a symbol_no column")
+	}
+
+	// readFloat returns nil for a missing column or an empty cell
+	readFloat := func(record []string, column string, line int) (*float64, error) {
+		i, exists := columns[column]
+		if !exists || strings.TrimSpace(record[i]) == "" {
+			return nil, nil
+		}
+		val, err := strconv.ParseFloat(strings.TrimSpace(record[i]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value on line %d", column, line)
+		}
+		return &val, nil
+	}
+
+	marks := []data.MarkEntry{}
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv on line %d", line)
+		}
+
+		var entry data.MarkEntry
+
+		entry.SymbolNo, err = strconv.ParseInt(strings.TrimSpace(record[columns["symbol_no"]]), 10, 64)
+		if err != nil || entry.SymbolNo <= 0 {
+			return nil, fmt.Errorf("invalid symbol_no value on line %d", line)
+		}
+
+		if entry.Internal, err = readFloat(record, "internal", line); err != nil {
+			return nil, err
+		}
+		if entry.Final, err = readFloat(record, "final", line); err != nil {
+			return nil, err
+		}
+
+		marks = append(marks, entry)
+	}
+
+	if len(marks) == 0 {
+		return nil, errors.New("the csv does not contain any marks")
+	}
+
+	return marks, nil
+}
+
+// uploadMarksHandler uploads the marks of students of a course offering
+// Marks can be sent as json, or as csv with the offering in the query strings
+// Handler for POST "/v1/marks"
+func (app *application) uploadMarksHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	token := app.currentToken(c)
+	if token == nil {
+		return
+	}
+
+	var input data.MarksUpload
+
+	if c.ContentType() == "text/csv" {
+		offering, ok := app.readOfferingQuery(c)
+		if !ok {
+			return
+		}
+
+		marks, err := parseMarksCSV(c.Request.Body)
+		if err != nil {
+			errBox.Add(data.BadRequestResponse(err.Error()))
+			app.ErrorResponse(c, http.StatusBadRequest, errBox)
+			return
+		}
+
+		input = data.MarksUpload{
+			ProgramID:  offering.ProgramID,
+			SemesterID: offering.SemesterID,
+			CourseID:   offering.CourseID,
+			Session:    offering.Session,
+			Marks:      marks,
+		}
+	} else {
+		err := c.ShouldBindJSON(&input)
+		if err != nil {
+			errBox.Add(data.BadRequestResponse(err.Error()))
+			app.ErrorResponse(c, http.StatusBadRequest, errBox)
+			return
+		}
+
+		if input.Session == 0 {
+			input.Session = data.CurrentSession()
+		}
+	}
+
+	v := validator.New()
+
+	if data.ValidateMarks(v, input.Marks); !v.Valid() {
+		for key := range v.Errors {
+			errBox.Add(data.BadRequestResponse(v.KeyValuePair(key)))
+		}
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	err := app.models.Marks.UploadMarks(token.UserID, &input)
+
+	if err != nil {
+		switch err {
+		case data.ErrNotPermitted:
+			errBox.Add(data.AuthorizationErrorResponse("You are not teaching the provided course."))
+			app.ErrorResponse(c, http.StatusForbidden, errBox)
+			return
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The provided course is not offered in the provided program_id and semester_id."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		case data.ErrMarksLocked:
+			errBox.Add(data.CustomErrorResponse("Conflict", "The marks of the course have been locked."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		case data.ErrInvalidStudent:
+			errBox.Add(data.BadRequestResponse("Every symbol_no must be of a student of the provided program_id and semester_id."))
+			app.ErrorResponse(c, http.StatusBadRequest, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Marks Uploaded", "The marks have been uploaded successfully."))
+	c.JSON(http.StatusCreated, gin.H{"messages": msgBox})
+}
+
+// listOfferingMarksHandler returns the marks of all students of a course offering
+// Superusers can view any course, teachers only the courses they teach
+// Handler for GET "/v1/marks"
+func (app *application) listOfferingMarksHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	offering, ok := app.readOfferingQuery(c)
+	if !ok {
+		return
+	}
+
+	token := app.currentToken(c)
+	if token == nil {
+		return
+	}
+
+	role, ok := app.currentRole(c, token)
+	if !ok {
+		return
+	}
+
+	var userID int64
+
+	switch role {
+	case "superuser":
+		userID = 0
+	case "teacher":
+		userID = token.UserID
+	default:
+		errBox.Add(data.AuthorizationErrorResponse("You do not have authorization to access this resource."))
+		app.ErrorResponse(c, http.StatusForbidden, errBox)
+		return
+	}
+
+	marks, err := app.models.Marks.GetOfferingMarks(userID, &offering)
+
+	if err != nil {
+		switch err {
+		case data.ErrNotPermitted:
+			errBox.Add(data.AuthorizationErrorResponse("You are not teaching the provided course."))
+			app.ErrorResponse(c, http.StatusForbidden, errBox)
+			return
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The provided course is not offered in the provided program_id and semester_id."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"marks": marks})
+}
+
+// This returns the published (locked) marks of a student in all courses
+// Handler For GET "/v1/students/:user_id/marks"
+func (app *application) showStudentMarksHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	// Check if token matches with provided user ID
+	val, token := app.DoesTokenMatchesUserID(c)
+
+	// If user id does not match with token
+	if !val {
+		return
+	}
+
+	marks, err := app.models.Marks.GetStudentMarks(token.UserID)
+
+	if err != nil && err != data.ErrNoRecords {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"marks": marks})
+}
+
+// lockMarksHandler locks the published marks of a course offering
+// Handler for POST "/v1/marks/lock"
+func (app *application) lockMarksHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	token := app.currentToken(c)
+	if token == nil {
+		return
+	}
+
+	var input data.Offering
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	if input.Session == 0 {
+		input.Session = data.CurrentSession()
+	}
+
+	err = app.models.Marks.LockMarks(&input, token.UserID)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The provided course is not offered in the provided program_id and semester_id."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		case data.ErrDuplicateEntry:
+			errBox.Add(data.CustomErrorResponse("Conflict", "The marks of the course have already been locked."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Marks Locked", "The marks of the course have been locked."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// unlockMarksHandler unlocks the marks of a course offering
+// Handler for DELETE "/v1/marks/lock"
+func (app *application) unlockMarksHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	offering, ok := app.readOfferingQuery(c)
+	if !ok {
+		return
+	}
+
+	err := app.models.Marks.UnlockMarks(&offering)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.ResourceNotFoundResponse("The marks of the course are not locked."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Marks Unlocked", "The marks of the course have been unlocked."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
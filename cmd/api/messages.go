@@ -0,0 +1,185 @@
+// This contains handlers for the messaging between students, teachers and superusers
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roshanlc/soe-backend/internal/data"
+)
+
+// messageErrorResponse writes the error response of sending a message
+func (app *application) messageErrorResponse(c *gin.Context, err error) {
+
+	var errBox data.ErrorBox
+
+	switch err {
+	case data.ErrRecordNotFound:
+		errBox.Add(data.ResourceNotFoundResponse("The requested conversation does not exist."))
+		app.ErrorResponse(c, http.StatusNotFound, errBox)
+	case data.ErrInvalidRecipient:
+		errBox.Add(data.BadRequestResponse("The recipient does not exist or is yourself."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+	case data.ErrNotPermitted:
+		errBox.Add(data.AuthorizationErrorResponse("You are not allowed to message this user. Students can only message superusers and teachers of their program."))
+		app.ErrorResponse(c, http.StatusForbidden, errBox)
+	default:
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+	}
+}
+
+// sendMessageHandler sends a message to a user, starting a conversation if needed
+// Handler for POST "/v1/messages"
+func (app *application) sendMessageHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	token := app.currentToken(c)
+	if token == nil {
+		return
+	}
+
+	var input data.MessageInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	input.Body = strings.TrimSpace(input.Body)
+	if input.Body == "" {
+		errBox.Add(data.BadRequestResponse("The message must not be empty."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	message, err := app.models.Messages.Send(token.UserID, &input)
+	if err != nil {
+		app.messageErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": message})
+}
+
+// listConversationsHandler lists the conversations of the user along with their unread messages
+// Handler for GET "/v1/conversations"
+func (app *application) listConversationsHandler(c *gin.Context) {
+
+	token := app.currentToken(c)
+	if token == nil {
+		return
+	}
+
+	conversations, err := app.models.Messages.GetConversations(token.UserID)
+	if err != nil {
+		app.writeInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"conversations": conversations})
+}
+
+// showConversationHandler returns a page of the messages of a conversation, latest first
+// Messages are not marked as read, see markConversationReadHandler
+// Handler for GET "/v1/conversations/:conversation_id?page=&page_size="
+func (app *application) showConversationHandler(c *gin.Context) {
+
+	token := app.currentToken(c)
+	if token == nil {
+		return
+	}
+
+	conversationID, ok := app.readIDParam(c, "conversation_id")
+	if !ok {
+		return
+	}
+
+	pagination, ok := app.readPagination(c)
+	if !ok {
+		return
+	}
+
+	messages, metadata, err := app.models.Messages.GetMessages(token.UserID, conversationID, pagination)
+	if err != nil {
+		app.messageErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"conversation": gin.H{"conversation_id": conversationID, "messages": messages},
+		"metadata":     metadata,
+	})
+}
+
+// replyMessageHandler sends a message in a conversation
+// Handler for POST "/v1/conversations/:conversation_id/messages"
+func (app *application) replyMessageHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	token := app.currentToken(c)
+	if token == nil {
+		return
+	}
+
+	conversationID, ok := app.readIDParam(c, "conversation_id")
+	if !ok {
+		return
+	}
+
+	var input data.ReplyInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	input.Body = strings.TrimSpace(input.Body)
+	if input.Body == "" {
+		errBox.Add(data.BadRequestResponse("The message must not be empty."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	message, err := app.models.Messages.Reply(token.UserID, conversationID, &input)
+	if err != nil {
+		app.messageErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": message})
+}
+
+// markConversationReadHandler marks the received messages of a conversation as read
+// Handler for PUT "/v1/conversations/:conversation_id/read"
+func (app *application) markConversationReadHandler(c *gin.Context) {
+
+	token := app.currentToken(c)
+	if token == nil {
+		return
+	}
+
+	conversationID, ok := app.readIDParam(c, "conversation_id")
+	if !ok {
+		return
+	}
+
+	marked, err := app.models.Messages.MarkRead(token.UserID, conversationID)
+	if err != nil {
+		app.messageErrorResponse(c, err)
+		return
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Conversation Read", "The messages of the conversation have been marked as read."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox, "marked": marked})
+}
@@ -338,12 +338,31 @@ func (app *application) deleteNoticeHandler(c *gin.Context) {
 
 // validContentType checks if a file is of supported type or not
 func validContentType(f *multipart.FileHeader) (bool, error) {
+
+	t, err := detectContentType(f)
+
+	// If  errors
+	if err != nil {
+		return false, err
+	}
+
+	// If content-type matches with saved ones
+	for _, val := range SupportedFileType {
+		if t == val {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// detectContentType returns the lower cased mime type of a file from its content
+func detectContentType(f *multipart.FileHeader) (string, error) {
 	// Open the file to check content type
 	d, err := f.Open()
 
 	// If  errors
 	if err != nil {
-		return false, err
+		return "", err
 	}
 
 	defer d.Close()
@@ -351,18 +370,10 @@ func validContentType(f *multipart.FileHeader) (bool, error) {
 
 	// If  errors
 	if err != nil {
-		return false, err
+		return "", err
 	}
 
-	t := http.DetectContentType(a)
-
-	// If content-type matches with saved ones
-	for _, val := range SupportedFileType {
-		if strings.ToLower(t) == val {
-			return true, nil
-		}
-	}
-	return false, nil
+	return strings.ToLower(http.DetectContentType(a)), nil
 }
 
 // Save file
@@ -351,3 +351,117 @@ func (app *application) listDepartmentsHandler(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"departments": departments})
 }
+
+// struct to read semester promotion details
+type PromotionInput struct {
+	ProgramID   int     `json:"program_id" binding:"required"`
+	SemesterID  int     `json:"semester_id" binding:"required"`
+	Exclude     []int64 `json:"exclude"`      // user_id of students who are held back
+	EndSemester bool    `json:"end_semester"` // stop running the semester after the promotion
+}
+
+// promoteStudentsHandler promotes the students of a running semester to the next semester
+// Handler for POST "/v1/semesters/promote"
+func (app *application) promoteStudentsHandler(c *gin.Context) {
+
+	// slice containing errors
+	var errBox data.ErrorBox
+
+	var input PromotionInput
+
+	err := c.ShouldBindJSON(&input)
+
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	if input.ProgramID <= 0 || input.SemesterID <= 0 {
+		errBox.Add(data.BadRequestResponse("Please provide valid program_id or semester_id value."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	// Remove repeated user ids from exclusions
+	seen := make(map[int64]bool)
+	exclude := []int64{}
+	for _, id := range input.Exclude {
+		if !seen[id] {
+			seen[id] = true
+			exclude = append(exclude, id)
+		}
+	}
+
+	// The held back students remain in the semester, so it must keep running
+	if input.EndSemester && len(exclude) > 0 {
+		errBox.Add(data.BadRequestResponse("A semester with held back students cannot be ended."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	// Only a running semester of the program can be promoted
+	semesters, err := app.models.Programs.GetRunningSemesters(input.ProgramID)
+
+	if err != nil && err != data.ErrNoRecords {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	running := false
+	if semesters != nil {
+		for _, sem := range *semesters {
+			if sem.SemesterID == input.SemesterID {
+				running = true
+				break
+			}
+		}
+	}
+
+	if !running {
+		errBox.Add(data.BadRequestResponse("The provided semester_id is not a running semester of the program."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	// The next semester must exist
+	_, err = app.models.Programs.GetSemester(input.SemesterID + 1)
+
+	if err != nil {
+		switch err {
+		case data.ErrRecordNotFound:
+			errBox.Add(data.BadRequestResponse("The students of the final semester cannot be promoted."))
+			app.ErrorResponse(c, http.StatusBadRequest, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	report, err := app.models.Programs.PromoteStudents(input.ProgramID, input.SemesterID, exclude, input.EndSemester)
+
+	if err != nil {
+		switch err {
+		case data.ErrInvalidExclusion:
+			errBox.Add(data.BadRequestResponse("Every excluded user_id must be a student of the provided program_id and semester_id."))
+			app.ErrorResponse(c, http.StatusBadRequest, errBox)
+			return
+		case data.ErrNoRecords:
+			errBox.Add(data.ResourceNotFoundResponse("There are no students to promote in the provided semester."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
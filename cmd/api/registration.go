@@ -33,6 +33,11 @@ const activatedHTML = `<!DOCTYPE html>
 </html>
 `
 
+// struct to read email for resending activation link
+type ActivationResendRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
 // registerStudentHandler registers a student
 // Handler for POST /v1/students/register
 func (app *application) registerStudentHandler(c *gin.Context) {
@@ -247,6 +252,90 @@ func (app *application) activateUserHandler(c *gin.Context) {
 
 }
 
+// resendActivationHandler sends a fresh activation link to an unactivated account
+// Handler for POST /v1/users/activate/resend
+func (app *application) resendActivationHandler(c *gin.Context) {
+	// list of errors
+	var errBox data.ErrorBox
+
+	var input ActivationResendRequest
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	v := validator.New()
+
+	data.ValidateEmail(v, input.Email)
+
+	// If errors
+	if !v.Valid() {
+		errBox.Add(data.CustomErrorResponse("Invalid Email", "Please provide a valid email address."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	// Only one resend per email is allowed within the throttle interval
+	if !app.resendThrottle.allow(input.Email) {
+		errBox.Add(data.CustomErrorResponse("Too Many Requests", "An activation email was sent recently. Please wait a few minutes before trying again."))
+		app.ErrorResponse(c, http.StatusTooManyRequests, errBox)
+		return
+	}
+
+	// The same message is returned for unknown or already activated emails
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Activation Email Sent", "If the email belongs to an unactivated account, a new activation link has been sent. The activation link is valid for 24 hours."))
+
+	user, err := app.models.Users.GetByEmail(input.Email)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			c.JSON(http.StatusAccepted, gin.H{"messages": msgBox})
+			return
+		default:
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing this request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	if user.Activated || user.Expired {
+		c.JSON(http.StatusAccepted, gin.H{"messages": msgBox})
+		return
+	}
+
+	// Remove the old activation tokens, only the new link should work
+	err = app.models.Tokens.DeleteAllForUser(data.ScopeActivation, user.UserID)
+
+	if err != nil {
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing this request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	token, err := app.models.Tokens.GenAndInsertActivationToken(user.Email, 24*time.Hour)
+	if err != nil {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing this request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	link := app.config.Domain + "/v1/users/activate?token=" + token.Hash
+
+	mailDetails := MailingContent{from: app.config.Mail.Sender, to: user.Email,
+		subject: "Activation Your Student Portal Account",
+		content: generateEmail(link)}
+
+	go app.mailHandler.SendMail(&mailDetails)
+
+	c.JSON(http.StatusAccepted, gin.H{"messages": msgBox})
+}
+
 // Generate activation email content
 func generateEmail(link string) string {
 
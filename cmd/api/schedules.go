@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/roshanlc/soe-backend/internal/data"
@@ -113,10 +114,23 @@ func (app *application) setScheduleHandler(c *gin.Context) {
 		}
 	}
 
-	err = app.models.Schedule.SetSchedule(&schedule)
+	if len(schedule.Slots()) == 0 {
+		errBox.Add(data.BadRequestResponse("Please provide at least one interval in the schedule."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	conflicts, err := app.models.Schedule.SetSchedule(&schedule)
 
 	if err != nil {
 		switch err {
+		case data.ErrScheduleConflict:
+			// Report every conflict found
+			for _, conflict := range conflicts {
+				errBox.Add(data.ScheduleConflictResponse(conflict))
+			}
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
 		case data.ErrNoRecords:
 			errBox.Add(data.BadRequestResponse("Please provide a valid mix of program_id and semester_id."))
 			app.ErrorResponse(c, http.StatusBadRequest, errBox)
@@ -291,3 +305,207 @@ func (app *application) deleteScheduleHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
 
 }
+
+// readSlotQuery reads the slot identified by the program_id, semester_id, day, interval_id
+// and course_id query parameters. Incase of invalid values, it writes the error response and returns false.
+func (app *application) readSlotQuery(c *gin.Context) (data.ScheduleSlot, bool) {
+
+	var errBox data.ErrorBox
+	var slot data.ScheduleSlot
+
+	params := []struct {
+		key  string
+		dest *int
+	}{
+		{"program_id", &slot.ProgramID},
+		{"semester_id", &slot.SemesterID},
+		{"interval_id", &slot.IntervalID},
+		{"course_id", &slot.CourseID},
+	}
+
+	for _, param := range params {
+		val, err := strconv.Atoi(c.Query(param.key))
+		if err != nil || val <= 0 {
+			errBox.Add(data.BadRequestResponse(fmt.Sprintf("Please provide a valid %s value.", param.key)))
+			continue
+		}
+		*param.dest = val
+	}
+
+	slot.Day = strings.ToUpper(strings.TrimSpace(c.Query("day")))
+	if slot.Day == "" {
+		errBox.Add(data.BadRequestResponse("Please provide the day value."))
+	}
+
+	if len(errBox) != 0 {
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return slot, false
+	}
+
+	return slot, true
+}
+
+// scheduleSlotErrorResponse writes the error response of the changes to a slot
+func (app *application) scheduleSlotErrorResponse(c *gin.Context, err error, conflicts []data.ScheduleConflict) {
+
+	var errBox data.ErrorBox
+
+	switch err {
+	case data.ErrScheduleConflict:
+		for _, conflict := range conflicts {
+			errBox.Add(data.ScheduleConflictResponse(conflict))
+		}
+		app.ErrorResponse(c, http.StatusConflict, errBox)
+	case data.ErrNoRecords:
+		errBox.Add(data.BadRequestResponse("Please provide a valid mix of program_id and semester_id."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+	case data.ErrRecordNotFound:
+		errBox.Add(data.ResourceNotFoundResponse("The requested slot does not exist in the schedule."))
+		app.ErrorResponse(c, http.StatusNotFound, errBox)
+	default:
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+	}
+}
+
+// addScheduleSlotHandler adds a slot to the schedule of a running semester
+// Handler for POST "/v1/schedules/slots"
+func (app *application) addScheduleSlotHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	var input data.ScheduleSlotInput
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	change, conflicts, err := app.models.Schedule.AddSlot(input.Slot())
+	if err != nil {
+		app.scheduleSlotErrorResponse(c, err, conflicts)
+		return
+	}
+
+	go app.notifyScheduleChange(change)
+
+	c.JSON(http.StatusCreated, gin.H{"slot": change.After})
+}
+
+// updateScheduleSlotHandler moves a slot to another day or interval and/or updates its description
+// Handler for PUT "/v1/schedules/slots?program_id=&semester_id=&day=&interval_id=&course_id="
+func (app *application) updateScheduleSlotHandler(c *gin.Context) {
+
+	var errBox data.ErrorBox
+
+	slot, ok := app.readSlotQuery(c)
+	if !ok {
+		return
+	}
+
+	var input data.ScheduleSlotUpdate
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	if input.Day == "" && input.IntervalID == 0 && input.Description == nil {
+		errBox.Add(data.BadRequestResponse("Please provide the day, interval_id or description to change."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	change, conflicts, err := app.models.Schedule.UpdateSlot(slot, &input)
+	if err != nil {
+		app.scheduleSlotErrorResponse(c, err, conflicts)
+		return
+	}
+
+	go app.notifyScheduleChange(change)
+
+	c.JSON(http.StatusOK, gin.H{"slot": change.After})
+}
+
+// removeScheduleSlotHandler removes a slot from the schedule
+// Handler for DELETE "/v1/schedules/slots?program_id=&semester_id=&day=&interval_id=&course_id="
+func (app *application) removeScheduleSlotHandler(c *gin.Context) {
+
+	slot, ok := app.readSlotQuery(c)
+	if !ok {
+		return
+	}
+
+	change, err := app.models.Schedule.RemoveSlot(slot)
+	if err != nil {
+		app.scheduleSlotErrorResponse(c, err, nil)
+		return
+	}
+
+	go app.notifyScheduleChange(change)
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Slot Removed", "The slot has been removed from the schedule."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// notifyScheduleChange mails the change of a slot to the students of the semester
+// and the teachers of the course. It is meant to be run in a goroutine.
+func (app *application) notifyScheduleChange(change *data.SlotChange) {
+
+	slot := change.After
+	if slot == nil {
+		slot = change.Before
+	}
+
+	emails, err := app.models.Schedule.GetSlotAudience(slot.ProgramID, slot.SemesterID, slot.CourseID)
+	if err != nil {
+		log.Println("Error Occured while fetching the audience of a schedule change, ", err)
+		return
+	}
+
+	content := generateScheduleChangeEmail(change)
+
+	for _, email := range emails {
+		mailDetails := MailingContent{from: app.config.Mail.Sender, to: email,
+			subject: "Class Schedule Changed: " + slot.CourseCode,
+			content: content,
+		}
+
+		app.mailHandler.SendMail(&mailDetails)
+	}
+}
+
+// Generate schedule change email content
+func generateScheduleChangeEmail(change *data.SlotChange) string {
+
+	describe := func(s *data.ScheduledSlot) string {
+		text := fmt.Sprintf("%s (%s) on %s at %s", s.CourseTitle, s.CourseCode, s.Day, s.Interval)
+		if s.Description != "" {
+			text += " - " + s.Description
+		}
+		return text
+	}
+
+	var part1 string
+
+	switch {
+	case change.Before == nil:
+		part1 = fmt.Sprintf("A class has been added to your schedule:\n%s", describe(change.After))
+	case change.After == nil:
+		part1 = fmt.Sprintf("A class has been removed from your schedule:\n%s", describe(change.Before))
+	default:
+		part1 = fmt.Sprintf("A class in your schedule has been changed.\nBefore: %s\nNow: %s",
+			describe(change.Before), describe(change.After))
+	}
+
+	part2 := "The rest of your schedule remains the same."
+	part3 := "Much love from OSP team."
+
+	return fmt.Sprintf("%s\n\n%s\n\n%s", part1, part2, part3)
+}
@@ -20,6 +20,12 @@ func (app *application) serve() error {
 	// A new empty gin.Engine
 	router := gin.New()
 
+	// Client ips are used for rate limiting, so the X-Forwarded-For header
+	// is only trusted from the configured proxies. By default gin trusts everyone.
+	if err := router.SetTrustedProxies(app.config.TrustedProxies); err != nil {
+		return err
+	}
+
 	// Using the defaul logger and recovery middleware
 	// They are quite good.
 	// Todo: Change their config later
@@ -90,6 +96,18 @@ func (app *application) serve() error {
 		// courses
 		v1.GET("/courses", app.listCoursesHandler)
 		v1.GET("/courses/:course_code", app.showCourseHandler)
+		v1.POST("/courses", app.limitBodySize, app.isAdmin, app.createCourseHandler)
+		v1.PUT("/courses/:course_code", app.limitBodySize, app.isAdmin, app.updateCourseHandler)
+		v1.DELETE("/courses/:course_code", app.isAdmin, app.deleteCourseHandler)
+		v1.POST("/courses/:course_code/books", app.limitBodySize, app.isAdmin, app.attachCourseBookHandler)
+		v1.DELETE("/courses/:course_code/books/:book_id", app.isAdmin, app.detachCourseBookHandler)
+
+		// books
+		v1.GET("/books", app.listBooksHandler) // search by title, author
+		v1.GET("/books/:book_id", app.showBookHandler)
+		v1.POST("/books", app.limitBodySize, app.isAdmin, app.createBookHandler)
+		v1.PUT("/books/:book_id", app.limitBodySize, app.isAdmin, app.updateBookHandler)
+		v1.DELETE("/books/:book_id", app.isAdmin, app.deleteBookHandler)
 
 		// teacher profiles
 		v1.GET("/profiles", app.listProfilesHandler)
@@ -111,10 +129,20 @@ func (app *application) serve() error {
 		v1.GET("/teachers/:user_id", app.authenticatedUser, app.showTeacherHandler)
 		v1.GET("/superusers/:user_id", app.authenticatedUser, app.showSuperUserHandler)
 
+		// Superuser hierarchy (superusers only)
+		v1.GET("/superusers", app.isAdmin, app.listSuperUsersHandler)
+		v1.POST("/superusers", app.limitBodySize, app.isAdmin, app.createSuperUserHandler)
+		v1.DELETE("/superusers/:user_id", app.isAdmin, app.deleteSuperUserHandler)
+
 		// Change Password
 		v1.POST("/users/:user_id/password", app.authenticatedUser, app.changePasswordHandler)
 
-		// Update student's and teacher's details (business logic is yet to be added )
+		// Reset forgotten password
+		v1.POST("/users/password-reset", app.limitBodySize, app.requestPasswordResetHandler)
+		v1.GET("/users/password", app.checkPasswordResetTokenHandler)
+		v1.PUT("/users/password", app.limitBodySize, app.resetPasswordHandler)
+
+		// Update student's and teacher's details (by themselves or by superusers)
 		v1.POST("/students/:user_id/update", app.authenticatedUser, app.updateStudentHandler)
 		v1.POST("/teachers/:user_id/update", app.authenticatedUser, app.updateTeacherHandler)
 
@@ -124,39 +152,155 @@ func (app *application) serve() error {
 
 		// Activate users
 		v1.GET("/users/activate", app.activateUserHandler)
+		v1.POST("/users/activate/resend", app.limitBodySize, app.resendActivationHandler)
+
+		// Manage user accounts (superusers only)
+		v1.GET("/admin/users", app.isAdmin, app.listUsersHandler)
+		v1.PUT("/admin/users/:user_id/activate", app.isAdmin, app.activateUserByAdminHandler)
+		v1.PUT("/admin/users/:user_id/expire", app.isAdmin, app.expireUserHandler)
+		v1.PUT("/admin/users/:user_id/reactivate", app.isAdmin, app.reactivateUserHandler)
+		v1.DELETE("/admin/users/:user_id", app.isAdmin, app.deleteUserHandler)
+
+		// Assignments of teachers to courses
+		v1.POST("/admin/teacher-courses", app.limitBodySize, app.isAdmin, app.assignTeacherHandler)
+		v1.GET("/admin/teacher-courses", app.isAdmin, app.listAssignmentsHandler) // filters: user_id, course_id, program_id, semester_id, status
+		v1.PUT("/admin/teacher-courses/:user_id/:course_id/renew", app.limitBodySize, app.isAdmin, app.renewAssignmentHandler)
+		v1.DELETE("/admin/teacher-courses/:user_id/:course_id", app.isAdmin, app.endAssignmentHandler)
 
 		// Programs and levels
 		v1.GET("/faculties", app.listFacultiesHandler)
 		v1.GET("/faculties/:faculty_id", app.showFacultyHandler)
+		v1.POST("/faculties", app.limitBodySize, app.isAdmin, app.createFacultyHandler)
+		v1.PUT("/faculties/:faculty_id", app.limitBodySize, app.isAdmin, app.updateFacultyHandler)
+		v1.DELETE("/faculties/:faculty_id", app.isAdmin, app.deleteFacultyHandler)
 
 		v1.GET("/departments", app.listDepartmentsHandler)
 		v1.GET("/departments/:faculty_id", app.showDepartmentsHandler)
+		v1.POST("/departments", app.limitBodySize, app.isAdmin, app.createDepartmentHandler)
+		v1.PUT("/departments/:department_id", app.limitBodySize, app.isAdmin, app.updateDepartmentHandler)
+		v1.DELETE("/departments/:department_id", app.isAdmin, app.deleteDepartmentHandler)
 
 		v1.GET("/programs", app.listProgramsHandler)
 		v1.GET("/programs/:program_id", app.showProgramHandler)
+		v1.POST("/programs", app.limitBodySize, app.isAdmin, app.createProgramHandler)
+		v1.PUT("/programs/:program_id", app.limitBodySize, app.isAdmin, app.updateProgramHandler)
+		v1.DELETE("/programs/:program_id", app.isAdmin, app.deleteProgramHandler)
+		v1.POST("/programs/:program_id/courses", app.limitBodySize, app.isAdmin, app.addProgramCourseHandler)
+		v1.DELETE("/programs/:program_id/courses/:course_id", app.isAdmin, app.removeProgramCourseHandler)
 
 		v1.GET("/levels", app.listLevelsHandler)
 		v1.GET("/semesters", app.listSemestersHandler)
 		v1.GET("/semesters/running", app.listRunningSemestersHandler)
 		v1.POST("/semesters/running", app.isAdmin, app.addRunningSemesterHandler)
+		v1.POST("/semesters/promote", app.isAdmin, app.promoteStudentsHandler)
 
 		// Schedules
 		v1.GET("/days", app.listDaysHandler)
 		v1.GET("/intervals", app.listIntervalsHandler)
 		v1.POST("/schedules", app.isAdmin, app.setScheduleHandler)
 		v1.DELETE("/schedules", app.isAdmin, app.deleteScheduleHandler)
+		v1.POST("/schedules/slots", app.isAdmin, app.addScheduleSlotHandler)
+		v1.PUT("/schedules/slots", app.isAdmin, app.updateScheduleSlotHandler)
+		v1.DELETE("/schedules/slots", app.isAdmin, app.removeScheduleSlotHandler)
 
 		v1.GET("/schedules", app.showScheduleHandler)
 		v1.GET("/teachers/:user_id/schedule", app.isTeacher, app.showTeacherScheduleHandler)
 		v1.GET("/students/:user_id/schedule", app.isStudent, app.showStudentScheduleHandler)
+		v1.GET("/teachers/:user_id/schedule.ics", app.isTeacher, app.showScheduleCalendarHandler)
+		v1.GET("/students/:user_id/schedule.ics", app.isStudent, app.showScheduleCalendarHandler)
+		v1.GET("/teachers/:user_id/schedule/subscription", app.isTeacher, app.showCalendarSubscriptionHandler)
+		v1.GET("/students/:user_id/schedule/subscription", app.isStudent, app.showCalendarSubscriptionHandler)
+		v1.POST("/teachers/:user_id/schedule/subscription", app.isTeacher, app.regenerateCalendarSubscriptionHandler)
+		v1.POST("/students/:user_id/schedule/subscription", app.isStudent, app.regenerateCalendarSubscriptionHandler)
+		v1.GET("/calendars/:user_id/schedule.ics", app.calendarFeedHandler)
+
+		// Admissions
+		v1.POST("/admissions/enquiries", app.limitBodySize, app.submitEnquiryHandler)
+		v1.GET("/admissions/enquiries", app.isAdmin, app.listEnquiriesHandler)
+		v1.POST("/admissions/applicants", app.limitBodySize, app.submitApplicationHandler)
+		v1.GET("/admissions/applicants", app.isAdmin, app.listApplicantsHandler)
+		v1.GET("/admissions/applicants/:applicant_id", app.isAdmin, app.showApplicantHandler)
+		v1.PUT("/admissions/applicants/:applicant_id/status", app.isAdmin, app.setApplicantStatusHandler)
+		v1.POST("/admissions/applicants/:applicant_id/admit", app.isAdmin, app.admitApplicantHandler)
+
+		// Messages
+		v1.POST("/messages", app.limitBodySize, app.authenticatedUser, app.sendMessageHandler)
+		v1.GET("/conversations", app.authenticatedUser, app.listConversationsHandler)
+		v1.GET("/conversations/:conversation_id", app.authenticatedUser, app.showConversationHandler)
+		v1.POST("/conversations/:conversation_id/messages", app.limitBodySize, app.authenticatedUser, app.replyMessageHandler)
+		v1.PUT("/conversations/:conversation_id/read", app.authenticatedUser, app.markConversationReadHandler)
 
 		// Issues
 
 		v1.GET("/issues", app.isAdmin, app.listIssuesHandler)
-		v1.POST("/issues", app.isStudentOrTeacher, app.registerIssueHandler) // For students and teachers
+		v1.POST("/issues", app.limitUploadSize, app.isStudentOrTeacher, app.registerIssueHandler) // For students and teachers
 		v1.GET("/students/:user_id/issues", app.isStudent, app.listStudentIssuesHandler)
 		v1.GET("/teachers/:user_id/issues", app.isTeacher, app.listTeacherIssuesHandler)
 		v1.PUT("/issues/:issue_id", app.isAdmin, app.markIssueAsReadHandler)
+		v1.GET("/issues/:issue_id", app.isAdmin, app.showIssueHandler)
+		v1.PUT("/issues/:issue_id/status", app.limitBodySize, app.isAdmin, app.setIssueStatusHandler)
+		v1.PUT("/issues/:issue_id/classification", app.limitBodySize, app.isAdmin, app.classifyIssueHandler)
+		v1.POST("/issues/:issue_id/replies", app.limitBodySize, app.isAdmin, app.replyIssueHandler)
+		v1.POST("/issues/track", app.limitBodySize, app.trackIssueHandler) // For reporters of anonymous issues
+		v1.GET("/issues/:issue_id/attachments/:attachment_id", app.authenticatedUser, app.showIssueAttachmentHandler)
+		v1.POST("/issues/track/attachments/:attachment_id", app.limitBodySize, app.trackedIssueAttachmentHandler)
+
+		// Attendance
+		v1.POST("/attendance", app.limitBodySize, app.isTeacher, app.markAttendanceHandler)
+		v1.GET("/students/:user_id/attendance", app.isStudent, app.showStudentAttendanceHandler)
+		v1.GET("/teachers/:user_id/attendance", app.isTeacher, app.showTeacherAttendanceHandler)
+
+		// Marks
+		v1.POST("/marks", app.limitBodySize, app.isTeacher, app.uploadMarksHandler) // json or csv
+		v1.GET("/marks", app.authenticatedUser, app.listOfferingMarksHandler)       // For teachers and superusers
+		v1.POST("/marks/lock", app.isAdmin, app.lockMarksHandler)
+		v1.DELETE("/marks/lock", app.isAdmin, app.unlockMarksHandler)
+		v1.GET("/students/:user_id/marks", app.isStudent, app.showStudentMarksHandler)
+
+		// Grades
+		v1.GET("/levels/:level_id/grading-scale", app.showGradingScaleHandler)
+		v1.PUT("/levels/:level_id/grading-scale", app.limitBodySize, app.isAdmin, app.setGradingScaleHandler)
+		v1.GET("/students/:user_id/transcript", app.isStudent, app.showTranscriptHandler)
+
+		// Exam forms
+		v1.POST("/students/:user_id/exam-forms", app.limitBodySize, app.isStudent, app.submitExamFormHandler)
+		v1.GET("/students/:user_id/exam-forms", app.isStudent, app.listStudentExamFormsHandler)
+		v1.GET("/exam-forms", app.isAdmin, app.listExamFormsHandler)
+		v1.GET("/exam-forms/:form_id", app.isAdmin, app.showExamFormHandler)
+		v1.PUT("/exam-forms/:form_id", app.limitBodySize, app.isAdmin, app.reviewExamFormHandler)
+
+		// ID cards
+		v1.GET("/students/:user_id/id-card", app.isStudent, app.showIDCardHandler) // pdf or png (format=png)
+		v1.GET("/id-cards", app.isAdmin, app.listIDCardsHandler)                   // all students of a program and semester
+		v1.GET("/id-cards/verify/:user_id", app.verifyIDCardHandler)
+		v1.POST("/id-cards/:user_id/reissue", app.isAdmin, app.reissueIDCardHandler) // the old card stops verifying
+
+		// Certificates
+		v1.POST("/certificates", app.limitBodySize, app.isAdmin, app.issueCertificateHandler)
+		v1.GET("/certificates", app.isAdmin, app.listCertificatesHandler)
+		v1.GET("/certificates/:serial/pdf", app.authenticatedUser, app.showCertificatePDFHandler) // For students and superusers
+		v1.PUT("/certificates/:serial/revoke", app.isAdmin, app.revokeCertificateHandler)
+		v1.GET("/certificates/verify/:serial", app.verifyCertificateHandler) // Public
+		v1.GET("/students/:user_id/certificates", app.isStudent, app.listStudentCertificatesHandler)
+
+		// Lesson plans
+		v1.GET("/courses/:course_code/lesson-plan", app.authenticatedUser, app.showLessonPlanHandler)
+		v1.PUT("/courses/:course_code/lesson-plan/:week", app.limitBodySize, app.isTeacher, app.setLessonWeekHandler)
+		v1.DELETE("/courses/:course_code/lesson-plan/:week", app.isTeacher, app.deleteLessonWeekHandler)
+		v1.PUT("/courses/:course_code/lesson-plan/:week/completion", app.limitBodySize, app.isTeacher, app.completeLessonWeekHandler)
+		v1.GET("/lesson-plans/status", app.isAdmin, app.listLessonPlanStatusHandler)
+
+		// Library
+		v1.GET("/library/books", app.authenticatedUser, app.listInventoryHandler)
+		v1.GET("/library/books/:book_id/copies", app.authenticatedUser, app.listBookCopiesHandler)
+		v1.POST("/library/books/:book_id/copies", app.limitBodySize, app.isAdmin, app.addBookCopyHandler)
+		v1.DELETE("/library/copies/:copy_id", app.isAdmin, app.withdrawBookCopyHandler)
+		v1.POST("/library/loans", app.limitBodySize, app.isAdmin, app.issueBookCopyHandler)
+		v1.GET("/library/loans", app.isAdmin, app.listLoansHandler) // filters: user_id, status
+		v1.PUT("/library/loans/:loan_id/return", app.isAdmin, app.returnBookCopyHandler)
+		v1.PUT("/library/loans/:loan_id/fine", app.isAdmin, app.payFineHandler)
+		v1.GET("/students/:user_id/loans", app.isStudent, app.listUserLoansHandler)
+		v1.GET("/teachers/:user_id/loans", app.isTeacher, app.listUserLoansHandler)
 
 	}
 
@@ -183,6 +327,23 @@ func (app *application) serve() error {
 
 	}()
 
+	// Ticker to run the daily jobs (overdue book reminders, assignment expiry warnings)
+	dailyTicker := time.NewTicker(24 * time.Hour)
+
+	go func() {
+
+		// Run the jobs once at startup, so that they are not skipped when the
+		// server is restarted more often than daily. They are safe to run again.
+		app.overdueLoanReminder()
+		app.assignmentExpiryWarning()
+
+		for range dailyTicker.C {
+			app.overdueLoanReminder()
+			app.assignmentExpiryWarning()
+		}
+
+	}()
+
 	err := server.ListenAndServe()
 	if err != nil {
 		return err
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// sign returns the hex encoded HMAC-SHA256 signature of the parts joined by ":"
+// using the secret from the config
+func (app *application) sign(parts ...string) string {
+
+	mac := hmac.New(sha256.New, []byte(app.config.Secret))
+	mac.Write([]byte(strings.Join(parts, ":")))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validSignature checks the signature of the parts in constant time
+func (app *application) validSignature(signature string, parts ...string) bool {
+	return hmac.Equal([]byte(signature), []byte(app.sign(parts...)))
+}
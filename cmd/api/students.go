@@ -1,12 +1,14 @@
 package main
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/roshanlc/soe-backend/internal/data"
+	"github.com/roshanlc/soe-backend/internal/validator"
 )
 
 // This retrieves student Details
@@ -41,18 +43,132 @@ func (app *application) showStudentHandler(c *gin.Context) {
 }
 
 // This update student Details
+// A student can update their own name and contact number, superusers can update
+// every field of any student.
 // Handler For POST "/v1/students/:user_id/update"
 func (app *application) updateStudentHandler(c *gin.Context) {
 	// list of errors
-	// var errBox data.ErrorBox
-	// Check if token matches with provided user ID
-	val, _ := app.DoesTokenMatchesUserID(c)
+	var errBox data.ErrorBox
 
-	// If user id does not match with token
-	if !val {
+	userID, ok := app.readIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	token := app.currentToken(c)
+	if token == nil {
+		return
+	}
+
+	role, ok := app.currentRole(c, token)
+	if !ok {
+		return
+	}
+
+	isSuperUser := role == "superuser"
+
+	// Others can only update their own details
+	if !isSuperUser && token.UserID != userID {
+		errBox.Add(data.AuthorizationErrorResponse("You do not have authorization to access this resource."))
+		app.ErrorResponse(c, http.StatusForbidden, errBox)
 		return
 	}
-	// TODO: add logic
+
+	var input data.StudentUpdate
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	// Academic details are only editable by superusers
+	if !isSuperUser && (input.SymbolNo != nil || input.PURegdNo != nil || input.ProgramID != nil || input.SemesterID != nil) {
+		errBox.Add(data.AuthorizationErrorResponse("Only superusers can update symbol_no, pu_regd_no, program_id and semester_id."))
+		app.ErrorResponse(c, http.StatusForbidden, errBox)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateStudentUpdate(v, &input); !v.Valid() {
+		for key := range v.Errors {
+			errBox.Add(data.BadRequestResponse(v.KeyValuePair(key)))
+		}
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	if input.ProgramID != nil {
+		_, err = app.models.Programs.GetProgram(*input.ProgramID)
+		if err != nil {
+			switch err {
+			case data.ErrRecordNotFound:
+				errBox.Add(data.BadRequestResponse("The provided program_id does not exist."))
+				app.ErrorResponse(c, http.StatusBadRequest, errBox)
+				return
+			default:
+				log.Println(err)
+				errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+				app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+				return
+			}
+		}
+	}
+
+	if input.SemesterID != nil {
+		_, err = app.models.Programs.GetSemester(*input.SemesterID)
+		if err != nil {
+			switch err {
+			case data.ErrRecordNotFound:
+				errBox.Add(data.BadRequestResponse("The provided semester_id does not exist."))
+				app.ErrorResponse(c, http.StatusBadRequest, errBox)
+				return
+			default:
+				log.Println(err)
+				errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+				app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+				return
+			}
+		}
+	}
+
+	err = app.models.Users.UpdateStudent(userID, &input)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			errBox.Add(data.ResourceNotFoundResponse("The provided user_id is not a student."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		case errors.Is(err, data.ErrEditConflict):
+			errBox.Add(data.CustomErrorResponse("Edit Conflict", "The record was modified by someone else. Please fetch the latest details and try again."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		case errors.Is(err, data.ErrDuplicateEntry):
+			errBox.Add(data.CustomErrorResponse("Duplicate Entry", "The provided symbol_no or pu_regd_no is already registered."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	student, err := app.models.Users.GetStudentDetails(userID)
+
+	if err != nil {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	// Return the updated student
+	c.JSON(http.StatusOK, gin.H{"student": student})
 }
 
 // This returns student's schedule
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/roshanlc/soe-backend/internal/data"
+	"github.com/roshanlc/soe-backend/internal/validator"
 )
 
 // Show teacher handler
@@ -33,3 +35,155 @@ func (app *application) showSuperUserHandler(c *gin.Context) {
 	// Return the teacher obj
 	c.JSON(http.StatusOK, gin.H{"superuser": su})
 }
+
+// listSuperUsersHandler lists all superusers and who added them
+// Handler For GET "/v1/superusers"
+func (app *application) listSuperUsersHandler(c *gin.Context) {
+	// list of errors
+	var errBox data.ErrorBox
+
+	superusers, err := app.models.Users.GetAllSuperUsers()
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecords):
+			c.JSON(http.StatusOK, gin.H{"superusers": nil})
+			return
+		default:
+			app.logger.PrintError(err, nil)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"superusers": superusers})
+}
+
+// createSuperUserHandler lets a superuser add another superuser
+// Handler For POST "/v1/superusers"
+func (app *application) createSuperUserHandler(c *gin.Context) {
+	// list of errors
+	var errBox data.ErrorBox
+
+	var su data.SuperUserRegistration
+
+	// Bind the data
+	err := c.ShouldBindJSON(&su)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	// Validator for email and password
+	v := validator.New()
+
+	data.ValidateEmail(v, su.Email)
+
+	// If errors
+	if !v.Valid() {
+		errBox.Add(data.CustomErrorResponse("Invalid Email", "Please provide a valid email address."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	// Validate password if requirements are ok
+	data.ValidatePasswordPlaintext(v, su.Password)
+
+	if !v.Valid() {
+		errBox.Add(data.CustomErrorResponse("Invalid Password", v.KeyValuePair("password")))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	// The superuser performing the request
+	token := app.currentToken(c)
+	if token == nil {
+		return
+	}
+
+	// Convert plain password into hash
+	pw := data.Password{}
+	err = pw.Set(su.Password)
+
+	if err != nil {
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	// Replace the plain password with hash
+	su.Password = pw.Hash()
+
+	err = app.models.Users.RegisterSuperUser(&su, token.UserID)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			errBox.Add(data.CustomErrorResponse("Duplicate Email", "The provided email is already registered."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		default:
+			app.logger.PrintError(err, nil)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Superuser Created", "The superuser account was created successfully."))
+
+	c.JSON(http.StatusCreated, gin.H{"messages": msgBox})
+}
+
+// deleteSuperUserHandler removes a superuser, the main superuser cannot be removed
+// Handler For DELETE "/v1/superusers/:user_id"
+func (app *application) deleteSuperUserHandler(c *gin.Context) {
+	// list of errors
+	var errBox data.ErrorBox
+
+	userID, ok := app.readIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	// The superuser performing the request
+	token := app.currentToken(c)
+	if token == nil {
+		return
+	}
+
+	// A superuser cannot remove themselves
+	if token.UserID == userID {
+		errBox.Add(data.CustomErrorResponse("Operation Not Permitted", "You cannot remove your own superuser account."))
+		app.ErrorResponse(c, http.StatusConflict, errBox)
+		return
+	}
+
+	err := app.models.Users.DeleteSuperUser(userID)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			errBox.Add(data.ResourceNotFoundResponse("The provided user_id is not a superuser."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		case errors.Is(err, data.ErrNotPermitted):
+			errBox.Add(data.CustomErrorResponse("Operation Not Permitted", "The main superuser cannot be removed."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		default:
+			app.logger.PrintError(err, nil)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Superuser Removed", "The superuser account was removed successfully."))
+
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
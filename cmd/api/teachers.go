@@ -1,12 +1,14 @@
 package main
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/roshanlc/soe-backend/internal/data"
+	"github.com/roshanlc/soe-backend/internal/validator"
 )
 
 // Show teacher handler
@@ -37,18 +39,84 @@ func (app *application) showTeacherHandler(c *gin.Context) {
 }
 
 // This update teacher Details
+// A teacher can update their own details, superusers can update any teacher.
 // Handler For POST "/v1/teachers/:user_id/update"
 func (app *application) updateTeacherHandler(c *gin.Context) {
 	// list of errors
-	// var errBox data.ErrorBox
-	// Check if token matches with provided user ID
-	val, _ := app.DoesTokenMatchesUserID(c)
+	var errBox data.ErrorBox
 
-	// If user id does not match with token
-	if !val {
+	userID, ok := app.readIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	token := app.currentToken(c)
+	if token == nil {
+		return
+	}
+
+	role, ok := app.currentRole(c, token)
+	if !ok {
+		return
+	}
+
+	// Others can only update their own details
+	if role != "superuser" && token.UserID != userID {
+		errBox.Add(data.AuthorizationErrorResponse("You do not have authorization to access this resource."))
+		app.ErrorResponse(c, http.StatusForbidden, errBox)
+		return
+	}
+
+	var input data.TeacherUpdate
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateTeacherUpdate(v, &input); !v.Valid() {
+		for key := range v.Errors {
+			errBox.Add(data.BadRequestResponse(v.KeyValuePair(key)))
+		}
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	err = app.models.Users.UpdateTeacher(userID, &input)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			errBox.Add(data.ResourceNotFoundResponse("The provided user_id is not a teacher."))
+			app.ErrorResponse(c, http.StatusNotFound, errBox)
+			return
+		case errors.Is(err, data.ErrEditConflict):
+			errBox.Add(data.CustomErrorResponse("Edit Conflict", "The record was modified by someone else. Please fetch the latest details and try again."))
+			app.ErrorResponse(c, http.StatusConflict, errBox)
+			return
+		default:
+			log.Println(err)
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	teacher, err := app.models.Users.GetTeacherDetails(userID)
+
+	if err != nil {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
 		return
 	}
-	// TODO: add logic
+
+	// Return the updated teacher
+	c.JSON(http.StatusOK, gin.H{"teacher": teacher})
 }
 
 func (app *application) listTeacherIssuesHandler(c *gin.Context) {
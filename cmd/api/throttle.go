@@ -0,0 +1,51 @@
+// This file contains a simple in-memory throttle which limits
+// how often an action can be performed for a key (email, ip address...)
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// throttle remembers the last time an action was allowed for a key
+type throttle struct {
+	mu       sync.Mutex
+	interval time.Duration        // minimum gap between two allowed actions
+	last     map[string]time.Time // key and the time it was last allowed
+}
+
+// newThrottle returns a throttle allowing one action per interval for a key
+func newThrottle(interval time.Duration) *throttle {
+	return &throttle{
+		interval: interval,
+		last:     make(map[string]time.Time),
+	}
+}
+
+// allow reports whether the action for a key is allowed right now.
+// If allowed, the current time is recorded for that key.
+func (t *throttle) allow(key string) bool {
+
+	// keys are case insensitive, i.e. emails
+	key = strings.ToLower(key)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+
+	// Remove stale keys so that the map does not grow forever
+	for k, v := range t.last {
+		if now.Sub(v) >= t.interval {
+			delete(t.last, k)
+		}
+	}
+
+	if _, exists := t.last[key]; exists {
+		return false
+	}
+
+	t.last[key] = now
+	return true
+}
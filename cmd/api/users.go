@@ -4,11 +4,15 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/roshanlc/soe-backend/internal/data"
+	"github.com/roshanlc/soe-backend/internal/validator"
 )
 
 // struct to read passwords
@@ -17,6 +21,18 @@ type Pass struct {
 	NewPassword string `json:"new_password"`
 }
 
+// struct to read email for password reset request
+type PassResetRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// struct to read password reset token and new password
+// The token can also be provided as the token query string of the mailed link
+type PassReset struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
 // This retrieves user's basic details
 // Handler for GET "/v1/users/:user_id"
 func (app *application) showUserHandler(c *gin.Context) {
@@ -41,6 +57,15 @@ func (app *application) showUserHandler(c *gin.Context) {
 		return
 	}
 
+	user.UnreadMessages, user.UnreadConversations, err = app.models.Messages.GetUnreadCounts(token.UserID)
+
+	if err != nil {
+		log.Println(err)
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing the request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
 	// Return the student
 	c.JSON(http.StatusOK, gin.H{"user": user})
 
@@ -188,3 +213,226 @@ func (app *application) changePasswordHandler(c *gin.Context) {
 	msgBox.Add(data.MessageResponse("Password Changed", "The password was changed successfully."))
 	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
 }
+
+// requestPasswordResetHandler mails a password reset link to the user
+// Handler for POST "/v1/users/password-reset"
+func (app *application) requestPasswordResetHandler(c *gin.Context) {
+	// list of errors
+	var errBox data.ErrorBox
+
+	var input PassResetRequest
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	v := validator.New()
+
+	data.ValidateEmail(v, input.Email)
+
+	// If errors
+	if !v.Valid() {
+		errBox.Add(data.CustomErrorResponse("Invalid Email", "Please provide a valid email address."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	// Only one reset email per email is allowed within the throttle interval
+	if !app.resetThrottle.allow(input.Email) {
+		errBox.Add(data.CustomErrorResponse("Too Many Requests", "A password reset was requested recently. Please wait a few minutes before trying again."))
+		app.ErrorResponse(c, http.StatusTooManyRequests, errBox)
+		return
+	}
+
+	// The same message is returned whether the email exists or not,
+	// so that this endpoint cannot be used to find registered emails
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Password Reset Requested", "If the email is registered, a password reset link has been sent to it. The link is valid for 45 minutes."))
+
+	user, err := app.models.Users.GetByEmail(input.Email)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			c.JSON(http.StatusAccepted, gin.H{"messages": msgBox})
+			return
+		default:
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing this request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	// Expired accounts cannot be recovered through password reset
+	if user.Expired {
+		c.JSON(http.StatusAccepted, gin.H{"messages": msgBox})
+		return
+	}
+
+	// Remove any previous reset tokens, only the latest one should work
+	err = app.models.Tokens.DeleteAllForUser(data.ScopePasswordReset, user.UserID)
+
+	if err != nil {
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing this request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	token, err := app.models.Tokens.NewPasswordResetToken(user.UserID, 45*time.Minute)
+
+	if err != nil {
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing this request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	mailDetails := MailingContent{from: app.config.Mail.Sender, to: user.Email,
+		subject: "Reset Your Student Portal Password",
+		content: generatePasswordResetEmail(app.config.Domain + "/v1/users/password?token=" + token.Plaintext),
+	}
+
+	go app.mailHandler.SendMail(&mailDetails)
+
+	c.JSON(http.StatusAccepted, gin.H{"messages": msgBox})
+}
+
+// checkPasswordResetTokenHandler reports whether the token of a mailed password reset link is valid
+// Handler for GET "/v1/users/password?token="
+func (app *application) checkPasswordResetTokenHandler(c *gin.Context) {
+	// list of errors
+	var errBox data.ErrorBox
+
+	tokenVal, exists := c.GetQuery("token")
+
+	if !exists {
+		errBox.Add(data.BadRequestResponse("A query paramter (\"token\") is missing."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	_, err := app.models.Tokens.GetForToken(data.ScopePasswordReset, tokenVal)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			errBox.Add(data.CustomErrorResponse("Invalid Token", "Invalid or expired password reset token."))
+			app.ErrorResponse(c, http.StatusUnprocessableEntity, errBox)
+			return
+		default:
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing this request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Valid Token", "The password reset link is valid. Send the new password with PUT to this link to set it."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// resetPasswordHandler sets a new password using a password reset token
+// Handler for PUT "/v1/users/password" and PUT "/v1/users/password?token="
+func (app *application) resetPasswordHandler(c *gin.Context) {
+	// list of errors
+	var errBox data.ErrorBox
+
+	var input PassReset
+
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		errBox.Add(data.BadRequestResponse(err.Error()))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	// The token of the mailed link
+	if input.Token == "" {
+		input.Token = c.Query("token")
+	}
+
+	if input.Token == "" {
+		errBox.Add(data.BadRequestResponse("Please provide the password reset token."))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	v := validator.New()
+
+	// Validate password if requirements are ok
+	data.ValidatePasswordPlaintext(v, input.NewPassword)
+
+	if !v.Valid() {
+		errBox.Add(data.CustomErrorResponse("Invalid Password", v.KeyValuePair("password")))
+		app.ErrorResponse(c, http.StatusBadRequest, errBox)
+		return
+	}
+
+	token, err := app.models.Tokens.GetForToken(data.ScopePasswordReset, input.Token)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			errBox.Add(data.CustomErrorResponse("Invalid Token", "Invalid or expired password reset token."))
+			app.ErrorResponse(c, http.StatusUnprocessableEntity, errBox)
+			return
+		default:
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing this request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	// new password hash
+	newPass := data.Password{}
+	err = newPass.Set(input.NewPassword)
+
+	if err != nil {
+		errBox.Add(data.InternalServerErrorResponse("The server had problems while processing this request."))
+		app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+		return
+	}
+
+	err = app.models.Users.ChangePassword(token.UserID, newPass.Hash())
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNotUpdated):
+			errBox.Add(data.CustomErrorResponse("Password Not Updated", "The password could not be updated"))
+			app.ErrorResponse(c, http.StatusBadRequest, errBox)
+			return
+		default:
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing this request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	// The reset token is used up, and all logged in sessions are revoked
+	for _, scope := range []string{data.ScopePasswordReset, data.ScopeAuthentication} {
+
+		err = app.models.Tokens.DeleteAllForUser(scope, token.UserID)
+
+		if err != nil {
+			errBox.Add(data.InternalServerErrorResponse("The server had problems while processing this request."))
+			app.ErrorResponse(c, http.StatusInternalServerError, errBox)
+			return
+		}
+	}
+
+	var msgBox data.MessageBox
+	msgBox.Add(data.MessageResponse("Password Reset", "The password was reset successfully. Please login with the new password."))
+	c.JSON(http.StatusOK, gin.H{"messages": msgBox})
+}
+
+// Generate password reset email content
+func generatePasswordResetEmail(link string) string {
+
+	part1 := "A password reset was requested for your Online Student Portal account. Please use the following link to set a new password."
+	part2 := "The link is valid for 45 minutes. If you did not request a password reset, you can safely ignore this email."
+	part3 := "Much love from OSP team."
+
+	return fmt.Sprintf("%s\n%v\n\n%s\n\n%s", part1, link, part2, part3)
+}
@@ -13,9 +13,12 @@ require (
 require github.com/gin-contrib/cors v1.3.1
 
 require (
+	github.com/boombuler/barcode v1.0.1
+	github.com/go-pdf/fpdf v0.6.0
 	github.com/golang-migrate/migrate v3.5.4+incompatible // indirect
 	github.com/jfeliu007/goplantuml v1.6.1 // indirect
 	github.com/spf13/afero v1.8.2 // indirect
+	golang.org/x/image v0.24.0
 )
 
 require (
@@ -33,7 +36,7 @@ require (
 	github.com/ugorji/go/codec v1.2.7 // indirect
 	github.com/wneessen/go-mail v0.2.4
 	golang.org/x/sys v0.0.0-20220520151302-bc2c85ada10a // indirect
-	golang.org/x/text v0.3.7 // indirect
+	golang.org/x/text v0.22.0 // indirect
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 	google.golang.org/protobuf v1.28.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
@@ -0,0 +1,372 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Statuses of the admission pipeline of an applicant
+const (
+	ApplicantReceived    = "received"
+	ApplicantShortlisted = "shortlisted"
+	ApplicantAdmitted    = "admitted"
+	ApplicantRejected    = "rejected"
+)
+
+type AdmissionModel struct {
+	DB *sql.DB
+}
+
+// Struct to read an enquiry about a program from the public
+type EnquiryInput struct {
+	ProgramID int    `json:"program_id" binding:"required,min=1"`
+	Name      string `json:"name" binding:"required,max=100"`
+	Email     string `json:"email" binding:"required,max=200"`
+	ContactNo string `json:"contact_no" binding:"required,max=20"`
+	Message   string `json:"message" binding:"required,max=2000"`
+}
+
+// An enquiry about a program
+type Enquiry struct {
+	EnquiryID   int64     `json:"enquiry_id"`
+	ProgramID   int       `json:"program_id"`
+	Program     string    `json:"program"`
+	Name        string    `json:"name"`
+	Email       string    `json:"email"`
+	ContactNo   string    `json:"contact_no"`
+	Message     string    `json:"message"`
+	SubmittedAt time.Time `json:"submitted_at"`
+}
+
+// Struct to read an application for admission into a program
+type ApplicantInput struct {
+	ProgramID     int    `json:"program_id" binding:"required,min=1"`
+	Name          string `json:"name" binding:"required,max=100"`
+	Email         string `json:"email" binding:"required,max=200"`
+	ContactNo     string `json:"contact_no" binding:"required,max=20"`
+	Qualification string `json:"qualification" binding:"required,max=500"`
+	Message       string `json:"message" binding:"max=2000"`
+}
+
+// An applicant for admission into a program
+type Applicant struct {
+	ApplicantID   int64     `json:"applicant_id"`
+	ProgramID     int       `json:"program_id"`
+	Program       string    `json:"program"`
+	Name          string    `json:"name"`
+	Email         string    `json:"email"`
+	ContactNo     string    `json:"contact_no"`
+	Qualification string    `json:"qualification"`
+	Message       string    `json:"message"`
+	Status        string    `json:"status"`
+	Remarks       string    `json:"remarks"`
+	UserID        *int64    `json:"user_id"` // student account of an admitted applicant
+	SubmittedAt   time.Time `json:"submitted_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Struct to read a change of the status of an applicant
+type ApplicantStatusInput struct {
+	Status  string `json:"status" binding:"required,oneof=received shortlisted rejected"`
+	Remarks string `json:"remarks" binding:"max=2000"`
+}
+
+// Struct to read the student details of an applicant being admitted
+type AdmitInput struct {
+	SymbolNo   int64  `json:"symbol_no" binding:"required,min=1"`
+	PURegdNo   string `json:"pu_regd_no" binding:"required"`
+	Semester   int    `json:"semester" binding:"required,min=1,max=8"`
+	EnrolledAt string `json:"enrolled_at"` // yyyy-mm-dd, today if empty
+}
+
+// Filters to list enquiries
+type EnquiryFilters struct {
+	ProgramID int
+	Filters
+}
+
+// Filters to list applicants
+type ApplicantFilters struct {
+	ProgramID int
+	Status    string
+	Filters
+}
+
+// InsertEnquiry adds an enquiry about a program
+func (m AdmissionModel) InsertEnquiry(input *EnquiryInput) (int64, error) {
+
+	query := `INSERT INTO enquiries (program_id, name, email, contact_no, message)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING enquiry_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var enquiryID int64
+
+	err := m.DB.QueryRowContext(ctx, query, input.ProgramID, strings.TrimSpace(input.Name), strings.TrimSpace(input.Email),
+		strings.TrimSpace(input.ContactNo), strings.TrimSpace(input.Message)).Scan(&enquiryID)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "enquiries_program_id_fkey"):
+			return 0, ErrInvalidReference
+		default:
+			return 0, err
+		}
+	}
+
+	return enquiryID, nil
+}
+
+// GetEnquiries returns a page of the enquiries, latest first
+func (m AdmissionModel) GetEnquiries(filters EnquiryFilters) ([]Enquiry, Metadata, error) {
+
+	query := `SELECT COUNT(*) OVER(), enquiries.enquiry_id, enquiries.program_id, programs.name,
+	enquiries.name, enquiries.email, enquiries.contact_no, enquiries.message, enquiries.submitted_at
+	FROM enquiries
+	INNER JOIN programs ON programs.program_id = enquiries.program_id
+	WHERE (enquiries.program_id = $1 OR $1 = 0)
+	ORDER BY enquiries.enquiry_id DESC
+	LIMIT $2 OFFSET $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, filters.ProgramID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	enquiries := []Enquiry{}
+
+	for rows.Next() {
+		var temp Enquiry
+
+		err := rows.Scan(&totalRecords, &temp.EnquiryID, &temp.ProgramID, &temp.Program, &temp.Name,
+			&temp.Email, &temp.ContactNo, &temp.Message, &temp.SubmittedAt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		enquiries = append(enquiries, temp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return enquiries, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// InsertApplicant adds an application for admission into a program
+// An email can have only one application for a program which is not rejected
+func (m AdmissionModel) InsertApplicant(input *ApplicantInput) (int64, error) {
+
+	query := `INSERT INTO applicants (program_id, name, email, contact_no, qualification, message)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING applicant_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var applicantID int64
+
+	err := m.DB.QueryRowContext(ctx, query, input.ProgramID, strings.TrimSpace(input.Name),
+		strings.TrimSpace(input.Email), strings.TrimSpace(input.ContactNo), strings.TrimSpace(input.Qualification),
+		strings.TrimSpace(input.Message)).Scan(&applicantID)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "applicants_open_email_key"):
+			return 0, ErrDuplicateEntry
+		case strings.Contains(err.Error(), "applicants_program_id_fkey"):
+			return 0, ErrInvalidReference
+		default:
+			return 0, err
+		}
+	}
+
+	return applicantID, nil
+}
+
+// Common query to select applicants
+const applicantQuery = `SELECT COUNT(*) OVER(), applicants.applicant_id, applicants.program_id, programs.name,
+	applicants.name, applicants.email, applicants.contact_no, applicants.qualification, applicants.message,
+	applicants.status, applicants.remarks, applicants.user_id, applicants.submitted_at, applicants.updated_at
+	FROM applicants
+	INNER JOIN programs ON programs.program_id = applicants.program_id `
+
+// listApplicants returns the applicants selected by a query built on applicantQuery
+func (m AdmissionModel) listApplicants(query string, args ...interface{}) ([]Applicant, int, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	applicants := []Applicant{}
+
+	for rows.Next() {
+		var temp Applicant
+
+		err := rows.Scan(&totalRecords, &temp.ApplicantID, &temp.ProgramID, &temp.Program, &temp.Name,
+			&temp.Email, &temp.ContactNo, &temp.Qualification, &temp.Message, &temp.Status, &temp.Remarks,
+			&temp.UserID, &temp.SubmittedAt, &temp.UpdatedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		applicants = append(applicants, temp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return applicants, totalRecords, nil
+}
+
+// GetApplicants returns a page of the applicants matching the filters, latest first
+func (m AdmissionModel) GetApplicants(filters ApplicantFilters) ([]Applicant, Metadata, error) {
+
+	query := applicantQuery + `WHERE (applicants.program_id = $1 OR $1 = 0)
+	AND (applicants.status = $2 OR $2 = '')
+	ORDER BY applicants.applicant_id DESC
+	LIMIT $3 OFFSET $4`
+
+	applicants, totalRecords, err := m.listApplicants(query, filters.ProgramID, filters.Status, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return applicants, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// GetApplicant returns an applicant
+func (m AdmissionModel) GetApplicant(applicantID int64) (*Applicant, error) {
+
+	applicants, _, err := m.listApplicants(applicantQuery+`WHERE applicants.applicant_id = $1`, applicantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(applicants) == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	return &applicants[0], nil
+}
+
+// SetStatus moves an applicant to the received, shortlisted or rejected status
+// The status of an admitted applicant can not be changed
+func (m AdmissionModel) SetStatus(applicantID int64, input *ApplicantStatusInput) error {
+
+	query := `UPDATE applicants SET status = $2, remarks = $3, updated_at = NOW()
+	WHERE applicant_id = $1 AND status <> 'admitted'`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, applicantID, input.Status, strings.TrimSpace(input.Remarks))
+	if err != nil {
+		switch {
+		// reopening a rejected application while another one is open
+		case strings.Contains(err.Error(), "applicants_open_email_key"):
+			return ErrDuplicateEntry
+		default:
+			return err
+		}
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		// Find out whether the applicant is missing or admitted
+		if _, err := m.GetApplicant(applicantID); err != nil {
+			return err
+		}
+		return ErrNotPermitted
+	}
+
+	return nil
+}
+
+// Admit registers the student account of a shortlisted applicant and marks the applicant as admitted,
+// all in a single transaction. It returns the user_id of the student.
+// ErrNotPermitted is returned if the applicant is not shortlisted, ErrDuplicateEntry if the
+// symbol no or the pu regd no already belongs to a student and ErrDuplicateEmail if the email is registered.
+func (m AdmissionModel) Admit(applicantID int64, student *StudentRegistration) (int64, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Lock the applicant, so that its status can not change until the admission is done
+	var status string
+
+	err = tx.QueryRowContext(ctx, `SELECT status FROM applicants WHERE applicant_id = $1 FOR UPDATE`, applicantID).Scan(&status)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	if status != ApplicantShortlisted {
+		return 0, ErrNotPermitted
+	}
+
+	var exists bool
+
+	query := `SELECT EXISTS (SELECT 1 FROM students WHERE symbol_no = $1 OR pu_regd_no = $2)`
+
+	err = tx.QueryRowContext(ctx, query, student.SymbolNo, student.PURegdNo).Scan(&exists)
+	if err != nil {
+		return 0, err
+	}
+
+	if exists {
+		return 0, ErrDuplicateEntry
+	}
+
+	// The student is registered the same way as a student added by a superuser
+	userID, err := registerStudent(ctx, tx, student)
+	if err != nil {
+		switch {
+		// a student added by someone else in the meantime
+		case strings.Contains(err.Error(), "students_symbol_no_key"), strings.Contains(err.Error(), "students_pu_regd_no_key"):
+			return 0, ErrDuplicateEntry
+		default:
+			return 0, err
+		}
+	}
+
+	query = `UPDATE applicants SET status = 'admitted', user_id = $2, updated_at = NOW()
+	WHERE applicant_id = $1`
+
+	_, err = tx.ExecContext(ctx, query, applicantID, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, tx.Commit()
+}
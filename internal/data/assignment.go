@@ -0,0 +1,287 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	AssignmentMonths  = 6  // Default number of months a teacher is assigned to a course
+	ExpiryWarningDays = 14 // Superusers are warned this many days before an assignment expires
+)
+
+// Incase the provided user is not a teacher
+var ErrInvalidTeacher = errors.New("user is not a teacher")
+
+type AssignmentModel struct {
+	DB *sql.DB
+}
+
+// Assignment of a teacher to a course (teacher_courses)
+type Assignment struct {
+	TeacherID   int64     `json:"teacher_id"`
+	UserID      int64     `json:"user_id"`
+	Teacher     string    `json:"teacher"`
+	CourseID    int       `json:"course_id"`
+	CourseCode  string    `json:"course_code"`
+	CourseTitle string    `json:"course_title"`
+	ProgramID   int       `json:"program_id"`
+	Program     string    `json:"program"`
+	SemesterID  int       `json:"semester_id"`
+	AssignedAt  time.Time `json:"assigned_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Expired     bool      `json:"expired"`
+}
+
+// Struct to read the assignment of a teacher to a course offered in a program and semester
+type AssignmentInput struct {
+	UserID     int64  `json:"user_id" binding:"required,min=1"`
+	ProgramID  int    `json:"program_id" binding:"required,min=1"`
+	SemesterID int    `json:"semester_id" binding:"required,min=1"`
+	CourseID   int    `json:"course_id" binding:"required,min=1"`
+	ExpiresAt  string `json:"expires_at"` // yyyy-mm-dd, AssignmentMonths from today if empty
+}
+
+// Struct to read the renewal of an assignment
+type AssignmentRenewal struct {
+	ExpiresAt string `json:"expires_at"` // yyyy-mm-dd, AssignmentMonths from the current expiry if empty
+}
+
+// Filters to list assignments
+type AssignmentFilters struct {
+	UserID     int64
+	CourseID   int
+	ProgramID  int
+	SemesterID int
+	Status     string // current or expired
+}
+
+// teacherIDByUserID returns the teacher_id of a teacher's user account
+func teacherIDByUserID(ctx context.Context, tx *sql.Tx, userID int64) (int64, error) {
+
+	var teacherID int64
+
+	err := tx.QueryRowContext(ctx, `SELECT teacher_id FROM teachers WHERE user_id = $1`, userID).Scan(&teacherID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrInvalidTeacher
+		default:
+			return 0, err
+		}
+	}
+
+	return teacherID, nil
+}
+
+// Assign assigns a teacher to a course offered in a program and semester
+// An expired assignment of the same course is replaced
+func (m AssignmentModel) Assign(input *AssignmentInput, expiresAt time.Time) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	teacherID, err := teacherIDByUserID(ctx, tx, input.UserID)
+	if err != nil {
+		return err
+	}
+
+	offered, err := isOffered(ctx, tx, &Offering{ProgramID: input.ProgramID, SemesterID: input.SemesterID, CourseID: input.CourseID})
+	if err != nil {
+		return err
+	}
+
+	if !offered {
+		return ErrInvalidCourse
+	}
+
+	query := `INSERT INTO teacher_courses (teacher_id, course_id, expires_at)
+	VALUES ($1, $2, $3)
+	ON CONFLICT ON CONSTRAINT teacher_courses_pkey DO UPDATE
+	SET assigned_at = CURRENT_DATE, expires_at = EXCLUDED.expires_at, expiry_warned = false
+	WHERE teacher_courses.expires_at < CURRENT_DATE`
+
+	result, err := tx.ExecContext(ctx, query, teacherID, input.CourseID, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// A current assignment already exists
+	if affected == 0 {
+		return ErrDuplicateEntry
+	}
+
+	return tx.Commit()
+}
+
+// Renew extends an assignment till the provided date,
+// or by AssignmentMonths from its expiry (or today if already expired) if expiresAt is nil
+func (m AssignmentModel) Renew(userID int64, courseID int, expiresAt *time.Time) error {
+
+	query := `UPDATE teacher_courses
+	SET expires_at = COALESCE($3, GREATEST(expires_at, CURRENT_DATE) + make_interval(months => $4)),
+	expiry_warned = false
+	WHERE teacher_id = (SELECT teacher_id FROM teachers WHERE user_id = $1) AND course_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, courseID, expiresAt, AssignmentMonths)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// End ends a current assignment, it is kept as an expired assignment
+func (m AssignmentModel) End(userID int64, courseID int) error {
+
+	query := `UPDATE teacher_courses SET expires_at = CURRENT_DATE - 1
+	WHERE teacher_id = (SELECT teacher_id FROM teachers WHERE user_id = $1) AND course_id = $2
+	AND expires_at >= CURRENT_DATE`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, courseID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Common query to select assignments
+const assignmentQuery = `SELECT teachers.teacher_id, COALESCE(teachers.user_id, 0), teachers.name,
+	courses.course_id, courses.course_code, courses.title,
+	COALESCE(program_courses.program_id, 0), COALESCE(programs.name, ''), COALESCE(program_courses.semester_id, 0),
+	teacher_courses.assigned_at, teacher_courses.expires_at, teacher_courses.expires_at < CURRENT_DATE
+	FROM teacher_courses
+	INNER JOIN teachers ON teachers.teacher_id = teacher_courses.teacher_id
+	INNER JOIN courses ON courses.course_id = teacher_courses.course_id
+	LEFT JOIN program_courses ON program_courses.course_id = courses.course_id
+	LEFT JOIN programs ON programs.program_id = program_courses.program_id `
+
+// listAssignments returns the assignments selected by a query built on assignmentQuery
+func (m AssignmentModel) listAssignments(query string, args ...interface{}) (*[]Assignment, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	assignments := []Assignment{}
+
+	for rows.Next() {
+		var temp Assignment
+
+		err := rows.Scan(&temp.TeacherID, &temp.UserID, &temp.Teacher, &temp.CourseID, &temp.CourseCode,
+			&temp.CourseTitle, &temp.ProgramID, &temp.Program, &temp.SemesterID, &temp.AssignedAt,
+			&temp.ExpiresAt, &temp.Expired)
+		if err != nil {
+			return nil, err
+		}
+
+		assignments = append(assignments, temp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(assignments) == 0 {
+		return &assignments, ErrNoRecords
+	}
+
+	return &assignments, nil
+}
+
+// GetAssignments returns the assignments matching the filters
+func (m AssignmentModel) GetAssignments(filters AssignmentFilters) (*[]Assignment, error) {
+
+	query := assignmentQuery + `WHERE (teachers.user_id = $1 OR $1 = 0)
+	AND (teacher_courses.course_id = $2 OR $2 = 0)
+	AND (program_courses.program_id = $3 OR $3 = 0)
+	AND (program_courses.semester_id = $4 OR $4 = 0)
+	AND CASE $5
+		WHEN 'current' THEN teacher_courses.expires_at >= CURRENT_DATE
+		WHEN 'expired' THEN teacher_courses.expires_at < CURRENT_DATE
+		ELSE true
+	END
+	ORDER BY teacher_courses.expires_at, teachers.name`
+
+	return m.listAssignments(query, filters.UserID, filters.CourseID, filters.ProgramID,
+		filters.SemesterID, filters.Status)
+}
+
+// GetUnwarnedExpiringAssignments returns the current assignments expiring within
+// ExpiryWarningDays of which superusers have not been warned yet
+func (m AssignmentModel) GetUnwarnedExpiringAssignments() (*[]Assignment, error) {
+
+	query := assignmentQuery + `WHERE teacher_courses.expires_at >= CURRENT_DATE
+	AND teacher_courses.expires_at <= CURRENT_DATE + $1::integer
+	AND NOT teacher_courses.expiry_warned
+	ORDER BY teacher_courses.expires_at, teachers.name`
+
+	return m.listAssignments(query, ExpiryWarningDays)
+}
+
+// MarkWarned records that superusers have been warned of the expiry of the assignments
+func (m AssignmentModel) MarkWarned(assignments *[]Assignment) error {
+
+	query := `UPDATE teacher_courses SET expiry_warned = true WHERE teacher_id = $1 AND course_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, assignment := range *assignments {
+		_, err = tx.ExecContext(ctx, query, assignment.TeacherID, assignment.CourseID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,344 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Incase a marked student does not belong to the program and semester of the slot
+var ErrInvalidStudent = errors.New("student does not belong to the program and semester")
+
+type AttendanceModel struct {
+	DB *sql.DB
+}
+
+// Attendance status of a single student
+type AttendanceMark struct {
+	StudentID int64  `json:"student_id" binding:"required"`
+	Status    string `json:"status" binding:"required,oneof=present absent late"`
+}
+
+// Struct to read attendance of a class from a teacher
+// The slot (program_id, semester_id, day, interval_id, course_id) must exist in day_schedule
+type AttendanceInput struct {
+	ProgramID  int              `json:"program_id" binding:"required"`
+	SemesterID int              `json:"semester_id" binding:"required"`
+	Day        string           `json:"day" binding:"required"`
+	IntervalID int              `json:"interval_id" binding:"required"`
+	CourseID   int              `json:"course_id" binding:"required"`
+	Date       string           `json:"date" binding:"required"` // YYYY-MM-DD
+	Records    []AttendanceMark `json:"records" binding:"required,min=1,dive"`
+}
+
+// Attendance of a student in a course
+type CourseAttendance struct {
+	CourseID     int     `json:"course_id"`
+	CourseCode   string  `json:"course_code"`
+	CourseTitle  string  `json:"course_title"`
+	SessionsHeld int     `json:"sessions_held"`
+	Present      int     `json:"present"`
+	Late         int     `json:"late"`
+	Absent       int     `json:"absent"`
+	Percentage   float64 `json:"percentage"`
+}
+
+// Attendance of a student as seen by a teacher
+type StudentAttendance struct {
+	UserID     int64   `json:"user_id"`
+	StudentID  int64   `json:"student_id"`
+	Name       string  `json:"name"`
+	SymbolNo   int64   `json:"symbol_no"`
+	Present    int     `json:"present"`
+	Late       int     `json:"late"`
+	Absent     int     `json:"absent"`
+	Percentage float64 `json:"percentage"`
+}
+
+// Attendance of a course offering taught by a teacher
+type OfferingAttendance struct {
+	CourseID     int                 `json:"course_id"`
+	CourseCode   string              `json:"course_code"`
+	CourseTitle  string              `json:"course_title"`
+	ProgramID    int                 `json:"program_id"`
+	ProgramName  string              `json:"program_name"`
+	SemesterID   int                 `json:"semester_id"`
+	SessionsHeld int                 `json:"sessions_held"`
+	Students     []StudentAttendance `json:"students"`
+}
+
+// attendancePercentage returns the percentage of attended sessions
+// A late student is counted as attended
+func attendancePercentage(present, late, held int) float64 {
+	if held == 0 {
+		return 0
+	}
+	return roundOff(float64(present+late) * 100 / float64(held))
+}
+
+// teacherOfCourse returns the teacher_id of a user currently teaching the course
+// It returns ErrNotPermitted if the user is not teaching the course
+func teacherOfCourse(ctx context.Context, tx *sql.Tx, userID int64, courseID int) (int64, error) {
+
+	query := `SELECT teachers.teacher_id FROM teachers
+	INNER JOIN teacher_courses ON teacher_courses.teacher_id = teachers.teacher_id
+	WHERE teachers.user_id = $1 AND teacher_courses.course_id = $2
+	AND teacher_courses.expires_at >= CURRENT_DATE`
+
+	var teacherID int64
+	err := tx.QueryRowContext(ctx, query, userID, courseID).Scan(&teacherID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrNotPermitted
+		default:
+			return 0, err
+		}
+	}
+
+	return teacherID, nil
+}
+
+// MarkAttendance records the attendance of a class taken by a teacher
+// Marking the same slot on the same date again overwrites the previous statuses
+func (m AttendanceModel) MarkAttendance(userID int64, input *AttendanceInput, heldOn time.Time) (int64, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// The teacher must currently teach the course
+	teacherID, err := teacherOfCourse(ctx, tx, userID, input.CourseID)
+	if err != nil {
+		return 0, err
+	}
+
+	// The slot must exist in the schedule
+	query := `SELECT EXISTS (SELECT 1 FROM day_schedule
+	WHERE program_id = $1 AND semester_id = $2 AND day = $3 AND interval_id = $4 AND course_id = $5)`
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, query, input.ProgramID, input.SemesterID, input.Day, input.IntervalID, input.CourseID).Scan(&exists)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, ErrRecordNotFound
+	}
+
+	studentIDs := []int64{}
+	statuses := []string{}
+	for _, record := range input.Records {
+		studentIDs = append(studentIDs, record.StudentID)
+		statuses = append(statuses, record.Status)
+	}
+
+	// All the students must belong to the program and semester
+	query = `SELECT COUNT(*) FROM students
+	WHERE program_id = $1 AND semester_id = $2 AND student_id = ANY($3)`
+
+	var count int
+	err = tx.QueryRowContext(ctx, query, input.ProgramID, input.SemesterID, pq.Array(studentIDs)).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	if count != len(studentIDs) {
+		return 0, ErrInvalidStudent
+	}
+
+	query = `INSERT INTO attendance_sessions (program_id, semester_id, day, interval_id, course_id, held_on, teacher_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT ON CONSTRAINT attendance_sessions_slot_key DO UPDATE SET teacher_id = EXCLUDED.teacher_id
+	RETURNING session_id`
+
+	var sessionID int64
+	err = tx.QueryRowContext(ctx, query, input.ProgramID, input.SemesterID, input.Day,
+		input.IntervalID, input.CourseID, heldOn, teacherID).Scan(&sessionID)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `INSERT INTO attendance_records (session_id, student_id, status)
+	SELECT $1, UNNEST($2::bigint[]), UNNEST($3::text[])
+	ON CONFLICT ON CONSTRAINT attendance_records_pkey DO UPDATE SET status = EXCLUDED.status`
+
+	_, err = tx.ExecContext(ctx, query, sessionID, pq.Array(studentIDs), pq.Array(statuses))
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return sessionID, nil
+}
+
+// GetStudentAttendance returns the attendance of a student in the courses of their current semester
+// Sessions the student was not marked in are counted as absent
+func (m AttendanceModel) GetStudentAttendance(userID int64) (*[]CourseAttendance, error) {
+
+	query := `SELECT courses.course_id, courses.course_code, courses.title,
+	COUNT(attendance_sessions.session_id),
+	COUNT(*) FILTER (WHERE attendance_records.status = 'present'),
+	COUNT(*) FILTER (WHERE attendance_records.status = 'late')
+	FROM students
+	INNER JOIN attendance_sessions ON attendance_sessions.program_id = students.program_id
+	AND attendance_sessions.semester_id = students.semester_id
+	INNER JOIN courses ON courses.course_id = attendance_sessions.course_id
+	LEFT JOIN attendance_records ON attendance_records.session_id = attendance_sessions.session_id
+	AND attendance_records.student_id = students.student_id
+	WHERE students.user_id = $1
+	GROUP BY courses.course_id, courses.course_code, courses.title
+	ORDER BY courses.course_code`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attendance := []CourseAttendance{}
+
+	for rows.Next() {
+		var temp CourseAttendance
+
+		err := rows.Scan(&temp.CourseID, &temp.CourseCode, &temp.CourseTitle,
+			&temp.SessionsHeld, &temp.Present, &temp.Late)
+		if err != nil {
+			return nil, err
+		}
+
+		temp.Absent = temp.SessionsHeld - temp.Present - temp.Late
+		temp.Percentage = attendancePercentage(temp.Present, temp.Late, temp.SessionsHeld)
+
+		attendance = append(attendance, temp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(attendance) == 0 {
+		return &attendance, ErrNoRecords
+	}
+
+	return &attendance, nil
+}
+
+// GetTeacherAttendance returns the attendance of students in the course offerings currently taught by a teacher
+func (m AttendanceModel) GetTeacherAttendance(userID int64) (*[]OfferingAttendance, error) {
+
+	query := `SELECT courses.course_id, courses.course_code, courses.title,
+	programs.program_id, programs.name, program_courses.semester_id
+	FROM teachers
+	INNER JOIN teacher_courses ON teacher_courses.teacher_id = teachers.teacher_id
+	INNER JOIN courses ON courses.course_id = teacher_courses.course_id
+	INNER JOIN program_courses ON program_courses.course_id = courses.course_id
+	INNER JOIN programs ON programs.program_id = program_courses.program_id
+	WHERE teachers.user_id = $1 AND program_courses.semester_id IS NOT NULL
+	AND teacher_courses.expires_at >= CURRENT_DATE
+	ORDER BY courses.course_code`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	offerings := []OfferingAttendance{}
+
+	for rows.Next() {
+		var temp OfferingAttendance
+
+		err := rows.Scan(&temp.CourseID, &temp.CourseCode, &temp.CourseTitle,
+			&temp.ProgramID, &temp.ProgramName, &temp.SemesterID)
+		if err != nil {
+			return nil, err
+		}
+
+		offerings = append(offerings, temp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(offerings) == 0 {
+		return &offerings, ErrNoRecords
+	}
+
+	for i := range offerings {
+		err = m.getOfferingAttendance(&offerings[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &offerings, nil
+}
+
+// getOfferingAttendance fills in the sessions held and the attendance of every student of an offering
+func (m AttendanceModel) getOfferingAttendance(offering *OfferingAttendance) error {
+
+	query := `SELECT COUNT(*) FROM attendance_sessions
+	WHERE program_id = $1 AND semester_id = $2 AND course_id = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, offering.ProgramID, offering.SemesterID, offering.CourseID).Scan(&offering.SessionsHeld)
+	if err != nil {
+		return err
+	}
+
+	query = `SELECT students.user_id, students.student_id, students.name, students.symbol_no,
+	COUNT(*) FILTER (WHERE attendance_records.status = 'present'),
+	COUNT(*) FILTER (WHERE attendance_records.status = 'late')
+	FROM students
+	LEFT JOIN attendance_records ON attendance_records.student_id = students.student_id
+	AND attendance_records.session_id IN (SELECT session_id FROM attendance_sessions
+		WHERE program_id = $1 AND semester_id = $2 AND course_id = $3)
+	WHERE students.program_id = $1 AND students.semester_id = $2
+	GROUP BY students.user_id, students.student_id, students.name, students.symbol_no
+	ORDER BY students.symbol_no`
+
+	rows, err := m.DB.QueryContext(ctx, query, offering.ProgramID, offering.SemesterID, offering.CourseID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	offering.Students = []StudentAttendance{}
+
+	for rows.Next() {
+		var temp StudentAttendance
+
+		err := rows.Scan(&temp.UserID, &temp.StudentID, &temp.Name, &temp.SymbolNo, &temp.Present, &temp.Late)
+		if err != nil {
+			return err
+		}
+
+		temp.Absent = offering.SessionsHeld - temp.Present - temp.Late
+		temp.Percentage = attendancePercentage(temp.Present, temp.Late, offering.SessionsHeld)
+
+		offering.Students = append(offering.Students, temp)
+	}
+
+	return rows.Err()
+}
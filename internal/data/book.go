@@ -0,0 +1,277 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+type BookModel struct {
+	DB *sql.DB
+}
+
+// Struct to read a book from a superuser
+type BookInput struct {
+	Title       string `json:"title" binding:"required,max=200"`
+	Author      string `json:"author" binding:"required,max=200"`
+	Edition     int    `json:"edition" binding:"required,min=1,max=100"`
+	Publication string `json:"publication" binding:"required,max=200"`
+}
+
+// Struct to read the assignment of a book to a course
+type CourseBookInput struct {
+	BookID   int64 `json:"book_id" binding:"required,min=1"`
+	TextBook *bool `json:"text_book" binding:"required"` // false for a reference book
+}
+
+// Struct to hold filters for searching books
+type BookFilters struct {
+	Title  string // matches part of the title
+	Author string // matches part of the author
+	Filters
+}
+
+// Insert adds a new book
+func (m BookModel) Insert(input *BookInput) (*Book, error) {
+
+	query := `INSERT INTO books (title, author, edition, publication)
+	VALUES ($1, $2, $3, $4)
+	RETURNING book_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	book := Book{
+		Title:       strings.TrimSpace(input.Title),
+		Author:      strings.TrimSpace(input.Author),
+		Edition:     input.Edition,
+		Publication: strings.TrimSpace(input.Publication),
+	}
+
+	err := m.DB.QueryRowContext(ctx, query, book.Title, book.Author, book.Edition, book.Publication).Scan(&book.BookID)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "books_title_key"):
+			return nil, ErrDuplicateEntry
+		default:
+			return nil, err
+		}
+	}
+
+	return &book, nil
+}
+
+// Get returns a book
+func (m BookModel) Get(bookID int64) (*Book, error) {
+
+	query := `SELECT book_id, title, author, edition, publication FROM books WHERE book_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var book Book
+
+	err := m.DB.QueryRowContext(ctx, query, bookID).Scan(&book.BookID, &book.Title, &book.Author, &book.Edition, &book.Publication)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &book, nil
+}
+
+// Update replaces the details of a book
+func (m BookModel) Update(bookID int64, input *BookInput) (*Book, error) {
+
+	query := `UPDATE books SET title = $2, author = $3, edition = $4, publication = $5
+	WHERE book_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	book := Book{
+		BookID:      bookID,
+		Title:       strings.TrimSpace(input.Title),
+		Author:      strings.TrimSpace(input.Author),
+		Edition:     input.Edition,
+		Publication: strings.TrimSpace(input.Publication),
+	}
+
+	result, err := m.DB.ExecContext(ctx, query, bookID, book.Title, book.Author, book.Edition, book.Publication)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "books_title_key"):
+			return nil, ErrDuplicateEntry
+		default:
+			return nil, err
+		}
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if affected == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	return &book, nil
+}
+
+// Delete removes a book along with its course assignments
+// Books having copies in the library can not be deleted
+func (m BookModel) Delete(bookID int64) error {
+
+	query := `DELETE FROM books WHERE book_id = $1
+	AND NOT EXISTS (SELECT 1 FROM book_copies WHERE book_copies.book_id = $1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, bookID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		// Find out whether the book is missing or has copies
+		if _, err := m.Get(bookID); err != nil {
+			return err
+		}
+		return ErrNotPermitted
+	}
+
+	return nil
+}
+
+// Search returns a page of books matching the title and author
+func (m BookModel) Search(filters BookFilters) ([]Book, Metadata, error) {
+
+	query := `SELECT COUNT(*) OVER(), book_id, title, author, edition, publication
+	FROM books
+	WHERE ($1 = '' OR title ILIKE '%' || $1 || '%')
+	AND ($2 = '' OR author ILIKE '%' || $2 || '%')
+	ORDER BY title
+	LIMIT $3 OFFSET $4`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, filters.Title, filters.Author, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	books := []Book{}
+
+	for rows.Next() {
+		var temp Book
+
+		err := rows.Scan(&totalRecords, &temp.BookID, &temp.Title, &temp.Author, &temp.Edition, &temp.Publication)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		books = append(books, temp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return books, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// AttachToCourse assigns a book to a course as a text or reference book
+// Assigning an already assigned book changes whether it is a text book
+func (m BookModel) AttachToCourse(courseCode string, input *CourseBookInput) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	courseID, err := courseIDByCode(ctx, tx, courseCode)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO course_books (course_id, book_id, text_book)
+	VALUES ($1, $2, $3)
+	ON CONFLICT ON CONSTRAINT course_books_pkey DO UPDATE SET text_book = EXCLUDED.text_book`
+
+	_, err = tx.ExecContext(ctx, query, courseID, input.BookID, *input.TextBook)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "course_books_book_id_fkey"):
+			return ErrInvalidBook
+		default:
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DetachFromCourse removes a book from a course
+// The book is also removed from the references of the course's lesson plan
+func (m BookModel) DetachFromCourse(courseCode string, bookID int64) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	courseID, err := courseIDByCode(ctx, tx, courseCode)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM course_books WHERE course_id = $1 AND book_id = $2`, courseID, bookID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrInvalidBook
+	}
+
+	query := `DELETE FROM lesson_plan_references
+	USING lesson_plans
+	WHERE lesson_plans.plan_id = lesson_plan_references.plan_id
+	AND lesson_plans.course_id = $1 AND lesson_plan_references.book_id = $2`
+
+	_, err = tx.ExecContext(ctx, query, courseID, bookID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,98 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// The calendar feed of a user along with the state of the account
+type CalendarFeed struct {
+	UserID    int64
+	Key       string // signed into the subscription url
+	Activated bool
+	Expired   bool
+}
+
+// generateKey returns a new random key for a calendar feed or an id card
+func generateKey() (string, error) {
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// GetFeedKey returns the key of the calendar feed of a user, the key is created on first use
+func (m ScheduleModel) GetFeedKey(userID int64) (string, error) {
+
+	key, err := generateKey()
+	if err != nil {
+		return "", err
+	}
+
+	// The existing key is kept, so that the subscribed calendars keep working
+	query := `INSERT INTO calendar_feeds (user_id, feed_key) VALUES ($1, $2)
+	ON CONFLICT (user_id) DO UPDATE SET feed_key = calendar_feeds.feed_key
+	RETURNING feed_key`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, userID, key).Scan(&key)
+
+	return key, err
+}
+
+// RegenerateFeedKey replaces the key of the calendar feed of a user, the old subscription url stops working
+func (m ScheduleModel) RegenerateFeedKey(userID int64) (string, error) {
+
+	key, err := generateKey()
+	if err != nil {
+		return "", err
+	}
+
+	query := `INSERT INTO calendar_feeds (user_id, feed_key) VALUES ($1, $2)
+	ON CONFLICT (user_id) DO UPDATE SET feed_key = EXCLUDED.feed_key, created_at = NOW()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = m.DB.ExecContext(ctx, query, userID, key)
+	if err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// GetFeed returns the calendar feed of a user along with the state of the account
+func (m ScheduleModel) GetFeed(userID int64) (*CalendarFeed, error) {
+
+	query := `SELECT calendar_feeds.user_id, calendar_feeds.feed_key, users.activated, users.expired
+	FROM calendar_feeds
+	INNER JOIN users ON users.user_id = calendar_feeds.user_id
+	WHERE calendar_feeds.user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var feed CalendarFeed
+
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&feed.UserID, &feed.Key, &feed.Activated, &feed.Expired)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &feed, nil
+}
@@ -0,0 +1,196 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Types of certificates and their titles
+var CertificateTitles = map[string]string{
+	"character":  "Character Certificate",
+	"completion": "Completion Certificate",
+	"enrollment": "Enrollment Letter",
+}
+
+type CertificateModel struct {
+	DB *sql.DB
+}
+
+// A certificate issued to a student
+type Certificate struct {
+	CertificateID int64     `json:"certificate_id"`
+	Serial        string    `json:"serial"`
+	Type          string    `json:"type"`
+	Title         string    `json:"title"`
+	UserID        int64     `json:"user_id"` // zero if the student has been deleted
+	Name          string    `json:"name"`
+	SymbolNo      int64     `json:"symbol_no"`
+	PURegdNo      string    `json:"pu_regd_no"`
+	Program       string    `json:"program"`
+	Level         string    `json:"level"`
+	Body          string    `json:"body"`
+	IssuedAt      time.Time `json:"issued_at"`
+	Signature     string    `json:"signature"`
+	Revoked       bool      `json:"revoked"`
+}
+
+// Struct to read a certificate request from a superuser
+type CertificateInput struct {
+	UserID  int64  `json:"user_id" binding:"required"`
+	Type    string `json:"type" binding:"required,oneof=character completion enrollment"`
+	Remarks string `json:"remarks" binding:"max=1000"`
+}
+
+// SigningPayload returns the details of a certificate covered by its signature
+func (cert *Certificate) SigningPayload() []string {
+	return []string{
+		"certificate",
+		cert.Serial,
+		cert.Type,
+		cert.Name,
+		strconv.FormatInt(cert.SymbolNo, 10),
+		cert.PURegdNo,
+		cert.Program,
+		cert.Level,
+		cert.Body,
+		cert.IssuedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// Insert stores a new certificate of a student
+func (m CertificateModel) Insert(cert *Certificate, studentID, issuedBy int64) error {
+
+	query := `INSERT INTO certificates (serial, type, student_id, name, symbol_no, pu_regd_no,
+	program, level, body, issued_by, issued_at, signature)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	RETURNING certificate_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, cert.Serial, cert.Type, studentID, cert.Name, cert.SymbolNo,
+		cert.PURegdNo, cert.Program, cert.Level, cert.Body, issuedBy, cert.IssuedAt, cert.Signature).Scan(&cert.CertificateID)
+
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "certificates_serial_key"):
+			return ErrDuplicateEntry
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Common query to select certificates
+const certificateQuery = `SELECT certificates.certificate_id, certificates.serial, certificates.type,
+	COALESCE(students.user_id, 0), certificates.name, certificates.symbol_no, certificates.pu_regd_no,
+	certificates.program, certificates.level, certificates.body, certificates.issued_at,
+	certificates.signature, certificates.revoked
+	FROM certificates
+	LEFT JOIN students ON students.student_id = certificates.student_id `
+
+// scanCertificate scans a row of certificateQuery
+func scanCertificate(scanner interface{ Scan(...interface{}) error }) (*Certificate, error) {
+
+	var cert Certificate
+
+	err := scanner.Scan(&cert.CertificateID, &cert.Serial, &cert.Type, &cert.UserID, &cert.Name,
+		&cert.SymbolNo, &cert.PURegdNo, &cert.Program, &cert.Level, &cert.Body, &cert.IssuedAt,
+		&cert.Signature, &cert.Revoked)
+	if err != nil {
+		return nil, err
+	}
+
+	cert.Title = CertificateTitles[cert.Type]
+
+	return &cert, nil
+}
+
+// GetBySerial returns a certificate by its serial
+func (m CertificateModel) GetBySerial(serial string) (*Certificate, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	row := m.DB.QueryRowContext(ctx, certificateQuery+`WHERE certificates.serial = $1`, serial)
+
+	cert, err := scanCertificate(row)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return cert, nil
+}
+
+// GetAll returns the certificates, of a single student if userID is not zero
+func (m CertificateModel) GetAll(userID int64) (*[]Certificate, error) {
+
+	query := certificateQuery + `WHERE (students.user_id = $1 OR $1 = 0)
+	ORDER BY certificates.issued_at DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	certificates := []Certificate{}
+
+	for rows.Next() {
+		cert, err := scanCertificate(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		certificates = append(certificates, *cert)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(certificates) == 0 {
+		return &certificates, ErrNoRecords
+	}
+
+	return &certificates, nil
+}
+
+// Revoke marks a certificate as revoked
+func (m CertificateModel) Revoke(serial string) error {
+
+	query := `UPDATE certificates SET revoked = true WHERE serial = $1 AND NOT revoked`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, serial)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
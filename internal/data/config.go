@@ -7,6 +7,7 @@ type Config struct {
 	Env    string   // other environment type (debug|release|test)
 	Domain string   // domain at which rest api will be available
 	CORS   []string // list of supported domains
+	Secret string   // secret key to sign verification urls (id cards, certificates ...)
 	Mail   struct { // mail config
 		Host     string
 		Port     int
@@ -22,6 +23,8 @@ type Config struct {
 		MaxIdleConns int    // max idle connections to db
 		MaxIdleTime  string // max idle time for a conn
 	}
+
+	TrustedProxies []string // reverse proxies whose X-Forwarded-For header is trusted for client ips
 }
 
 type Mail struct { // mail config
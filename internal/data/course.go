@@ -642,3 +642,72 @@ func filterCourses(faculty, department, program, level string, semester int, all
 
 	return list
 }
+
+// Struct to read a course from a superuser
+type CourseInput struct {
+	CourseCode string `json:"course_code" binding:"required,max=20"`
+	Title      string `json:"title" binding:"required,max=200"`
+	Credit     int    `json:"credit" binding:"required,min=1,max=10"`
+	Elective   bool   `json:"elective"`
+}
+
+// Insert adds a new course
+func (m CourseModel) Insert(input *CourseInput) error {
+
+	query := `INSERT INTO courses (course_code, title, credit, elective) VALUES ($1, $2, $3, $4)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, strings.ToUpper(strings.TrimSpace(input.CourseCode)),
+		strings.TrimSpace(input.Title), input.Credit, input.Elective)
+	if err != nil {
+		return catalogueError(err)
+	}
+
+	return nil
+}
+
+// Update replaces the details of a course
+func (m CourseModel) Update(courseCode string, input *CourseInput) error {
+
+	query := `UPDATE courses SET course_code = $2, title = $3, credit = $4, elective = $5
+	WHERE LOWER(course_code) = LOWER($1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return execCatalogue(ctx, m.DB, query, courseCode, strings.ToUpper(strings.TrimSpace(input.CourseCode)),
+		strings.TrimSpace(input.Title), input.Credit, input.Elective)
+}
+
+// Delete deletes a course along with its books and lesson plan
+// Courses offered by programs, taught by teachers or having records of students can not be deleted
+func (m CourseModel) Delete(courseCode string) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	courseID, err := courseIDByCode(ctx, tx, courseCode)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM course_books WHERE course_id = $1`, courseID)
+	if err != nil {
+		return err
+	}
+
+	err = execCatalogue(ctx, tx, `DELETE FROM courses WHERE course_id = $1`, courseID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,386 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/roshanlc/soe-backend/internal/validator"
+)
+
+// Incase a chosen elective or back paper is not valid for the student
+var ErrInvalidCourse = errors.New("invalid course for the exam form")
+
+type ExamFormModel struct {
+	DB *sql.DB
+}
+
+// A course listed in an exam form
+type ExamFormCourse struct {
+	CourseID    int    `json:"course_id"`
+	CourseCode  string `json:"course_code"`
+	CourseTitle string `json:"course_title"`
+	Credit      int    `json:"credit"`
+	Type        string `json:"type"` // regular, elective or back
+}
+
+// Examination form of a student
+type ExamForm struct {
+	FormID      int64            `json:"form_id"`
+	UserID      int64            `json:"user_id"`
+	StudentID   int64            `json:"student_id"`
+	Name        string           `json:"name"`
+	SymbolNo    int64            `json:"symbol_no"`
+	ProgramID   int              `json:"program_id"`
+	Program     string           `json:"program"`
+	SemesterID  int              `json:"semester_id"`
+	Status      string           `json:"status"`
+	Remarks     string           `json:"remarks"`
+	SubmittedAt time.Time        `json:"submitted_at"`
+	VerifiedAt  *time.Time       `json:"verified_at"`
+	Courses     []ExamFormCourse `json:"courses"`
+}
+
+// Struct to read an exam form from a student
+// Regular courses of the student's semester are added automatically
+type ExamFormInput struct {
+	Electives  []int64 `json:"electives"`
+	BackPapers []int64 `json:"back_papers"`
+}
+
+// Struct to read the verification of an exam form from a superuser
+type ExamFormReview struct {
+	Status  string `json:"status" binding:"required,oneof=verified rejected"`
+	Remarks string `json:"remarks"`
+}
+
+// Filters to list exam forms
+type ExamFormFilters struct {
+	ProgramID  int
+	SemesterID int
+	Status     string
+}
+
+// ValidateExamFormInput checks that a course is not chosen twice
+func ValidateExamFormInput(v *validator.Validator, input *ExamFormInput) {
+
+	seen := make(map[int64]bool)
+
+	for _, id := range input.Electives {
+		v.Check(id > 0, "electives", "must contain valid course ids")
+		v.Check(!seen[id], "electives", "must not contain repeated courses")
+		seen[id] = true
+	}
+
+	for _, id := range input.BackPapers {
+		v.Check(id > 0, "back_papers", "must contain valid course ids")
+		v.Check(!seen[id], "back_papers", "must not contain repeated courses or electives")
+		seen[id] = true
+	}
+}
+
+// ValidateExamFormReview checks that a rejection has remarks
+func ValidateExamFormReview(v *validator.Validator, input *ExamFormReview) {
+	v.Check(input.Status != "rejected" || input.Remarks != "", "remarks", "must be provided while rejecting")
+	v.Check(len(input.Remarks) <= 1000, "remarks", "must not be more than 1000 bytes long")
+}
+
+// countCourses counts the courses of a program matching the condition
+func countCourses(ctx context.Context, tx *sql.Tx, condition string, programID, semesterID int, courseIDs []int64) (int, error) {
+
+	query := `SELECT COUNT(*) FROM program_courses
+	INNER JOIN courses ON courses.course_id = program_courses.course_id
+	WHERE program_courses.program_id = $1 AND program_courses.course_id = ANY($3) AND ` + condition
+
+	var count int
+	err := tx.QueryRowContext(ctx, query, programID, semesterID, pq.Array(courseIDs)).Scan(&count)
+
+	return count, err
+}
+
+// SubmitForm submits the exam form of a student for their current semester
+// A rejected form is replaced, a pending or verified form cannot be submitted again
+func (m ExamFormModel) SubmitForm(userID int64, input *ExamFormInput) (int64, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var studentID int64
+	var programID, semesterID int
+
+	query := `SELECT student_id, program_id, semester_id FROM students WHERE user_id = $1`
+
+	err = tx.QueryRowContext(ctx, query, userID).Scan(&studentID, &programID, &semesterID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	// Electives must be of the current semester
+	count, err := countCourses(ctx, tx, `courses.elective AND program_courses.semester_id = $2`,
+		programID, semesterID, input.Electives)
+	if err != nil {
+		return 0, err
+	}
+	if count != len(input.Electives) {
+		return 0, ErrInvalidCourse
+	}
+
+	// Back papers must be of a previous semester
+	count, err = countCourses(ctx, tx, `program_courses.semester_id < $2`,
+		programID, semesterID, input.BackPapers)
+	if err != nil {
+		return 0, err
+	}
+	if count != len(input.BackPapers) {
+		return 0, ErrInvalidCourse
+	}
+
+	// Check for an existing form of the semester
+	var formID int64
+	var status string
+
+	query = `SELECT form_id, status FROM exam_forms
+	WHERE student_id = $1 AND program_id = $2 AND semester_id = $3
+	FOR UPDATE`
+
+	err = tx.QueryRowContext(ctx, query, studentID, programID, semesterID).Scan(&formID, &status)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		query = `INSERT INTO exam_forms (student_id, program_id, semester_id)
+		VALUES ($1, $2, $3)
+		RETURNING form_id`
+
+		err = tx.QueryRowContext(ctx, query, studentID, programID, semesterID).Scan(&formID)
+		if err != nil {
+			return 0, err
+		}
+	case err != nil:
+		return 0, err
+	case status != "rejected":
+		return 0, ErrDuplicateEntry
+	default:
+		query = `UPDATE exam_forms SET status = 'pending', remarks = NULL, verified_by = NULL,
+		verified_at = NULL, submitted_at = NOW()
+		WHERE form_id = $1`
+
+		_, err = tx.ExecContext(ctx, query, formID)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM exam_form_courses WHERE form_id = $1`, formID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Regular courses of the semester
+	query = `INSERT INTO exam_form_courses (form_id, course_id, type)
+	SELECT $1, program_courses.course_id, 'regular' FROM program_courses
+	INNER JOIN courses ON courses.course_id = program_courses.course_id
+	WHERE program_courses.program_id = $2 AND program_courses.semester_id = $3 AND NOT courses.elective`
+
+	_, err = tx.ExecContext(ctx, query, formID, programID, semesterID)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `INSERT INTO exam_form_courses (form_id, course_id, type)
+	SELECT $1, UNNEST($2::bigint[]), $3`
+
+	_, err = tx.ExecContext(ctx, query, formID, pq.Array(input.Electives), "elective")
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, query, formID, pq.Array(input.BackPapers), "back")
+	if err != nil {
+		return 0, err
+	}
+
+	// A form must list at least one course
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM exam_form_courses WHERE form_id = $1`, formID).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, ErrNoRecords
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return formID, nil
+}
+
+// getFormCourses returns the courses listed in an exam form
+func (m ExamFormModel) getFormCourses(ctx context.Context, formID int64) ([]ExamFormCourse, error) {
+
+	query := `SELECT courses.course_id, courses.course_code, courses.title, courses.credit, exam_form_courses.type
+	FROM exam_form_courses
+	INNER JOIN courses ON courses.course_id = exam_form_courses.course_id
+	WHERE exam_form_courses.form_id = $1
+	ORDER BY exam_form_courses.type DESC, courses.course_code`
+
+	rows, err := m.DB.QueryContext(ctx, query, formID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	courses := []ExamFormCourse{}
+
+	for rows.Next() {
+		var temp ExamFormCourse
+
+		err := rows.Scan(&temp.CourseID, &temp.CourseCode, &temp.CourseTitle, &temp.Credit, &temp.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		courses = append(courses, temp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return courses, nil
+}
+
+// Common query to select exam forms
+const examFormQuery = `SELECT exam_forms.form_id, students.user_id, students.student_id, students.name,
+	students.symbol_no, programs.program_id, programs.name, exam_forms.semester_id, exam_forms.status,
+	COALESCE(exam_forms.remarks, ''), exam_forms.submitted_at, exam_forms.verified_at
+	FROM exam_forms
+	INNER JOIN students ON students.student_id = exam_forms.student_id
+	INNER JOIN programs ON programs.program_id = exam_forms.program_id `
+
+// listForms returns the exam forms along with their courses
+func (m ExamFormModel) listForms(query string, args ...interface{}) (*[]ExamForm, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	forms := []ExamForm{}
+
+	for rows.Next() {
+		var temp ExamForm
+
+		err := rows.Scan(&temp.FormID, &temp.UserID, &temp.StudentID, &temp.Name, &temp.SymbolNo,
+			&temp.ProgramID, &temp.Program, &temp.SemesterID, &temp.Status, &temp.Remarks,
+			&temp.SubmittedAt, &temp.VerifiedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		forms = append(forms, temp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range forms {
+		forms[i].Courses, err = m.getFormCourses(ctx, forms[i].FormID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(forms) == 0 {
+		return &forms, ErrNoRecords
+	}
+
+	return &forms, nil
+}
+
+// GetForm returns an exam form
+func (m ExamFormModel) GetForm(formID int64) (*ExamForm, error) {
+
+	forms, err := m.listForms(examFormQuery+`WHERE exam_forms.form_id = $1`, formID)
+	if err != nil {
+		switch err {
+		case ErrNoRecords:
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &(*forms)[0], nil
+}
+
+// GetStudentForms returns all the exam forms of a student
+func (m ExamFormModel) GetStudentForms(userID int64) (*[]ExamForm, error) {
+	return m.listForms(examFormQuery+`WHERE students.user_id = $1 ORDER BY exam_forms.semester_id DESC`, userID)
+}
+
+// ListForms returns exam forms filtered by program, semester and status
+// Zero values and empty status are ignored
+func (m ExamFormModel) ListForms(filters ExamFormFilters) (*[]ExamForm, error) {
+
+	query := examFormQuery + `WHERE (exam_forms.program_id = $1 OR $1 = 0)
+	AND (exam_forms.semester_id = $2 OR $2 = 0)
+	AND (exam_forms.status = $3 OR $3 = '')
+	ORDER BY exam_forms.program_id, exam_forms.semester_id, students.symbol_no`
+
+	return m.listForms(query, filters.ProgramID, filters.SemesterID, filters.Status)
+}
+
+// ReviewForm verifies or rejects a pending exam form
+func (m ExamFormModel) ReviewForm(formID int64, review *ExamFormReview, reviewerID int64) error {
+
+	query := `UPDATE exam_forms SET status = $1, remarks = NULLIF($2, ''), verified_by = $3, verified_at = NOW()
+	WHERE form_id = $4 AND status = 'pending'`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, review.Status, review.Remarks, reviewerID, formID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		// Either the form does not exist or has been reviewed already
+		var exists bool
+		err = m.DB.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM exam_forms WHERE form_id = $1)`, formID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrRecordNotFound
+		}
+		return ErrNotPermitted
+	}
+
+	return nil
+}
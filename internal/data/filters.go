@@ -0,0 +1,57 @@
+package data
+
+import (
+	"math"
+
+	"github.com/roshanlc/soe-backend/internal/validator"
+)
+
+// Struct to hold pagination values of a listing
+type Filters struct {
+	Page     int
+	PageSize int
+}
+
+// Struct to hold pagination metadata returned along with a listing
+type Metadata struct {
+	CurrentPage  int `json:"current_page,omitempty"`
+	PageSize     int `json:"page_size,omitempty"`
+	FirstPage    int `json:"first_page,omitempty"`
+	LastPage     int `json:"last_page,omitempty"`
+	TotalRecords int `json:"total_records,omitempty"`
+}
+
+// ValidateFilters checks that page and page_size are within sensible range
+func ValidateFilters(v *validator.Validator, f Filters) {
+	v.Check(f.Page > 0, "page", "must be greater than zero")
+	v.Check(f.Page <= 10_000_000, "page", "must be a maximum of 10 million")
+	v.Check(f.PageSize > 0, "page_size", "must be greater than zero")
+	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
+}
+
+// limit returns the value for LIMIT clause
+func (f Filters) limit() int {
+	return f.PageSize
+}
+
+// offset returns the value for OFFSET clause
+func (f Filters) offset() int {
+	return (f.Page - 1) * f.PageSize
+}
+
+// calculateMetadata calculates pagination metadata from total records count
+func calculateMetadata(totalRecords, page, pageSize int) Metadata {
+
+	// Empty metadata if there are no records
+	if totalRecords == 0 {
+		return Metadata{}
+	}
+
+	return Metadata{
+		CurrentPage:  page,
+		PageSize:     pageSize,
+		FirstPage:    1,
+		LastPage:     int(math.Ceil(float64(totalRecords) / float64(pageSize))),
+		TotalRecords: totalRecords,
+	}
+}
@@ -0,0 +1,307 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/roshanlc/soe-backend/internal/validator"
+)
+
+type GradesModel struct {
+	DB *sql.DB
+}
+
+// A grade of a grading scale
+// A percentage maps to the grade with the highest MinPercent not above it
+type Grade struct {
+	Grade      string  `json:"grade" binding:"required"`
+	GradePoint float64 `json:"grade_point"`
+	MinPercent float64 `json:"min_percent"`
+}
+
+// Grading scale of a level
+type GradingScale struct {
+	LevelID int     `json:"level_id"`
+	Level   string  `json:"level"`
+	Grades  []Grade `json:"grades"`
+}
+
+// Struct to read a new grading scale from user
+type GradingScaleInput struct {
+	Grades []Grade `json:"grades" binding:"required,min=1,dive"`
+}
+
+// Result of a course in a transcript
+type CourseResult struct {
+	CourseID    int     `json:"course_id"`
+	CourseCode  string  `json:"course_code"`
+	CourseTitle string  `json:"course_title"`
+	Credit      int     `json:"credit"`
+	Total       float64 `json:"total"`
+	Percentage  float64 `json:"percentage"`
+	Grade       string  `json:"grade"`
+	GradePoint  float64 `json:"grade_point"`
+}
+
+// Result of a semester in a transcript
+type SemesterResult struct {
+	SemesterID int            `json:"semester_id"`
+	Courses    []CourseResult `json:"courses"`
+	Credits    int            `json:"credits"`
+	SGPA       float64        `json:"sgpa"`
+}
+
+// Transcript of a student, only published (locked) marks are included
+type Transcript struct {
+	UserID    int64            `json:"user_id"`
+	Name      string           `json:"name"`
+	SymbolNo  int64            `json:"symbol_no"`
+	PURegdNo  string           `json:"pu_regd_no"`
+	Program   string           `json:"program"`
+	Level     string           `json:"level"`
+	Semesters []SemesterResult `json:"semesters"`
+	Credits   int              `json:"credits"`
+	CGPA      float64          `json:"cgpa"`
+}
+
+// ValidateGradingScale checks the grades of a grading scale
+func ValidateGradingScale(v *validator.Validator, grades []Grade) {
+
+	seenGrade := make(map[string]bool)
+	seenPercent := make(map[float64]bool)
+	hasZero := false
+
+	for _, grade := range grades {
+		v.Check(len(grade.Grade) <= 5, "grade", "must not be more than 5 bytes long")
+		v.Check(!seenGrade[grade.Grade], "grade", "must not be repeated")
+		v.Check(grade.GradePoint >= 0 && grade.GradePoint <= 10, "grade_point", "must be between 0 and 10")
+		v.Check(grade.MinPercent >= 0 && grade.MinPercent <= 100, "min_percent", "must be between 0 and 100")
+		v.Check(!seenPercent[grade.MinPercent], "min_percent", "must not be repeated")
+
+		seenGrade[grade.Grade] = true
+		seenPercent[grade.MinPercent] = true
+		if grade.MinPercent == 0 {
+			hasZero = true
+		}
+	}
+
+	v.Check(hasZero, "min_percent", "must be 0 for one of the grades")
+
+	// A higher percentage must not get a lower grade point
+	sorted := append([]Grade{}, grades...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].MinPercent > sorted[j].MinPercent })
+	for i := 1; i < len(sorted); i++ {
+		v.Check(sorted[i-1].GradePoint >= sorted[i].GradePoint, "grade_point", "must not decrease as min_percent increases")
+	}
+}
+
+// Incase the grading scale of a level is missing or has no grade for a percentage
+var ErrNoGradingScale = errors.New("no grading scale for the percentage")
+
+// gradeFor returns the grade of a percentage, grades must be sorted by min_percent in descending order
+func gradeFor(grades []Grade, percent float64) (Grade, error) {
+	for _, grade := range grades {
+		if percent >= grade.MinPercent {
+			return grade, nil
+		}
+	}
+	return Grade{}, ErrNoGradingScale
+}
+
+// roundOff rounds off to two decimal places
+func roundOff(val float64) float64 {
+	return math.Round(val*100) / 100
+}
+
+// GetGradingScale returns the grading scale of a level
+func (m GradesModel) GetGradingScale(levelID int) (*GradingScale, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	scale := GradingScale{LevelID: levelID, Grades: []Grade{}}
+
+	query := `SELECT name FROM levels WHERE level_id = $1`
+
+	err := m.DB.QueryRowContext(ctx, query, levelID).Scan(&scale.Level)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	query = `SELECT grade, grade_point, min_percent FROM grading_scales
+	WHERE level_id = $1
+	ORDER BY min_percent DESC`
+
+	rows, err := m.DB.QueryContext(ctx, query, levelID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var temp Grade
+
+		err := rows.Scan(&temp.Grade, &temp.GradePoint, &temp.MinPercent)
+		if err != nil {
+			return nil, err
+		}
+
+		scale.Grades = append(scale.Grades, temp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &scale, nil
+}
+
+// SetGradingScale replaces the grading scale of a level
+func (m GradesModel) SetGradingScale(levelID int, grades []Grade) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM levels WHERE level_id = $1)`, levelID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrRecordNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM grading_scales WHERE level_id = $1`, levelID)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO grading_scales (level_id, grade, grade_point, min_percent)
+	VALUES ($1, $2, $3, $4)`
+
+	for _, grade := range grades {
+		_, err = tx.ExecContext(ctx, query, levelID, grade.Grade, grade.GradePoint, grade.MinPercent)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetTranscript computes the grades, SGPA of every semester and CGPA of a student
+// from the published marks, using the grading scale of the level of the student's program
+func (m GradesModel) GetTranscript(userID int64) (*Transcript, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	transcript := Transcript{Semesters: []SemesterResult{}}
+	var studentID int64
+	var levelID int
+
+	query := `SELECT students.user_id, students.student_id, students.name, students.symbol_no,
+	students.pu_regd_no, programs.name, levels.level_id, levels.name
+	FROM students
+	INNER JOIN programs ON programs.program_id = students.program_id
+	INNER JOIN levels ON levels.level_id = programs.level_id
+	WHERE students.user_id = $1`
+
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&transcript.UserID, &studentID, &transcript.Name,
+		&transcript.SymbolNo, &transcript.PURegdNo, &transcript.Program, &levelID, &transcript.Level)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	scale, err := m.GetGradingScale(levelID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A re-taken course counts with the marks of its latest published attempt
+	query = `SELECT DISTINCT ON (marks.semester_id, courses.course_code)
+	marks.semester_id, courses.course_id, courses.course_code, courses.title, courses.credit,
+	COALESCE(marks.internal, 0) + COALESCE(marks.final, 0)
+	FROM marks
+	INNER JOIN courses ON courses.course_id = marks.course_id
+	INNER JOIN marks_locks ON marks_locks.program_id = marks.program_id
+	AND marks_locks.semester_id = marks.semester_id AND marks_locks.course_id = marks.course_id
+	AND marks_locks.session = marks.session
+	WHERE marks.student_id = $1
+	ORDER BY marks.semester_id, courses.course_code, marks.session DESC`
+
+	rows, err := m.DB.QueryContext(ctx, query, studentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points, semesterPoints float64
+
+	for rows.Next() {
+		var semesterID int
+		var temp CourseResult
+
+		err := rows.Scan(&semesterID, &temp.CourseID, &temp.CourseCode, &temp.CourseTitle, &temp.Credit, &temp.Total)
+		if err != nil {
+			return nil, err
+		}
+
+		temp.Percentage = roundOff(temp.Total * 100 / (InternalFullMarks + FinalFullMarks))
+		grade, err := gradeFor(scale.Grades, temp.Percentage)
+		if err != nil {
+			return nil, err
+		}
+		temp.Grade = grade.Grade
+		temp.GradePoint = grade.GradePoint
+
+		// Start a new semester
+		last := len(transcript.Semesters) - 1
+		if last < 0 || transcript.Semesters[last].SemesterID != semesterID {
+			transcript.Semesters = append(transcript.Semesters, SemesterResult{SemesterID: semesterID, Courses: []CourseResult{}})
+			last++
+			semesterPoints = 0
+		}
+
+		semester := &transcript.Semesters[last]
+		semester.Courses = append(semester.Courses, temp)
+		semester.Credits += temp.Credit
+		semesterPoints += temp.GradePoint * float64(temp.Credit)
+		if semester.Credits > 0 {
+			semester.SGPA = roundOff(semesterPoints / float64(semester.Credits))
+		}
+
+		transcript.Credits += temp.Credit
+		points += temp.GradePoint * float64(temp.Credit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if transcript.Credits > 0 {
+		transcript.CGPA = roundOff(points / float64(transcript.Credits))
+	}
+
+	return &transcript, nil
+}
@@ -0,0 +1,131 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// The id card of a student along with the state of the account
+type IDCard struct {
+	UserID    int64
+	Key       string // signed into the QR code of the card
+	Activated bool
+	Expired   bool
+}
+
+// GetIDCardKeys returns the keys of the id cards of students, the keys are created on first use
+func (m UserModel) GetIDCardKeys(userIDs []int64) (map[int64]string, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// The existing keys are kept, so that the printed cards keep verifying
+	query := `INSERT INTO id_cards (user_id, card_key) VALUES ($1, $2)
+	ON CONFLICT (user_id) DO NOTHING`
+
+	for _, userID := range userIDs {
+		key, err := generateKey()
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.ExecContext(ctx, query, userID, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT user_id, card_key FROM id_cards WHERE user_id = ANY($1)`, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make(map[int64]string)
+
+	for rows.Next() {
+		var userID int64
+		var key string
+
+		if err := rows.Scan(&userID, &key); err != nil {
+			return nil, err
+		}
+
+		keys[userID] = key
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, tx.Commit()
+}
+
+// ReissueIDCard replaces the key of the id card of a student, the old card stops verifying
+func (m UserModel) ReissueIDCard(userID int64) (string, error) {
+
+	key, err := generateKey()
+	if err != nil {
+		return "", err
+	}
+
+	query := `INSERT INTO id_cards (user_id, card_key)
+	SELECT user_id, $2 FROM students WHERE user_id = $1
+	ON CONFLICT (user_id) DO UPDATE SET card_key = EXCLUDED.card_key, issued_at = NOW()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, key)
+	if err != nil {
+		return "", err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+
+	// Not a student
+	if affected == 0 {
+		return "", ErrRecordNotFound
+	}
+
+	return key, nil
+}
+
+// GetIDCard returns the id card of a student along with the state of the account
+func (m UserModel) GetIDCard(userID int64) (*IDCard, error) {
+
+	query := `SELECT id_cards.user_id, id_cards.card_key, users.activated, users.expired
+	FROM id_cards
+	INNER JOIN users ON users.user_id = id_cards.user_id
+	WHERE id_cards.user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var card IDCard
+
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&card.UserID, &card.Key, &card.Activated, &card.Expired)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &card, nil
+}
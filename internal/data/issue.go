@@ -2,12 +2,32 @@ package data
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
-	"errors"
+	"encoding/base32"
+	"encoding/hex"
 	"log"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
+)
+
+// Statuses of the lifecycle of an issue
+const (
+	IssueOpen       = "open"
+	IssueInProgress = "in-progress"
+	IssueResolved   = "resolved"
+	IssueRejected   = "rejected"
 )
 
+// Categories an issue can be lodged under
+var IssueCategories = []string{"general", "academic", "examination", "fees", "library", "facilities", "harassment"}
+
+// Priorities set on an issue by superusers
+var IssuePriorities = []string{"low", "normal", "high", "urgent"}
+
 // Wrapper around *sql.DB
 type IssuesModel struct {
 	DB *sql.DB
@@ -15,29 +35,85 @@ type IssuesModel struct {
 
 // struct to hold issue
 type Issue struct {
-	IssueID   int       `json:"issue_d"`
-	Issue     string    `json:"issue"`
-	UserID    int       `json:"user_id"`
-	UserRole  string    `json:"user_role"`
-	Read      bool      `json:"read"`
+	IssueID       int               `json:"issue_d"`
+	Issue         string            `json:"issue"`
+	UserID        *int              `json:"user_id"` // nil for anonymous issues
+	UserRole      string            `json:"user_role"`
+	Anonymous     bool              `json:"anonymous"`
+	Read          bool              `json:"read"`
+	Status        string            `json:"status"`
+	Category      string            `json:"category"`
+	Priority      string            `json:"priority"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	Replies       []IssueReply      `json:"replies"`
+	Attachments   []IssueAttachment `json:"attachments"`
+	ReporterEmail string            `json:"-"`
+}
+
+// A file attached to an issue, served only to the reporter and superusers
+type IssueAttachment struct {
+	AttachmentID int64     `json:"attachment_id"`
+	IssueID      int       `json:"issue_id"`
+	FileName     string    `json:"file_name"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	CreatedAt    time.Time `json:"created_at"`
+	Path         string    `json:"-"` // inside the issues uploads folder
+}
+
+// A reply of a superuser to an issue, visible to the reporter
+type IssueReply struct {
+	ReplyID   int64     `json:"reply_id"`
+	IssueID   int       `json:"issue_id"`
+	RepliedBy string    `json:"replied_by"`
+	Reply     string    `json:"reply"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
-// RegisterIssue inserts an issue into db
-func (m IssuesModel) RegisterIssue(issue, token string) error {
+// Struct to read a change of the status of an issue, along with an optional reply
+type IssueStatusInput struct {
+	Status string `json:"status" binding:"required,oneof=open in-progress resolved rejected"`
+	Reply  string `json:"reply" binding:"max=2000"`
+}
+
+// Struct to read the category and/or priority of an issue, omitted values are kept as they are
+type IssueClassificationInput struct {
+	Category string `json:"category" binding:"omitempty,oneof=general academic examination fees library facilities harassment"`
+	Priority string `json:"priority" binding:"omitempty,oneof=low normal high urgent"`
+}
+
+// Struct to read a reply to an issue
+type IssueReplyInput struct {
+	Reply string `json:"reply" binding:"required,max=2000"`
+}
+
+// Struct to read the tracking code of an anonymous issue
+type IssueTrackingInput struct {
+	TrackingCode string `json:"tracking_code" binding:"required,max=64"`
+}
+
+// Filters to list issues, empty values match all
+type IssueFilters struct {
+	Read     *bool
+	Status   string
+	Category string
+	Priority string
+}
+
+// RegisterIssue inserts an issue into db along with its attachments
+func (m IssuesModel) RegisterIssue(issue, category, token string, attachments []IssueAttachment) error {
 
-	query := `INSERT INTO issues (issue, user_id, user_role) VALUES 
-	( $1, (SELECT user_id FROM tokens WHERE hash = $2),
+	query := `INSERT INTO issues (issue, category, user_id, user_role) VALUES 
+	( $1, $3, (SELECT user_id FROM tokens WHERE hash = $2),
 	(select roles.name as role FROM users
 	 inner join user_roles on users.user_id = user_roles.user_id 
 	 inner join roles on roles.role_id = user_roles.role_id 
 	 where users.user_id = (SELECT user_id FROM tokens WHERE hash = $2)
-	))`
+	))
+	RETURNING issue_id`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	_, err := m.DB.ExecContext(ctx, query, issue, token)
+	err := m.insertIssue(attachments, query, issue, token, category)
 
 	if err != nil {
 		log.Println(err)
@@ -48,6 +124,75 @@ func (m IssuesModel) RegisterIssue(issue, token string) error {
 	return nil
 }
 
+// insertIssue inserts an issue by a query returning the issue_id, along with its attachments
+func (m IssuesModel) insertIssue(attachments []IssueAttachment, query string, args ...interface{}) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var issueID int
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&issueID)
+	if err != nil {
+		return err
+	}
+
+	query = `INSERT INTO issue_attachments (issue_id, file_name, path, content_type, size)
+	VALUES ($1, $2, $3, $4, $5)`
+
+	for _, attachment := range attachments {
+		_, err = tx.ExecContext(ctx, query, issueID, attachment.FileName, attachment.Path, attachment.ContentType, attachment.Size)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// trackingHash returns the hash of a tracking code, as stored in the issues table
+func trackingHash(code string) string {
+	hash := sha256.Sum256([]byte(strings.ToUpper(strings.TrimSpace(code))))
+	return hex.EncodeToString(hash[:])
+}
+
+// RegisterAnonymousIssue inserts an issue without the user along with its attachments,
+// only the role of the user is kept
+// It returns the tracking code with which the reporter can follow the issue
+func (m IssuesModel) RegisterAnonymousIssue(issue, category, token string, attachments []IssueAttachment) (string, error) {
+
+	randomBytes := make([]byte, 16)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	code := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+
+	query := `INSERT INTO issues (issue, category, user_role, anonymous, tracking_hash) VALUES 
+	( $1, $3,
+	(select roles.name as role FROM users
+	 inner join user_roles on users.user_id = user_roles.user_id 
+	 inner join roles on roles.role_id = user_roles.role_id 
+	 where users.user_id = (SELECT user_id FROM tokens WHERE hash = $2)
+	), 't', $4)
+	RETURNING issue_id`
+
+	err = m.insertIssue(attachments, query, issue, token, category, trackingHash(code))
+	if err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
 // MarkAsRead marks an issue as read
 // For admins
 func (m IssuesModel) MarkAsRead(issueID int) error {
@@ -81,41 +226,27 @@ func (m IssuesModel) MarkAsRead(issueID int) error {
 
 }
 
-// Returns a list of all issues
-// Supports filter like all, only read, only unread
-func (m IssuesModel) GetAllIssues(all, onlyRead bool) (*[]Issue, error) {
-
-	var issues []Issue
+// Common query to select issues along with the email of the reporter, which is empty for anonymous issues
+const issueQuery = `SELECT issues.issue_id, issues.issue, issues.user_id, issues.user_role, issues.anonymous,
+	issues.read, issues.status, issues.category, issues.priority, issues.created_at, issues.updated_at,
+	COALESCE(users.email, '')
+	FROM issues
+	LEFT JOIN users ON users.user_id = issues.user_id `
 
-	//  issue_id | issue | user_id | user_role | read | created_at
-	query := `SELECT issue_id, issue, user_id, user_role, read, created_at 
-	FROM issues`
-
-	if !all && onlyRead {
-		// if read filter is provided
-		query += ` WHERE read = 't'`
-	} else if !all && !onlyRead {
-		// if unread filter is provided
-		query += ` WHERE read = 'f'`
-	}
+// listIssues returns the issues selected by a query built on issueQuery, along with their replies and attachments
+func (m IssuesModel) listIssues(query string, args ...interface{}) ([]Issue, error) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, query)
-
+	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		switch {
-		// No records, but not an error
-		case errors.Is(err, sql.ErrNoRows):
-			return nil, ErrNoRecords
-		default:
-			return nil, err
-		}
+		return nil, err
 	}
-
 	defer rows.Close()
 
+	issues := []Issue{}
+
 	for rows.Next() {
 
 		var temp Issue
@@ -124,13 +255,21 @@ func (m IssuesModel) GetAllIssues(all, onlyRead bool) (*[]Issue, error) {
 			&temp.Issue,
 			&temp.UserID,
 			&temp.UserRole,
+			&temp.Anonymous,
 			&temp.Read,
-			&temp.CreatedAt)
+			&temp.Status,
+			&temp.Category,
+			&temp.Priority,
+			&temp.CreatedAt,
+			&temp.UpdatedAt,
+			&temp.ReporterEmail)
 
 		if err != nil {
 			return nil, err
 		}
 
+		temp.Replies = []IssueReply{}
+		temp.Attachments = []IssueAttachment{}
 		issues = append(issues, temp)
 	}
 
@@ -139,57 +278,299 @@ func (m IssuesModel) GetAllIssues(all, onlyRead bool) (*[]Issue, error) {
 		return nil, err
 	}
 
+	if len(issues) == 0 {
+		return issues, nil
+	}
+
+	err = m.attachReplies(ctx, issues)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.attachFiles(ctx, issues)
+	if err != nil {
+		return nil, err
+	}
+
+	return issues, nil
+}
+
+// attachReplies fills in the replies of the issues, oldest first
+func (m IssuesModel) attachReplies(ctx context.Context, issues []Issue) error {
+
+	query := `SELECT issue_replies.reply_id, issue_replies.issue_id, COALESCE(superusers.name, ''),
+	issue_replies.reply, issue_replies.created_at
+	FROM issue_replies
+	LEFT JOIN superusers ON superusers.user_id = issue_replies.user_id
+	WHERE issue_replies.issue_id = ANY($1)
+	ORDER BY issue_replies.reply_id`
+
+	index := make(map[int]int, len(issues))
+	issueIDs := make([]int64, 0, len(issues))
+
+	for i := range issues {
+		index[issues[i].IssueID] = i
+		issueIDs = append(issueIDs, int64(issues[i].IssueID))
+	}
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(issueIDs))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var temp IssueReply
+
+		err := rows.Scan(&temp.ReplyID, &temp.IssueID, &temp.RepliedBy, &temp.Reply, &temp.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		i := index[temp.IssueID]
+		issues[i].Replies = append(issues[i].Replies, temp)
+	}
+
+	return rows.Err()
+}
+
+// attachFiles fills in the attachments of the issues
+func (m IssuesModel) attachFiles(ctx context.Context, issues []Issue) error {
+
+	query := `SELECT attachment_id, issue_id, file_name, content_type, size, created_at, path
+	FROM issue_attachments
+	WHERE issue_id = ANY($1)
+	ORDER BY attachment_id`
+
+	index := make(map[int]int, len(issues))
+	issueIDs := make([]int64, 0, len(issues))
+
+	for i := range issues {
+		index[issues[i].IssueID] = i
+		issueIDs = append(issueIDs, int64(issues[i].IssueID))
+	}
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(issueIDs))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var temp IssueAttachment
+
+		err := rows.Scan(&temp.AttachmentID, &temp.IssueID, &temp.FileName, &temp.ContentType,
+			&temp.Size, &temp.CreatedAt, &temp.Path)
+		if err != nil {
+			return err
+		}
+
+		i := index[temp.IssueID]
+		issues[i].Attachments = append(issues[i].Attachments, temp)
+	}
+
+	return rows.Err()
+}
+
+// Returns a list of all issues, latest first
+// Supports filters on read, status, category and priority
+func (m IssuesModel) GetAllIssues(filters IssueFilters) (*[]Issue, error) {
+
+	query := issueQuery + `WHERE ($1::boolean IS NULL OR issues.read = $1)
+	AND (issues.status = $2 OR $2 = '')
+	AND (issues.category = $3 OR $3 = '')
+	AND (issues.priority = $4 OR $4 = '')
+	ORDER BY issues.issue_id DESC`
+
+	issues, err := m.listIssues(query, filters.Read, filters.Status, filters.Category, filters.Priority)
+	if err != nil {
+		return nil, err
+	}
+
 	return &issues, nil
 }
 
-// Returns a list of all issues by a user
+// Returns a list of all issues by a user along with the replies, latest first
 func (m IssuesModel) GetIssues(userID int) (*[]Issue, error) {
 
-	var issues []Issue
+	issues, err := m.listIssues(issueQuery+`WHERE issues.user_id = $1 ORDER BY issues.issue_id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &issues, nil
+}
 
-	//  issue_id | issue | user_id | user_role | read | created_at
-	query := `SELECT issue_id, issue, user_id, user_role, read, created_at 
-	FROM issues WHERE user_id = $1`
+// GetIssue returns an issue along with its replies
+func (m IssuesModel) GetIssue(issueID int) (*Issue, error) {
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	issues, err := m.listIssues(issueQuery+`WHERE issues.issue_id = $1`, issueID)
+	if err != nil {
+		return nil, err
+	}
 
-	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if len(issues) == 0 {
+		return nil, ErrRecordNotFound
+	}
 
+	return &issues[0], nil
+}
+
+// GetIssueByTrackingCode returns an anonymous issue along with its replies
+func (m IssuesModel) GetIssueByTrackingCode(code string) (*Issue, error) {
+
+	if strings.TrimSpace(code) == "" {
+		return nil, ErrRecordNotFound
+	}
+
+	issues, err := m.listIssues(issueQuery+`WHERE issues.tracking_hash = $1`, trackingHash(code))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(issues) == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	return &issues[0], nil
+}
+
+// insertReply adds a reply of a superuser to an issue
+func insertReply(ctx context.Context, tx *sql.Tx, issueID int, userID int64, reply string) (*IssueReply, error) {
+
+	query := `INSERT INTO issue_replies (issue_id, user_id, reply)
+	VALUES ($1, $2, $3)
+	RETURNING reply_id, created_at, COALESCE((SELECT name FROM superusers WHERE user_id = $2), '')`
+
+	temp := IssueReply{IssueID: issueID, Reply: reply}
+
+	err := tx.QueryRowContext(ctx, query, issueID, userID, reply).Scan(&temp.ReplyID, &temp.CreatedAt, &temp.RepliedBy)
 	if err != nil {
 		switch {
-		// No records, but not an error
-		case errors.Is(err, sql.ErrNoRows):
-			return nil, ErrNoRecords
+		case strings.Contains(err.Error(), "issue_replies_issue_id_fkey"):
+			return nil, ErrRecordNotFound
 		default:
 			return nil, err
 		}
 	}
 
-	defer rows.Close()
+	return &temp, nil
+}
 
-	for rows.Next() {
+// SetStatus moves an issue to another status, the issue is also marked as read
+// The reply, if any, is added along with the change
+// It returns ErrNotUpdated if the issue already has the status
+func (m IssuesModel) SetStatus(issueID int, userID int64, input *IssueStatusInput) (*Issue, error) {
 
-		var temp Issue
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-		err := rows.Scan(&temp.IssueID,
-			&temp.Issue,
-			&temp.UserID,
-			&temp.UserRole,
-			&temp.Read,
-			&temp.CreatedAt)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE issues SET status = $2, read = 't', updated_at = NOW()
+	WHERE issue_id = $1 AND status <> $2`
+
+	result, err := tx.ExecContext(ctx, query, issueID, input.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
 
+	if affected == 0 {
+		// Find out whether the issue is missing or already has the status
+		var exists bool
+		err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM issues WHERE issue_id = $1)`, issueID).Scan(&exists)
 		if err != nil {
 			return nil, err
 		}
 
-		issues = append(issues, temp)
+		if !exists {
+			return nil, ErrRecordNotFound
+		}
+		return nil, ErrNotUpdated
 	}
 
-	// If any  error happened during rows scanning
-	if err = rows.Err(); err != nil {
+	if reply := strings.TrimSpace(input.Reply); reply != "" {
+		_, err = insertReply(ctx, tx, issueID, userID, reply)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
 		return nil, err
 	}
 
-	return &issues, nil
+	return m.GetIssue(issueID)
+}
+
+// Classify sets the category and/or the priority of an issue
+func (m IssuesModel) Classify(issueID int, input *IssueClassificationInput) error {
+
+	query := `UPDATE issues SET category = COALESCE(NULLIF($2, ''), category),
+	priority = COALESCE(NULLIF($3, ''), priority), updated_at = NOW()
+	WHERE issue_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, issueID, input.Category, input.Priority)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// AddReply adds a reply of a superuser to an issue, the issue is also marked as read
+func (m IssuesModel) AddReply(issueID int, userID int64, reply string) (*IssueReply, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE issues SET read = 't', updated_at = NOW() WHERE issue_id = $1`, issueID)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if affected == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	temp, err := insertReply(ctx, tx, issueID, userID, strings.TrimSpace(reply))
+	if err != nil {
+		return nil, err
+	}
+
+	return temp, tx.Commit()
 }
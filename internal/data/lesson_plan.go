@@ -0,0 +1,404 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/roshanlc/soe-backend/internal/validator"
+)
+
+// Maximum number of weeks in a lesson plan
+const MaxLessonWeeks = 30
+
+// Incase a referred book is not a book of the course
+var ErrInvalidBook = errors.New("book is not assigned to the course")
+
+type LessonPlanModel struct {
+	DB *sql.DB
+}
+
+// A book referred in a week of a lesson plan
+type LessonReference struct {
+	BookID int64  `json:"book_id"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	Pages  string `json:"pages"`
+}
+
+// A week of a lesson plan
+type LessonWeek struct {
+	Week        int               `json:"week"`
+	Topics      string            `json:"topics"`
+	Objectives  string            `json:"objectives"`
+	References  []LessonReference `json:"references"`
+	Completed   bool              `json:"completed"`
+	CompletedAt *time.Time        `json:"completed_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// Lesson plan of a course
+type LessonPlan struct {
+	CourseID   int64        `json:"course_id"`
+	CourseCode string       `json:"course_code"`
+	Title      string       `json:"title"`
+	Weeks      []LessonWeek `json:"weeks"`
+}
+
+// Struct to read a reference of a week from a teacher
+type LessonReferenceInput struct {
+	BookID int64  `json:"book_id" binding:"required"`
+	Pages  string `json:"pages"`
+}
+
+// Struct to read a week of a lesson plan from a teacher
+type LessonWeekInput struct {
+	Topics     string                 `json:"topics" binding:"required"`
+	Objectives string                 `json:"objectives"`
+	References []LessonReferenceInput `json:"references" binding:"dive"`
+}
+
+// Completion status of the lesson plan of a course offering
+type LessonPlanStatus struct {
+	CourseID       int64      `json:"course_id"`
+	CourseCode     string     `json:"course_code"`
+	Title          string     `json:"title"`
+	Program        string     `json:"program"`
+	SemesterID     int        `json:"semester_id"`
+	PlannedWeeks   int        `json:"planned_weeks"`
+	CompletedWeeks int        `json:"completed_weeks"`
+	Percentage     float64    `json:"percentage"`
+	LastUpdated    *time.Time `json:"last_updated"`
+}
+
+// ValidateLessonWeek checks a week of a lesson plan
+func ValidateLessonWeek(v *validator.Validator, input *LessonWeekInput) {
+
+	v.Check(strings.TrimSpace(input.Topics) != "", "topics", "must not be empty")
+	v.Check(len(input.Topics) <= 5000, "topics", "must not be more than 5000 bytes long")
+	v.Check(len(input.Objectives) <= 5000, "objectives", "must not be more than 5000 bytes long")
+
+	seen := make(map[int64]bool)
+	for _, ref := range input.References {
+		v.Check(!seen[ref.BookID], "references", "must not contain repeated books")
+		v.Check(len(ref.Pages) <= 200, "pages", "must not be more than 200 bytes long")
+		seen[ref.BookID] = true
+	}
+}
+
+// courseIDByCode returns the id of a course from its code
+func courseIDByCode(ctx context.Context, tx *sql.Tx, courseCode string) (int64, error) {
+
+	var courseID int64
+
+	query := `SELECT course_id FROM courses WHERE LOWER(course_code) = LOWER($1)`
+
+	err := tx.QueryRowContext(ctx, query, courseCode).Scan(&courseID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return courseID, nil
+}
+
+// SetWeek creates or replaces a week of the lesson plan of a course
+// Only a teacher currently teaching the course can change the plan
+func (m LessonPlanModel) SetWeek(userID int64, courseCode string, week int, input *LessonWeekInput) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	courseID, err := courseIDByCode(ctx, tx, courseCode)
+	if err != nil {
+		return err
+	}
+
+	teacherID, err := teacherOfCourse(ctx, tx, userID, int(courseID))
+	if err != nil {
+		return err
+	}
+
+	bookIDs := []int64{}
+	pages := []string{}
+	for _, ref := range input.References {
+		bookIDs = append(bookIDs, ref.BookID)
+		pages = append(pages, ref.Pages)
+	}
+
+	// Referred books must be the books of the course
+	var count int
+	query := `SELECT COUNT(*) FROM course_books WHERE course_id = $1 AND book_id = ANY($2)`
+
+	err = tx.QueryRowContext(ctx, query, courseID, pq.Array(bookIDs)).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count != len(bookIDs) {
+		return ErrInvalidBook
+	}
+
+	query = `INSERT INTO lesson_plans (course_id, week, topics, objectives, teacher_id)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT ON CONSTRAINT lesson_plans_course_week_key DO UPDATE SET
+	topics = EXCLUDED.topics, objectives = EXCLUDED.objectives,
+	teacher_id = EXCLUDED.teacher_id, updated_at = NOW()
+	RETURNING plan_id`
+
+	var planID int64
+	err = tx.QueryRowContext(ctx, query, courseID, week, input.Topics, input.Objectives, teacherID).Scan(&planID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM lesson_plan_references WHERE plan_id = $1`, planID)
+	if err != nil {
+		return err
+	}
+
+	query = `INSERT INTO lesson_plan_references (plan_id, book_id, pages)
+	SELECT $1, UNNEST($2::bigint[]), UNNEST($3::text[])`
+
+	_, err = tx.ExecContext(ctx, query, planID, pq.Array(bookIDs), pq.Array(pages))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// updateWeek runs a query on a week of the lesson plan after checking the teacher
+// The query receives course_id as $1 and week as $2 followed by args
+func (m LessonPlanModel) updateWeek(userID int64, courseCode string, week int, query string, args ...interface{}) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	courseID, err := courseIDByCode(ctx, tx, courseCode)
+	if err != nil {
+		return err
+	}
+
+	_, err = teacherOfCourse(ctx, tx, userID, int(courseID))
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, query, append([]interface{}{courseID, week}, args...)...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return tx.Commit()
+}
+
+// DeleteWeek removes a week from the lesson plan of a course
+func (m LessonPlanModel) DeleteWeek(userID int64, courseCode string, week int) error {
+	return m.updateWeek(userID, courseCode, week, `DELETE FROM lesson_plans WHERE course_id = $1 AND week = $2`)
+}
+
+// SetCompleted marks a week of the lesson plan of a course as completed or not
+func (m LessonPlanModel) SetCompleted(userID int64, courseCode string, week int, completed bool) error {
+
+	query := `UPDATE lesson_plans SET completed = $3,
+	completed_at = CASE WHEN $3 THEN NOW() ELSE NULL END
+	WHERE course_id = $1 AND week = $2`
+
+	return m.updateWeek(userID, courseCode, week, query, completed)
+}
+
+// CanViewPlan checks if a user can view the lesson plan of a course
+// Superusers can view all plans, teachers the courses they currently teach and
+// students the courses of their program and semester
+func (m LessonPlanModel) CanViewPlan(userID int64, role, courseCode string) (bool, error) {
+
+	var query string
+
+	switch role {
+	case "superuser":
+		return true, nil
+	case "teacher":
+		query = `SELECT EXISTS (SELECT 1 FROM teachers
+		INNER JOIN teacher_courses ON teacher_courses.teacher_id = teachers.teacher_id
+		INNER JOIN courses ON courses.course_id = teacher_courses.course_id
+		WHERE teachers.user_id = $1 AND LOWER(courses.course_code) = LOWER($2)
+		AND teacher_courses.expires_at >= CURRENT_DATE)`
+	case "student":
+		query = `SELECT EXISTS (SELECT 1 FROM students
+		INNER JOIN program_courses ON program_courses.program_id = students.program_id
+		AND program_courses.semester_id = students.semester_id
+		INNER JOIN courses ON courses.course_id = program_courses.course_id
+		WHERE students.user_id = $1 AND LOWER(courses.course_code) = LOWER($2))`
+	default:
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var allowed bool
+	err := m.DB.QueryRowContext(ctx, query, userID, courseCode).Scan(&allowed)
+
+	return allowed, err
+}
+
+// GetPlan returns the lesson plan of a course
+func (m LessonPlanModel) GetPlan(courseCode string) (*LessonPlan, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var plan LessonPlan
+
+	query := `SELECT course_id, course_code, title FROM courses WHERE LOWER(course_code) = LOWER($1)`
+
+	err := m.DB.QueryRowContext(ctx, query, courseCode).Scan(&plan.CourseID, &plan.CourseCode, &plan.Title)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	query = `SELECT plan_id, week, topics, objectives, completed, completed_at, updated_at
+	FROM lesson_plans WHERE course_id = $1
+	ORDER BY week`
+
+	rows, err := m.DB.QueryContext(ctx, query, plan.CourseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plan.Weeks = []LessonWeek{}
+	index := make(map[int64]int) // plan_id -> index of week
+
+	for rows.Next() {
+		var planID int64
+		var temp LessonWeek
+
+		err := rows.Scan(&planID, &temp.Week, &temp.Topics, &temp.Objectives, &temp.Completed, &temp.CompletedAt, &temp.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		temp.References = []LessonReference{}
+		index[planID] = len(plan.Weeks)
+		plan.Weeks = append(plan.Weeks, temp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `SELECT lesson_plan_references.plan_id, books.book_id, books.title, books.author, lesson_plan_references.pages
+	FROM lesson_plan_references
+	INNER JOIN lesson_plans ON lesson_plans.plan_id = lesson_plan_references.plan_id
+	INNER JOIN books ON books.book_id = lesson_plan_references.book_id
+	WHERE lesson_plans.course_id = $1
+	ORDER BY books.title`
+
+	refRows, err := m.DB.QueryContext(ctx, query, plan.CourseID)
+	if err != nil {
+		return nil, err
+	}
+	defer refRows.Close()
+
+	for refRows.Next() {
+		var planID int64
+		var temp LessonReference
+
+		err := refRows.Scan(&planID, &temp.BookID, &temp.Title, &temp.Author, &temp.Pages)
+		if err != nil {
+			return nil, err
+		}
+
+		week := &plan.Weeks[index[planID]]
+		week.References = append(week.References, temp)
+	}
+
+	if err = refRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &plan, nil
+}
+
+// GetCompletionStatus returns the completion status of lesson plans of all course offerings
+func (m LessonPlanModel) GetCompletionStatus() (*[]LessonPlanStatus, error) {
+
+	query := `SELECT courses.course_id, courses.course_code, courses.title, programs.name, program_courses.semester_id,
+	COUNT(lesson_plans.plan_id), COUNT(*) FILTER (WHERE lesson_plans.completed), MAX(lesson_plans.updated_at)
+	FROM program_courses
+	INNER JOIN courses ON courses.course_id = program_courses.course_id
+	INNER JOIN programs ON programs.program_id = program_courses.program_id
+	LEFT JOIN lesson_plans ON lesson_plans.course_id = courses.course_id
+	GROUP BY courses.course_id, courses.course_code, courses.title, programs.name, program_courses.semester_id
+	ORDER BY programs.name, program_courses.semester_id, courses.course_code`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statuses := []LessonPlanStatus{}
+
+	for rows.Next() {
+		var temp LessonPlanStatus
+
+		err := rows.Scan(&temp.CourseID, &temp.CourseCode, &temp.Title, &temp.Program, &temp.SemesterID,
+			&temp.PlannedWeeks, &temp.CompletedWeeks, &temp.LastUpdated)
+		if err != nil {
+			return nil, err
+		}
+
+		if temp.PlannedWeeks > 0 {
+			temp.Percentage = roundOff(float64(temp.CompletedWeeks) * 100 / float64(temp.PlannedWeeks))
+		}
+
+		statuses = append(statuses, temp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(statuses) == 0 {
+		return &statuses, ErrNoRecords
+	}
+
+	return &statuses, nil
+}
@@ -0,0 +1,485 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	LoanPeriodDays = 14  // Default number of days a copy can be kept
+	FinePerDay     = 5.0 // Fine (in Rs.) for each day a copy is kept after the due date
+	MaxOpenLoans   = 5   // Max number of copies a user can hold at a time
+)
+
+var (
+	ErrCopyUnavailable  = errors.New("copy is not available")           // Incase a copy is withdrawn or already issued
+	ErrInvalidBorrower  = errors.New("invalid borrower")                // Incase a copy is issued to other than an active student or teacher
+	ErrLoanLimitReached = errors.New("maximum number of loans reached") // Incase the borrower already holds MaxOpenLoans copies
+)
+
+type LibraryModel struct {
+	DB *sql.DB
+}
+
+// A physical copy of a book
+type BookCopy struct {
+	CopyID      int64     `json:"copy_id"`
+	BookID      int64     `json:"book_id"`
+	AccessionNo string    `json:"accession_no"`
+	AddedAt     time.Time `json:"added_at"`
+	Withdrawn   bool      `json:"withdrawn"`
+	Available   bool      `json:"available"`
+}
+
+// Number of copies of a book in the library
+type BookInventory struct {
+	Book
+	TotalCopies int `json:"total_copies"` // excluding withdrawn copies
+	OnLoan      int `json:"on_loan"`
+	Available   int `json:"available"`
+}
+
+// A copy issued to a student or teacher
+type BookLoan struct {
+	LoanID      int64      `json:"loan_id"`
+	CopyID      int64      `json:"copy_id"`
+	AccessionNo string     `json:"accession_no"`
+	BookID      int64      `json:"book_id"`
+	Title       string     `json:"title"`
+	UserID      int64      `json:"user_id"`
+	Borrower    string     `json:"borrower"`
+	Email       string     `json:"-"`
+	IssuedAt    time.Time  `json:"issued_at"`
+	DueDate     time.Time  `json:"due_date"`
+	ReturnedAt  *time.Time `json:"returned_at"`
+	DaysOverdue int        `json:"days_overdue"`
+	Fine        float64    `json:"fine"` // accrued fine for copies not yet returned
+	FinePaid    bool       `json:"fine_paid"`
+}
+
+// Struct to read a new copy of a book
+type BookCopyInput struct {
+	AccessionNo string `json:"accession_no" binding:"required,max=50"`
+}
+
+// Struct to read the issue of a copy
+type BookLoanInput struct {
+	CopyID  int64  `json:"copy_id" binding:"required,min=1"`
+	UserID  int64  `json:"user_id" binding:"required,min=1"`
+	DueDate string `json:"due_date"` // yyyy-mm-dd, LoanPeriodDays from today if empty
+}
+
+// Filters to list loans
+type BookLoanFilters struct {
+	UserID int64
+	Status string // open, overdue or returned
+}
+
+// fineFor returns the fine of a copy kept for the given days after the due date
+func fineFor(daysOverdue int) float64 {
+	return roundOff(float64(daysOverdue) * FinePerDay)
+}
+
+// AddCopy adds a new copy of a book
+func (m LibraryModel) AddCopy(bookID int64, input *BookCopyInput) (*BookCopy, error) {
+
+	query := `INSERT INTO book_copies (book_id, accession_no) VALUES ($1, $2)
+	RETURNING copy_id, added_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	bookCopy := BookCopy{BookID: bookID, AccessionNo: strings.TrimSpace(input.AccessionNo), Available: true}
+
+	err := m.DB.QueryRowContext(ctx, query, bookID, bookCopy.AccessionNo).Scan(&bookCopy.CopyID, &bookCopy.AddedAt)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "book_copies_accession_no_key"):
+			return nil, ErrDuplicateEntry
+		case strings.Contains(err.Error(), "book_copies_book_id_fkey"):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &bookCopy, nil
+}
+
+// GetCopies returns the copies of a book
+func (m LibraryModel) GetCopies(bookID int64) (*[]BookCopy, error) {
+
+	query := `SELECT book_copies.copy_id, book_copies.book_id, book_copies.accession_no,
+	book_copies.added_at, book_copies.withdrawn,
+	NOT book_copies.withdrawn AND book_loans.loan_id IS NULL
+	FROM book_copies
+	LEFT JOIN book_loans ON book_loans.copy_id = book_copies.copy_id AND book_loans.returned_at IS NULL
+	WHERE book_copies.book_id = $1
+	ORDER BY book_copies.accession_no`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	copies := []BookCopy{}
+
+	for rows.Next() {
+		var temp BookCopy
+
+		err := rows.Scan(&temp.CopyID, &temp.BookID, &temp.AccessionNo, &temp.AddedAt, &temp.Withdrawn, &temp.Available)
+		if err != nil {
+			return nil, err
+		}
+
+		copies = append(copies, temp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(copies) == 0 {
+		return &copies, ErrNoRecords
+	}
+
+	return &copies, nil
+}
+
+// WithdrawCopy withdraws a lost or damaged copy from circulation
+// A copy that is currently issued can not be withdrawn
+func (m LibraryModel) WithdrawCopy(copyID int64) error {
+
+	query := `SELECT withdrawn,
+	EXISTS (SELECT 1 FROM book_loans WHERE copy_id = $1 AND returned_at IS NULL)
+	FROM book_copies WHERE copy_id = $1
+	FOR UPDATE`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var withdrawn, onLoan bool
+
+	err = tx.QueryRowContext(ctx, query, copyID).Scan(&withdrawn, &onLoan)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	if withdrawn || onLoan {
+		return ErrCopyUnavailable
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE book_copies SET withdrawn = true WHERE copy_id = $1`, copyID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetInventory returns the number of copies of every book
+func (m LibraryModel) GetInventory() (*[]BookInventory, error) {
+
+	query := `SELECT books.book_id, books.title, books.author, books.edition, books.publication,
+	COUNT(book_copies.copy_id),
+	COUNT(book_loans.loan_id)
+	FROM books
+	LEFT JOIN book_copies ON book_copies.book_id = books.book_id AND NOT book_copies.withdrawn
+	LEFT JOIN book_loans ON book_loans.copy_id = book_copies.copy_id AND book_loans.returned_at IS NULL
+	GROUP BY books.book_id
+	ORDER BY books.title`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	inventory := []BookInventory{}
+
+	for rows.Next() {
+		var temp BookInventory
+
+		err := rows.Scan(&temp.BookID, &temp.Title, &temp.Author, &temp.Edition, &temp.Publication,
+			&temp.TotalCopies, &temp.OnLoan)
+		if err != nil {
+			return nil, err
+		}
+
+		temp.Available = temp.TotalCopies - temp.OnLoan
+		inventory = append(inventory, temp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(inventory) == 0 {
+		return &inventory, ErrNoRecords
+	}
+
+	return &inventory, nil
+}
+
+// IssueCopy issues a copy to an activated student or teacher
+func (m LibraryModel) IssueCopy(input *BookLoanInput, dueDate time.Time, issuedBy int64) (*BookLoan, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT EXISTS (SELECT 1 FROM users
+	INNER JOIN user_roles ON user_roles.user_id = users.user_id
+	INNER JOIN roles ON roles.role_id = user_roles.role_id
+	WHERE users.user_id = $1 AND users.activated AND NOT users.expired
+	AND roles.name IN ('student', 'teacher'))`
+
+	var valid bool
+	err = tx.QueryRowContext(ctx, query, input.UserID).Scan(&valid)
+	if err != nil {
+		return nil, err
+	}
+
+	if !valid {
+		return nil, ErrInvalidBorrower
+	}
+
+	var withdrawn bool
+	err = tx.QueryRowContext(ctx, `SELECT withdrawn FROM book_copies WHERE copy_id = $1 FOR UPDATE`, input.CopyID).Scan(&withdrawn)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if withdrawn {
+		return nil, ErrCopyUnavailable
+	}
+
+	var openLoans int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM book_loans WHERE user_id = $1 AND returned_at IS NULL`,
+		input.UserID).Scan(&openLoans)
+	if err != nil {
+		return nil, err
+	}
+
+	if openLoans >= MaxOpenLoans {
+		return nil, ErrLoanLimitReached
+	}
+
+	query = `INSERT INTO book_loans (copy_id, user_id, issued_by, due_date)
+	VALUES ($1, $2, $3, $4)
+	RETURNING loan_id`
+
+	var loanID int64
+	err = tx.QueryRowContext(ctx, query, input.CopyID, input.UserID, issuedBy, dueDate).Scan(&loanID)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "book_loans_open_copy_key"):
+			return nil, ErrCopyUnavailable
+		default:
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return m.GetLoan(loanID)
+}
+
+// ReturnCopy records the return of an issued copy along with its fine
+func (m LibraryModel) ReturnCopy(loanID int64) (*BookLoan, error) {
+
+	query := `UPDATE book_loans SET returned_at = NOW(),
+	fine = GREATEST(CURRENT_DATE - due_date, 0) * $2::numeric
+	WHERE loan_id = $1 AND returned_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, loanID, FinePerDay)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if affected == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	return m.GetLoan(loanID)
+}
+
+// PayFine records the payment of the fine of a returned copy
+func (m LibraryModel) PayFine(loanID int64) error {
+
+	query := `UPDATE book_loans SET fine_paid = true
+	WHERE loan_id = $1 AND returned_at IS NOT NULL AND fine > 0 AND NOT fine_paid`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, loanID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Common query to select loans
+const bookLoanQuery = `SELECT book_loans.loan_id, book_loans.copy_id, book_copies.accession_no,
+	books.book_id, books.title, book_loans.user_id, COALESCE(students.name, teachers.name, ''), users.email,
+	book_loans.issued_at, book_loans.due_date, book_loans.returned_at,
+	GREATEST(COALESCE(book_loans.returned_at::date, CURRENT_DATE) - book_loans.due_date, 0),
+	book_loans.fine, book_loans.fine_paid
+	FROM book_loans
+	INNER JOIN book_copies ON book_copies.copy_id = book_loans.copy_id
+	INNER JOIN books ON books.book_id = book_copies.book_id
+	INNER JOIN users ON users.user_id = book_loans.user_id
+	LEFT JOIN students ON students.user_id = book_loans.user_id
+	LEFT JOIN teachers ON teachers.user_id = book_loans.user_id `
+
+// listLoans returns the loans selected by a query built on bookLoanQuery
+func (m LibraryModel) listLoans(query string, args ...interface{}) (*[]BookLoan, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	loans := []BookLoan{}
+
+	for rows.Next() {
+		var temp BookLoan
+
+		err := rows.Scan(&temp.LoanID, &temp.CopyID, &temp.AccessionNo, &temp.BookID, &temp.Title,
+			&temp.UserID, &temp.Borrower, &temp.Email, &temp.IssuedAt, &temp.DueDate, &temp.ReturnedAt,
+			&temp.DaysOverdue, &temp.Fine, &temp.FinePaid)
+		if err != nil {
+			return nil, err
+		}
+
+		// The fine is only stored on return
+		if temp.ReturnedAt == nil {
+			temp.Fine = fineFor(temp.DaysOverdue)
+		}
+
+		loans = append(loans, temp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(loans) == 0 {
+		return &loans, ErrNoRecords
+	}
+
+	return &loans, nil
+}
+
+// GetLoan returns a loan
+func (m LibraryModel) GetLoan(loanID int64) (*BookLoan, error) {
+
+	loans, err := m.listLoans(bookLoanQuery+`WHERE book_loans.loan_id = $1`, loanID)
+	if err != nil {
+		switch err {
+		case ErrNoRecords:
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &(*loans)[0], nil
+}
+
+// GetLoans returns the loans matching the filters, latest first
+func (m LibraryModel) GetLoans(filters BookLoanFilters) (*[]BookLoan, error) {
+
+	query := bookLoanQuery + `WHERE (book_loans.user_id = $1 OR $1 = 0)
+	AND CASE $2
+		WHEN 'open' THEN book_loans.returned_at IS NULL
+		WHEN 'overdue' THEN book_loans.returned_at IS NULL AND book_loans.due_date < CURRENT_DATE
+		WHEN 'returned' THEN book_loans.returned_at IS NOT NULL
+		ELSE true
+	END
+	ORDER BY book_loans.issued_at DESC`
+
+	return m.listLoans(query, filters.UserID, filters.Status)
+}
+
+// GetUnremindedOverdueLoans returns the overdue loans whose borrowers have not been reminded today
+func (m LibraryModel) GetUnremindedOverdueLoans() (*[]BookLoan, error) {
+
+	query := bookLoanQuery + `WHERE book_loans.returned_at IS NULL
+	AND book_loans.due_date < CURRENT_DATE
+	AND (book_loans.reminded_on IS NULL OR book_loans.reminded_on < CURRENT_DATE)
+	ORDER BY book_loans.due_date`
+
+	return m.listLoans(query)
+}
+
+// MarkReminded records that the borrowers of the loans have been reminded today
+func (m LibraryModel) MarkReminded(loanIDs []int64) error {
+
+	query := `UPDATE book_loans SET reminded_on = CURRENT_DATE WHERE loan_id = ANY($1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, pq.Array(loanIDs))
+
+	return err
+}
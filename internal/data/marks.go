@@ -0,0 +1,407 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/roshanlc/soe-backend/internal/validator"
+)
+
+// Full marks of the internal and final assessments of a course
+const (
+	InternalFullMarks = 50
+	FinalFullMarks    = 50
+)
+
+// Incase marks of a locked offering are changed
+var ErrMarksLocked = errors.New("marks are locked")
+
+type MarksModel struct {
+	DB *sql.DB
+}
+
+// Marks of a single student, identified by their symbol number
+// A missing internal or final mark keeps the previously uploaded value
+type MarkEntry struct {
+	SymbolNo int64    `json:"symbol_no" binding:"required"`
+	Internal *float64 `json:"internal"`
+	Final    *float64 `json:"final"`
+}
+
+// Struct to read marks of a course offering from a teacher
+type MarksUpload struct {
+	ProgramID  int         `json:"program_id" binding:"required"`
+	SemesterID int         `json:"semester_id" binding:"required"`
+	CourseID   int         `json:"course_id" binding:"required"`
+	Session    int         `json:"session" binding:"omitempty,min=2000,max=2100"`
+	Marks      []MarkEntry `json:"marks" binding:"required,min=1,dive"`
+}
+
+// A course offering i.e a course taught in a semester of a program
+// in an academic session, the current session if it is not provided
+type Offering struct {
+	ProgramID  int `json:"program_id" binding:"required"`
+	SemesterID int `json:"semester_id" binding:"required"`
+	CourseID   int `json:"course_id" binding:"required"`
+	Session    int `json:"session" binding:"omitempty,min=2000,max=2100"`
+}
+
+// CurrentSession returns the academic session (year) of marks uploaded now
+func CurrentSession() int {
+	return time.Now().Year()
+}
+
+// Marks of a student in a course
+type CourseMarks struct {
+	CourseID    int      `json:"course_id"`
+	CourseCode  string   `json:"course_code"`
+	CourseTitle string   `json:"course_title"`
+	Credit      int      `json:"credit"`
+	SemesterID  int      `json:"semester_id"`
+	Session     int      `json:"session"`
+	Internal    *float64 `json:"internal"`
+	Final       *float64 `json:"final"`
+	Total       float64  `json:"total"`
+	Locked      bool     `json:"locked"`
+}
+
+// Marks of a student in a course offering
+type StudentMarks struct {
+	StudentID int64    `json:"student_id"`
+	Name      string   `json:"name"`
+	SymbolNo  int64    `json:"symbol_no"`
+	Internal  *float64 `json:"internal"`
+	Final     *float64 `json:"final"`
+	Total     float64  `json:"total"`
+}
+
+// Marks of all the students of a course offering
+type OfferingMarks struct {
+	Offering
+	Locked bool           `json:"locked"`
+	Marks  []StudentMarks `json:"marks"`
+}
+
+// ValidateMarks checks that the marks are within the full marks and symbol numbers are not repeated
+func ValidateMarks(v *validator.Validator, marks []MarkEntry) {
+
+	seen := make(map[int64]bool)
+
+	for _, entry := range marks {
+		v.Check(!seen[entry.SymbolNo], "symbol_no", "must not be repeated")
+		seen[entry.SymbolNo] = true
+
+		if entry.Internal != nil {
+			v.Check(*entry.Internal >= 0 && *entry.Internal <= InternalFullMarks, "internal", "must be between 0 and 50")
+		}
+		if entry.Final != nil {
+			v.Check(*entry.Final >= 0 && *entry.Final <= FinalFullMarks, "final", "must be between 0 and 50")
+		}
+	}
+}
+
+// totalMarks adds up the internal and final marks
+func totalMarks(internal, final *float64) float64 {
+	var total float64
+	if internal != nil {
+		total += *internal
+	}
+	if final != nil {
+		total += *final
+	}
+	return total
+}
+
+// isOffered checks if the course is offered in the semester of the program
+func isOffered(ctx context.Context, tx *sql.Tx, offering *Offering) (bool, error) {
+
+	query := `SELECT EXISTS (SELECT 1 FROM program_courses
+	WHERE program_id = $1 AND semester_id = $2 AND course_id = $3)`
+
+	var exists bool
+	err := tx.QueryRowContext(ctx, query, offering.ProgramID, offering.SemesterID, offering.CourseID).Scan(&exists)
+
+	return exists, err
+}
+
+// UploadMarks inserts or updates the marks of students of a course offering
+// Only a teacher currently teaching the course can upload marks of an unlocked offering
+func (m MarksModel) UploadMarks(userID int64, upload *MarksUpload) error {
+
+	offering := Offering{ProgramID: upload.ProgramID, SemesterID: upload.SemesterID, CourseID: upload.CourseID, Session: upload.Session}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	teacherID, err := teacherOfCourse(ctx, tx, userID, upload.CourseID)
+	if err != nil {
+		return err
+	}
+
+	offered, err := isOffered(ctx, tx, &offering)
+	if err != nil {
+		return err
+	}
+	if !offered {
+		return ErrRecordNotFound
+	}
+
+	// Locked marks cannot be changed
+	query := `SELECT EXISTS (SELECT 1 FROM marks_locks
+	WHERE program_id = $1 AND semester_id = $2 AND course_id = $3 AND session = $4)`
+
+	var locked bool
+	err = tx.QueryRowContext(ctx, query, upload.ProgramID, upload.SemesterID, upload.CourseID, upload.Session).Scan(&locked)
+	if err != nil {
+		return err
+	}
+	if locked {
+		return ErrMarksLocked
+	}
+
+	symbolNos := []int64{}
+	for _, entry := range upload.Marks {
+		symbolNos = append(symbolNos, entry.SymbolNo)
+	}
+
+	// Map symbol numbers to students of the program and semester
+	query = `SELECT symbol_no, student_id FROM students
+	WHERE program_id = $1 AND semester_id = $2 AND symbol_no = ANY($3)`
+
+	rows, err := tx.QueryContext(ctx, query, upload.ProgramID, upload.SemesterID, pq.Array(symbolNos))
+	if err != nil {
+		return err
+	}
+
+	students := make(map[int64]int64)
+	for rows.Next() {
+		var symbolNo, studentID int64
+		if err := rows.Scan(&symbolNo, &studentID); err != nil {
+			rows.Close()
+			return err
+		}
+		students[symbolNo] = studentID
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if len(students) != len(symbolNos) {
+		return ErrInvalidStudent
+	}
+
+	// The marks of earlier sessions are kept, a re-taken course gets new marks
+	query = `INSERT INTO marks (student_id, course_id, program_id, semester_id, session, internal, final, teacher_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT ON CONSTRAINT marks_pkey DO UPDATE SET
+	internal = COALESCE(EXCLUDED.internal, marks.internal),
+	final = COALESCE(EXCLUDED.final, marks.final),
+	teacher_id = EXCLUDED.teacher_id,
+	updated_at = NOW()`
+
+	for _, entry := range upload.Marks {
+		_, err = tx.ExecContext(ctx, query, students[entry.SymbolNo], upload.CourseID, upload.ProgramID,
+			upload.SemesterID, upload.Session, entry.Internal, entry.Final, teacherID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetOfferingMarks returns the marks of all students of a course offering
+// If userID is not zero, the user must be currently teaching the course
+func (m MarksModel) GetOfferingMarks(userID int64, offering *Offering) (*OfferingMarks, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if userID != 0 {
+		_, err = teacherOfCourse(ctx, tx, userID, offering.CourseID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	offered, err := isOffered(ctx, tx, offering)
+	if err != nil {
+		return nil, err
+	}
+	if !offered {
+		return nil, ErrRecordNotFound
+	}
+
+	result := OfferingMarks{Offering: *offering, Marks: []StudentMarks{}}
+
+	query := `SELECT EXISTS (SELECT 1 FROM marks_locks
+	WHERE program_id = $1 AND semester_id = $2 AND course_id = $3 AND session = $4)`
+
+	err = tx.QueryRowContext(ctx, query, offering.ProgramID, offering.SemesterID, offering.CourseID, offering.Session).Scan(&result.Locked)
+	if err != nil {
+		return nil, err
+	}
+
+	// Students without marks are listed with null marks
+	query = `SELECT students.student_id, students.name, students.symbol_no, marks.internal, marks.final
+	FROM students
+	LEFT JOIN marks ON marks.student_id = students.student_id AND marks.course_id = $3
+	AND marks.program_id = $1 AND marks.semester_id = $2 AND marks.session = $4
+	WHERE students.program_id = $1 AND students.semester_id = $2
+	ORDER BY students.symbol_no`
+
+	rows, err := tx.QueryContext(ctx, query, offering.ProgramID, offering.SemesterID, offering.CourseID, offering.Session)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var temp StudentMarks
+
+		err := rows.Scan(&temp.StudentID, &temp.Name, &temp.SymbolNo, &temp.Internal, &temp.Final)
+		if err != nil {
+			return nil, err
+		}
+
+		temp.Total = totalMarks(temp.Internal, temp.Final)
+		result.Marks = append(result.Marks, temp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetStudentMarks returns the marks of a student in all courses, every attempt of a re-taken course included
+// Only the locked (published) marks are returned, the rest may still be changed by teachers
+func (m MarksModel) GetStudentMarks(userID int64) (*[]CourseMarks, error) {
+
+	query := `SELECT courses.course_id, courses.course_code, courses.title, courses.credit,
+	marks.semester_id, marks.session, marks.internal, marks.final, (marks_locks.course_id IS NOT NULL)
+	FROM students
+	INNER JOIN marks ON marks.student_id = students.student_id
+	INNER JOIN courses ON courses.course_id = marks.course_id
+	INNER JOIN marks_locks ON marks_locks.program_id = marks.program_id
+	AND marks_locks.semester_id = marks.semester_id AND marks_locks.course_id = marks.course_id
+	AND marks_locks.session = marks.session
+	WHERE students.user_id = $1
+	ORDER BY marks.semester_id, courses.course_code, marks.session`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	marks := []CourseMarks{}
+
+	for rows.Next() {
+		var temp CourseMarks
+
+		err := rows.Scan(&temp.CourseID, &temp.CourseCode, &temp.CourseTitle, &temp.Credit,
+			&temp.SemesterID, &temp.Session, &temp.Internal, &temp.Final, &temp.Locked)
+		if err != nil {
+			return nil, err
+		}
+
+		temp.Total = totalMarks(temp.Internal, temp.Final)
+		marks = append(marks, temp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(marks) == 0 {
+		return &marks, ErrNoRecords
+	}
+
+	return &marks, nil
+}
+
+// LockMarks locks the marks of a course offering in a session, teachers can no longer change them
+func (m MarksModel) LockMarks(offering *Offering, lockedBy int64) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	offered, err := isOffered(ctx, tx, offering)
+	if err != nil {
+		return err
+	}
+	if !offered {
+		return ErrRecordNotFound
+	}
+
+	query := `INSERT INTO marks_locks (program_id, semester_id, course_id, session, locked_by)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT ON CONSTRAINT marks_locks_pkey DO NOTHING`
+
+	result, err := tx.ExecContext(ctx, query, offering.ProgramID, offering.SemesterID, offering.CourseID, offering.Session, lockedBy)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrDuplicateEntry
+	}
+
+	return tx.Commit()
+}
+
+// UnlockMarks unlocks the marks of a course offering in a session
+func (m MarksModel) UnlockMarks(offering *Offering) error {
+
+	query := `DELETE FROM marks_locks WHERE program_id = $1 AND semester_id = $2 AND course_id = $3 AND session = $4`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, offering.ProgramID, offering.SemesterID, offering.CourseID, offering.Session)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,385 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Incase the recipient of a message does not exist or is the sender
+var ErrInvalidRecipient = errors.New("invalid recipient")
+
+type MessageModel struct {
+	DB *sql.DB
+}
+
+// The other participant of a conversation
+type Participant struct {
+	UserID int64  `json:"user_id"`
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+}
+
+// A conversation thread between two users, as seen by one of them
+type Conversation struct {
+	ConversationID int64       `json:"conversation_id"`
+	With           Participant `json:"with"`
+	LastMessage    string      `json:"last_message"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+	Unread         int         `json:"unread"` // messages not yet read by the user
+}
+
+// A message of a conversation, ReadAt is the read receipt
+type Message struct {
+	MessageID      int64      `json:"message_id"`
+	ConversationID int64      `json:"conversation_id"`
+	SenderID       int64      `json:"sender_id"`
+	Body           string     `json:"body"`
+	SentAt         time.Time  `json:"sent_at"`
+	ReadAt         *time.Time `json:"read_at"`
+}
+
+// Struct to read a message to a user
+type MessageInput struct {
+	RecipientID int64  `json:"recipient_id" binding:"required,min=1"`
+	Body        string `json:"body" binding:"required,max=5000"`
+}
+
+// Struct to read a reply in a conversation
+type ReplyInput struct {
+	Body string `json:"body" binding:"required,max=5000"`
+}
+
+// canMessage reports whether two users may message each other.
+// Superusers may message anyone and teachers may message each other.
+// Students and teachers may message each other only if the teacher currently
+// teaches a course of the student's program. Students may not message each other.
+func canMessage(ctx context.Context, tx *sql.Tx, senderID, recipientID int64) (bool, error) {
+
+	query := `SELECT user_roles.user_id, roles.name FROM user_roles
+	INNER JOIN roles ON roles.role_id = user_roles.role_id
+	WHERE user_roles.user_id IN ($1, $2)`
+
+	rows, err := tx.QueryContext(ctx, query, senderID, recipientID)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	roles := map[int64]string{}
+
+	for rows.Next() {
+		var userID int64
+		var role string
+		if err := rows.Scan(&userID, &role); err != nil {
+			return false, err
+		}
+		roles[userID] = role
+	}
+
+	if err = rows.Err(); err != nil {
+		return false, err
+	}
+
+	if _, exists := roles[recipientID]; !exists {
+		return false, ErrInvalidRecipient
+	}
+
+	sender, recipient := roles[senderID], roles[recipientID]
+
+	switch {
+	case sender == "superuser" || recipient == "superuser":
+		return true, nil
+	case sender == "teacher" && recipient == "teacher":
+		return true, nil
+	case sender == "student" && recipient == "teacher":
+		return teachesProgramOf(ctx, tx, recipientID, senderID)
+	case sender == "teacher" && recipient == "student":
+		return teachesProgramOf(ctx, tx, senderID, recipientID)
+	default:
+		return false, nil
+	}
+}
+
+// teachesProgramOf reports whether a teacher currently teaches a course of the program of a student
+func teachesProgramOf(ctx context.Context, tx *sql.Tx, teacherUserID, studentUserID int64) (bool, error) {
+
+	query := `SELECT EXISTS (SELECT 1 FROM students
+	INNER JOIN program_courses ON program_courses.program_id = students.program_id
+	INNER JOIN teacher_courses ON teacher_courses.course_id = program_courses.course_id
+	INNER JOIN teachers ON teachers.teacher_id = teacher_courses.teacher_id
+	WHERE students.user_id = $1 AND teachers.user_id = $2
+	AND teacher_courses.expires_at >= CURRENT_DATE)`
+
+	var exists bool
+	err := tx.QueryRowContext(ctx, query, studentUserID, teacherUserID).Scan(&exists)
+
+	return exists, err
+}
+
+// otherParticipant returns the other participant of a conversation of the user
+func otherParticipant(ctx context.Context, tx *sql.Tx, userID, conversationID int64) (int64, error) {
+
+	query := `SELECT CASE WHEN user_a = $2 THEN user_b ELSE user_a END
+	FROM conversations
+	WHERE conversation_id = $1 AND $2 IN (user_a, user_b)`
+
+	var otherID int64
+
+	err := tx.QueryRowContext(ctx, query, conversationID, userID).Scan(&otherID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return otherID, nil
+}
+
+// send adds a message to the conversation of the two users, the conversation is started if needed
+func send(ctx context.Context, tx *sql.Tx, senderID, recipientID int64, body string) (*Message, error) {
+
+	if senderID == recipientID {
+		return nil, ErrInvalidRecipient
+	}
+
+	allowed, err := canMessage(ctx, tx, senderID, recipientID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !allowed {
+		return nil, ErrNotPermitted
+	}
+
+	query := `INSERT INTO conversations (user_a, user_b)
+	VALUES (LEAST($1::bigint, $2::bigint), GREATEST($1::bigint, $2::bigint))
+	ON CONFLICT ON CONSTRAINT conversations_users_key DO UPDATE SET updated_at = NOW()
+	RETURNING conversation_id`
+
+	message := Message{SenderID: senderID, Body: body}
+
+	err = tx.QueryRowContext(ctx, query, senderID, recipientID).Scan(&message.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `INSERT INTO messages (conversation_id, sender_id, body)
+	VALUES ($1, $2, $3)
+	RETURNING message_id, sent_at`
+
+	err = tx.QueryRowContext(ctx, query, message.ConversationID, senderID, body).Scan(&message.MessageID, &message.SentAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &message, nil
+}
+
+// Send sends a message to a user, in the conversation of the two users
+func (m MessageModel) Send(senderID int64, input *MessageInput) (*Message, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	message, err := send(ctx, tx, senderID, input.RecipientID, input.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return message, tx.Commit()
+}
+
+// Reply sends a message in a conversation of the user
+// The messaging rules are checked again, so that a reply is not sent once they no longer hold
+func (m MessageModel) Reply(senderID, conversationID int64, input *ReplyInput) (*Message, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	recipientID, err := otherParticipant(ctx, tx, senderID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := send(ctx, tx, senderID, recipientID, input.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return message, tx.Commit()
+}
+
+// GetConversations returns the conversations of a user, latest first
+func (m MessageModel) GetConversations(userID int64) ([]Conversation, error) {
+
+	query := `SELECT conversations.conversation_id, others.user_id,
+	COALESCE(students.name, teachers.name, superusers.name, ''), COALESCE(roles.name, ''),
+	latest.body, conversations.updated_at,
+	(SELECT COUNT(*) FROM messages
+		WHERE messages.conversation_id = conversations.conversation_id
+		AND messages.sender_id <> $1 AND messages.read_at IS NULL)
+	FROM conversations
+	INNER JOIN users others ON others.user_id =
+		CASE WHEN conversations.user_a = $1 THEN conversations.user_b ELSE conversations.user_a END
+	LEFT JOIN students ON students.user_id = others.user_id
+	LEFT JOIN teachers ON teachers.user_id = others.user_id
+	LEFT JOIN superusers ON superusers.user_id = others.user_id
+	LEFT JOIN user_roles ON user_roles.user_id = others.user_id
+	LEFT JOIN roles ON roles.role_id = user_roles.role_id
+	INNER JOIN LATERAL (SELECT body FROM messages
+		WHERE messages.conversation_id = conversations.conversation_id
+		ORDER BY message_id DESC LIMIT 1) latest ON true
+	WHERE $1 IN (conversations.user_a, conversations.user_b)
+	ORDER BY conversations.updated_at DESC, conversations.conversation_id DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []Conversation{}
+
+	for rows.Next() {
+		var temp Conversation
+
+		err := rows.Scan(&temp.ConversationID, &temp.With.UserID, &temp.With.Name, &temp.With.Role,
+			&temp.LastMessage, &temp.UpdatedAt, &temp.Unread)
+		if err != nil {
+			return nil, err
+		}
+
+		conversations = append(conversations, temp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return conversations, nil
+}
+
+// GetMessages returns a page of the messages of a conversation of the user, latest first
+func (m MessageModel) GetMessages(userID, conversationID int64, filters Filters) ([]Message, Metadata, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer tx.Rollback()
+
+	_, err = otherParticipant(ctx, tx, userID, conversationID)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	query := `SELECT COUNT(*) OVER(), message_id, conversation_id, sender_id, body, sent_at, read_at
+	FROM messages
+	WHERE conversation_id = $1
+	ORDER BY message_id DESC
+	LIMIT $2 OFFSET $3`
+
+	rows, err := tx.QueryContext(ctx, query, conversationID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	messages := []Message{}
+
+	for rows.Next() {
+		var temp Message
+
+		err := rows.Scan(&totalRecords, &temp.MessageID, &temp.ConversationID, &temp.SenderID,
+			&temp.Body, &temp.SentAt, &temp.ReadAt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		messages = append(messages, temp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return messages, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// MarkRead marks the messages received by the user in a conversation as read
+// It returns the number of messages marked
+func (m MessageModel) MarkRead(userID, conversationID int64) (int64, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = otherParticipant(ctx, tx, userID, conversationID)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `UPDATE messages SET read_at = NOW()
+	WHERE conversation_id = $1 AND sender_id <> $2 AND read_at IS NULL`
+
+	result, err := tx.ExecContext(ctx, query, conversationID, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return affected, tx.Commit()
+}
+
+// GetUnreadCounts returns the number of unread messages of a user
+// and the number of conversations having them
+func (m MessageModel) GetUnreadCounts(userID int64) (int, int, error) {
+
+	query := `SELECT COUNT(*), COUNT(DISTINCT messages.conversation_id)
+	FROM messages
+	INNER JOIN conversations ON conversations.conversation_id = messages.conversation_id
+	WHERE $1 IN (conversations.user_a, conversations.user_b)
+	AND messages.sender_id <> $1 AND messages.read_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var messages, conversations int
+
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&messages, &conversations)
+
+	return messages, conversations, err
+}
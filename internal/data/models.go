@@ -15,32 +15,55 @@ var (
 	ErrOldPasswordMisMatch = errors.New("old password does not match")    // Incase of old password mismatch during password change
 	ErrNotUpdated          = errors.New("the change was not successfull") // Incase of failure while changing info
 	ErrDuplicateEntry      = errors.New("duplicate entry denied")         // Incase of duplicate entry
+	ErrNotPermitted        = errors.New("operation not permitted")        // Incase an operation is not allowed on a record
 )
 
 // All models within a single wrapper struct
 type Models struct {
-	Notices  NoticeModel   // Notice model
-	Courses  CourseModel   // Course Model
-	Users    UserModel     // User model
-	Tokens   TokenModel    // Token Model
-	Roles    RoleModel     // Role Model
-	Programs ProgramModel  // Programs model
-	Schedule ScheduleModel // Schedule Model
-	Issues   IssuesModel   // Issue Model
-	Profiles ProfileModel  // Profile Model
+	Notices      NoticeModel      // Notice model
+	Courses      CourseModel      // Course Model
+	Users        UserModel        // User model
+	Tokens       TokenModel       // Token Model
+	Roles        RoleModel        // Role Model
+	Programs     ProgramModel     // Programs model
+	Schedule     ScheduleModel    // Schedule Model
+	Issues       IssuesModel      // Issue Model
+	Profiles     ProfileModel     // Profile Model
+	Attendance   AttendanceModel  // Attendance Model
+	Marks        MarksModel       // Marks Model
+	Grades       GradesModel      // Grades Model
+	ExamForms    ExamFormModel    // Exam Form Model
+	Certificates CertificateModel // Certificate Model
+	LessonPlans  LessonPlanModel  // Lesson Plan Model
+	Library      LibraryModel     // Library Model
+	Books        BookModel        // Book Model
+	Assignments  AssignmentModel  // Teacher Course Assignment Model
+	Messages     MessageModel     // Message Model
+	Admissions   AdmissionModel   // Admission Model
 }
 
 // Returns a models object
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Notices:  NoticeModel{DB: db}, // NoticeModel
-		Courses:  CourseModel{DB: db}, // Course Model
-		Users:    UserModel{DB: db},   // User Model
-		Tokens:   TokenModel{DB: db},  // Token Model
-		Roles:    RoleModel{DB: db},
-		Programs: ProgramModel{DB: db},
-		Schedule: ScheduleModel{DB: db},
-		Issues:   IssuesModel{DB: db},
-		Profiles: ProfileModel{DB: db},
+		Notices:      NoticeModel{DB: db}, // NoticeModel
+		Courses:      CourseModel{DB: db}, // Course Model
+		Users:        UserModel{DB: db},   // User Model
+		Tokens:       TokenModel{DB: db},  // Token Model
+		Roles:        RoleModel{DB: db},
+		Programs:     ProgramModel{DB: db},
+		Schedule:     ScheduleModel{DB: db},
+		Issues:       IssuesModel{DB: db},
+		Profiles:     ProfileModel{DB: db},
+		Attendance:   AttendanceModel{DB: db},
+		Marks:        MarksModel{DB: db},
+		Grades:       GradesModel{DB: db},
+		ExamForms:    ExamFormModel{DB: db},
+		Certificates: CertificateModel{DB: db},
+		LessonPlans:  LessonPlanModel{DB: db},
+		Library:      LibraryModel{DB: db},
+		Books:        BookModel{DB: db},
+		Assignments:  AssignmentModel{DB: db},
+		Messages:     MessageModel{DB: db},
+		Admissions:   AdmissionModel{DB: db},
 	}
 }
@@ -7,6 +7,8 @@ import (
 	"log"
 	"strings"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 // Struct to hold info about degree levels
@@ -29,6 +31,24 @@ type Program struct {
 	Level      string `json:"level"`
 }
 
+// Struct to hold info about a student in promotion report
+type PromotedStudent struct {
+	UserID    int64  `json:"user_id"`
+	StudentID int64  `json:"student_id"`
+	Name      string `json:"name"`
+	SymbolNo  int64  `json:"symbol_no"`
+}
+
+// Struct to hold the report of a semester promotion
+type PromotionReport struct {
+	ProgramID    int               `json:"program_id"`
+	FromSemester int               `json:"from_semester"`
+	ToSemester   int               `json:"to_semester"`
+	Promoted     []PromotedStudent `json:"promoted"`
+	HeldBack     []PromotedStudent `json:"held_back"`
+	Ended        bool              `json:"from_semester_ended"` // the semester is no longer running
+}
+
 // Struct to hold info about faculty
 type Faculty struct {
 	FacultyID int    `json:"faculty_id"`
@@ -44,6 +64,9 @@ type Department struct {
 	Faculty        string `json:"faculty"`
 }
 
+// Returned when an excluded user is not a student of the semester being promoted
+var ErrInvalidExclusion = errors.New("excluded user is not a student of the semester")
+
 // A wrapper struct around a *sql.DB conn
 type ProgramModel struct {
 	DB *sql.DB
@@ -117,15 +140,28 @@ func (m ProgramModel) GetAFaculty(facultyID int) (*Faculty, error) {
 
 }
 
+// execer is satisfied by both *sql.DB and *sql.Tx,
+// so that a query can run within or outside a transaction
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
 // AddRunningSemester adds a  running semester for a program
 func (m ProgramModel) AddRunningSemester(programID, semesterID int) error {
 
-	query := `INSERT INTO running_semesters (program_id, semester_id) VALUES ($1, $2)`
 	// Timeout context
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	_, err := m.DB.ExecContext(ctx, query, programID, semesterID)
+	return addRunningSemester(ctx, m.DB, programID, semesterID)
+}
+
+// addRunningSemester inserts a running semester using the provided db or transaction
+func addRunningSemester(ctx context.Context, db execer, programID, semesterID int) error {
+
+	query := `INSERT INTO running_semesters (program_id, semester_id) VALUES ($1, $2)`
+
+	_, err := db.ExecContext(ctx, query, programID, semesterID)
 
 	if err != nil {
 		switch {
@@ -512,3 +548,371 @@ func (m ProgramModel) GetAllDepartments() (*[]Department, error) {
 	// Return the data
 	return &departments, nil
 }
+
+// PromoteStudents moves all students of a program from a semester to the next one,
+// except the excluded (held back) students. The next semester is added as a running
+// semester if it is not already, and the semester stops running if asked to.
+// Everything happens in a single transaction.
+func (m ProgramModel) PromoteStudents(programID, semesterID int, exclude []int64, endSemester bool) (*PromotionReport, error) {
+
+	report := PromotionReport{
+		ProgramID:    programID,
+		FromSemester: semesterID,
+		ToSemester:   semesterID + 1,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	// The held back students must belong to the semester being promoted
+	heldBackQuery := `SELECT user_id, student_id, name, symbol_no FROM students
+	WHERE program_id = $1 AND semester_id = $2 AND user_id = ANY($3)
+	ORDER BY symbol_no`
+
+	report.HeldBack, err = scanPromotedStudents(tx.QueryContext(ctx, heldBackQuery, programID, semesterID, pq.Array(exclude)))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(report.HeldBack) != len(exclude) {
+		return nil, ErrInvalidExclusion
+	}
+
+	promoteQuery := `UPDATE students SET semester_id = $1, version = version + 1
+	WHERE program_id = $2 AND semester_id = $3 AND NOT (user_id = ANY($4))
+	RETURNING user_id, student_id, name, symbol_no`
+
+	report.Promoted, err = scanPromotedStudents(tx.QueryContext(ctx, promoteQuery, report.ToSemester, programID, semesterID, pq.Array(exclude)))
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Nobody to promote
+	if len(report.Promoted) == 0 {
+		return nil, ErrNoRecords
+	}
+
+	// The next semester is now running for the program, if it is not already.
+	// A failed insert would abort the transaction, so check before inserting.
+	var running bool
+
+	runningQuery := `SELECT EXISTS(SELECT 1 FROM running_semesters WHERE program_id = $1 AND semester_id = $2)`
+
+	err = tx.QueryRowContext(ctx, runningQuery, programID, report.ToSemester).Scan(&running)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !running {
+		err = addRunningSemester(ctx, tx, programID, report.ToSemester)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The semester stops running only on request, it may still have exams or marks pending
+	if endSemester {
+		endQuery := `DELETE FROM running_semesters WHERE program_id = $1 AND semester_id = $2`
+
+		_, err = tx.ExecContext(ctx, endQuery, programID, semesterID)
+
+		if err != nil {
+			return nil, err
+		}
+
+		report.Ended = true
+	}
+
+	err = tx.Commit()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
+// scanPromotedStudents reads the rows of students for promotion report
+func scanPromotedStudents(rows *sql.Rows, err error) ([]PromotedStudent, error) {
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var students []PromotedStudent
+
+	for rows.Next() {
+		var temp PromotedStudent
+
+		err := rows.Scan(&temp.UserID, &temp.StudentID, &temp.Name, &temp.SymbolNo)
+
+		if err != nil {
+			return nil, err
+		}
+
+		students = append(students, temp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return students, nil
+}
+
+// Returned when a catalogue entry refers to a faculty, department, level,
+// program, course or semester that does not exist
+var ErrInvalidReference = errors.New("referenced record does not exist")
+
+// Struct to read a faculty from a superuser
+type FacultyInput struct {
+	Name string `json:"name" binding:"required,max=200"`
+	Head string `json:"faculty_head" binding:"max=200"`
+}
+
+// Struct to read a department from a superuser
+type DepartmentInput struct {
+	Name      string `json:"name" binding:"required,max=200"`
+	Head      string `json:"department_head" binding:"max=200"`
+	FacultyID int    `json:"faculty_id" binding:"required,min=1"`
+}
+
+// Struct to read a program from a superuser
+type ProgramInput struct {
+	Name         string `json:"name" binding:"required,max=200"`
+	Head         string `json:"program_head" binding:"max=200"`
+	DepartmentID int    `json:"department_id" binding:"required,min=1"`
+	LevelID      int    `json:"level_id" binding:"required,min=1"`
+}
+
+// Struct to read the offering of a course in a semester of a program
+type ProgramCourseInput struct {
+	CourseID   int64 `json:"course_id" binding:"required,min=1"`
+	SemesterID int   `json:"semester_id" binding:"required,min=1,max=8"`
+}
+
+// catalogueError translates the constraint violations of catalogue changes
+// into errors that can be reported to the client
+func catalogueError(err error) error {
+
+	switch {
+	case strings.Contains(err.Error(), "duplicate key value violates unique constraint"):
+		return ErrDuplicateEntry
+	// the record is still referred by other records
+	case strings.Contains(err.Error(), "update or delete on table"):
+		return ErrNotPermitted
+	// the record refers to a record that does not exist
+	case strings.Contains(err.Error(), "violates foreign key constraint"):
+		return ErrInvalidReference
+	default:
+		return err
+	}
+}
+
+// execCatalogue runs a change on a single catalogue entry,
+// returns ErrRecordNotFound if no entry was changed
+func execCatalogue(ctx context.Context, db execer, query string, args ...interface{}) error {
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return catalogueError(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// InsertFaculty adds a new faculty and returns its id
+func (m ProgramModel) InsertFaculty(input *FacultyInput) (int, error) {
+
+	query := `INSERT INTO faculties (name, faculty_head) VALUES ($1, $2) RETURNING faculty_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var facultyID int
+	err := m.DB.QueryRowContext(ctx, query, strings.TrimSpace(input.Name), strings.TrimSpace(input.Head)).Scan(&facultyID)
+	if err != nil {
+		return 0, catalogueError(err)
+	}
+
+	return facultyID, nil
+}
+
+// UpdateFaculty replaces the details of a faculty
+func (m ProgramModel) UpdateFaculty(facultyID int, input *FacultyInput) error {
+
+	query := `UPDATE faculties SET name = $2, faculty_head = $3 WHERE faculty_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return execCatalogue(ctx, m.DB, query, facultyID, strings.TrimSpace(input.Name), strings.TrimSpace(input.Head))
+}
+
+// DeleteFaculty deletes a faculty having no departments
+func (m ProgramModel) DeleteFaculty(facultyID int) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return execCatalogue(ctx, m.DB, `DELETE FROM faculties WHERE faculty_id = $1`, facultyID)
+}
+
+// GetDepartment returns the detail of a department
+func (m ProgramModel) GetDepartment(departmentID int) (*Department, error) {
+
+	query := `SELECT departments.department_id, departments.name, COALESCE(departments.department_head, ''),
+	faculties.name as faculty
+	FROM departments
+	INNER JOIN faculties ON departments.faculty_id = faculties.faculty_id
+	WHERE departments.department_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var dept Department
+
+	err := m.DB.QueryRowContext(ctx, query, departmentID).Scan(&dept.DepartmentID, &dept.Name, &dept.DepartmentHead, &dept.Faculty)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &dept, nil
+}
+
+// InsertDepartment adds a new department within a faculty and returns its id
+func (m ProgramModel) InsertDepartment(input *DepartmentInput) (int, error) {
+
+	query := `INSERT INTO departments (name, department_head, faculty_id) VALUES ($1, $2, $3)
+	RETURNING department_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var departmentID int
+	err := m.DB.QueryRowContext(ctx, query, strings.TrimSpace(input.Name), strings.TrimSpace(input.Head),
+		input.FacultyID).Scan(&departmentID)
+	if err != nil {
+		return 0, catalogueError(err)
+	}
+
+	return departmentID, nil
+}
+
+// UpdateDepartment replaces the details of a department
+func (m ProgramModel) UpdateDepartment(departmentID int, input *DepartmentInput) error {
+
+	query := `UPDATE departments SET name = $2, department_head = $3, faculty_id = $4
+	WHERE department_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return execCatalogue(ctx, m.DB, query, departmentID, strings.TrimSpace(input.Name),
+		strings.TrimSpace(input.Head), input.FacultyID)
+}
+
+// DeleteDepartment deletes a department having no programs
+func (m ProgramModel) DeleteDepartment(departmentID int) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return execCatalogue(ctx, m.DB, `DELETE FROM departments WHERE department_id = $1`, departmentID)
+}
+
+// InsertProgram adds a new program and returns its id
+func (m ProgramModel) InsertProgram(input *ProgramInput) (int, error) {
+
+	query := `INSERT INTO programs (name, program_head, department_id, level_id) VALUES ($1, $2, $3, $4)
+	RETURNING program_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var programID int
+	err := m.DB.QueryRowContext(ctx, query, strings.TrimSpace(input.Name), strings.TrimSpace(input.Head),
+		input.DepartmentID, input.LevelID).Scan(&programID)
+	if err != nil {
+		return 0, catalogueError(err)
+	}
+
+	return programID, nil
+}
+
+// UpdateProgram replaces the details of a program
+func (m ProgramModel) UpdateProgram(programID int, input *ProgramInput) error {
+
+	query := `UPDATE programs SET name = $2, program_head = $3, department_id = $4, level_id = $5
+	WHERE program_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return execCatalogue(ctx, m.DB, query, programID, strings.TrimSpace(input.Name),
+		strings.TrimSpace(input.Head), input.DepartmentID, input.LevelID)
+}
+
+// DeleteProgram deletes a program having no courses, running semesters or students
+func (m ProgramModel) DeleteProgram(programID int) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return execCatalogue(ctx, m.DB, `DELETE FROM programs WHERE program_id = $1`, programID)
+}
+
+// AddProgramCourse offers a course in a semester of a program
+func (m ProgramModel) AddProgramCourse(programID int, input *ProgramCourseInput) error {
+
+	query := `INSERT INTO program_courses (program_id, course_id, semester_id) VALUES ($1, $2, $3)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, programID, input.CourseID, input.SemesterID)
+	if err != nil {
+		return catalogueError(err)
+	}
+
+	return nil
+}
+
+// RemoveProgramCourse stops offering a course in a program
+func (m ProgramModel) RemoveProgramCourse(programID int, courseID int64) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return execCatalogue(ctx, m.DB, `DELETE FROM program_courses WHERE program_id = $1 AND course_id = $2`,
+		programID, courseID)
+}
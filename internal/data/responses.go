@@ -2,8 +2,9 @@ package data
 
 // Struct to return error messages
 type ErrorResponseMessage struct {
-	ErrType string `json:"type"`    // The type of error
-	Message string `json:"message"` // The error message in details
+	ErrType string      `json:"type"`              // The type of error
+	Message string      `json:"message"`           // The error message in details
+	Details interface{} `json:"details,omitempty"` // Structured details of the error, if any
 }
 
 // Struct to return helpful messages
@@ -68,6 +69,15 @@ func CustomErrorResponse(errorType string, msg string) ErrorResponseMessage {
 	}
 }
 
+// Response for a conflict in a schedule
+func ScheduleConflictResponse(conflict ScheduleConflict) ErrorResponseMessage {
+	return ErrorResponseMessage{
+		ErrType: "Schedule Conflict",
+		Message: conflict.Message,
+		Details: conflict,
+	}
+}
+
 // Response for Invalid credentials
 func InvalidCredentialsResponse(msg string) ErrorResponseMessage {
 	return ErrorResponseMessage{
@@ -164,7 +164,9 @@ func (m ScheduleModel) GetAllIntervals() (*[]IntervalPeriod, error) {
 }
 
 // SetSchedule sets a schedule for a semester of a program
-func (m ScheduleModel) SetSchedule(obj *Schedule) error {
+// Every slot is checked for conflicts before anything is added,
+// incase of conflicts all of them are returned along with ErrScheduleConflict
+func (m ScheduleModel) SetSchedule(obj *Schedule) ([]ScheduleConflict, error) {
 
 	programID := obj.ProgramID
 	semesterID := obj.SemesterID
@@ -175,29 +177,40 @@ func (m ScheduleModel) SetSchedule(obj *Schedule) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	var prog, sem int
-	row := m.DB.QueryRowContext(ctx, query1, programID, semesterID)
+	row := tx.QueryRowContext(ctx, query1, programID, semesterID)
 	// Scan the values
 	row.Scan(&prog, &sem)
 
 	// If values stay zero, that means no such mix of prog and sem ids exists
 	if prog == 0 && sem == 0 {
-		return ErrNoRecords
+		return nil, ErrNoRecords
 	}
-	// program_id | semester_id |  day   | interval_id | course_id | description
-	query := `INSERT INTO day_schedule (program_id, semester_id, day, interval_id, course_id, description) VALUES `
 
-	args := []interface{}{}
+	slots := obj.Slots()
+
+	conflicts, err := scheduleConflicts(ctx, tx, slots, nil)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, val1 := range obj.Days {
+	if len(conflicts) != 0 {
+		return conflicts, ErrScheduleConflict
+	}
 
-		day := val1.Day
-		intervals := val1.Intervals
+	// program_id | semester_id |  day   | interval_id | course_id | description
+	query := `INSERT INTO day_schedule (program_id, semester_id, day, interval_id, course_id, description) VALUES `
 
-		for _, val2 := range intervals {
+	args := []interface{}{}
 
-			args = append(args, programID, semesterID, day, val2.IntervalID, val2.CourseID, val2.Description)
-		}
+	for _, slot := range slots {
+		args = append(args, programID, semesterID, slot.Day, slot.IntervalID, slot.CourseID, slot.Description)
 	}
 
 	// No of values to be inserted
@@ -223,22 +236,19 @@ func (m ScheduleModel) SetSchedule(obj *Schedule) error {
 	// The whole query
 	fullQuery := query + otherQuery
 
-	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	_, err := m.DB.ExecContext(ctx, fullQuery, args...)
+	_, err = tx.ExecContext(ctx, fullQuery, args...)
 
 	if err != nil {
 		// Incase of duplicate entry
 		switch err.Error() {
 		case `pq: duplicate key value violates unique constraint "day_schedule_pkey"`:
-			return ErrDuplicateEntry
+			return nil, ErrDuplicateEntry
 		default:
-			return err
+			return nil, err
 		}
 	}
 	// Success
-	return nil
+	return nil, tx.Commit()
 }
 
 func (m ScheduleModel) GetSchedule(programID, semesterID int) (*StudentSchedule, error) {
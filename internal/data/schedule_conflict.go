@@ -0,0 +1,391 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Kinds of conflicts found while scheduling a slot
+const (
+	ConflictInvalidDay      = "invalid_day"        // the day is not a day of the week
+	ConflictInvalidInterval = "invalid_interval"   // the interval does not exist
+	ConflictNotOffered      = "course_not_offered" // the course is not offered in the program and semester
+	ConflictNoTeacher       = "no_teacher"         // no teacher is currently assigned to the course
+	ConflictDuplicateSlot   = "duplicate_slot"     // the slot is already scheduled
+	ConflictClassOverlap    = "class_overlap"      // the class already has another course at the time
+	ConflictTeacherBusy     = "teacher_busy"       // the teacher already teaches another class at the time
+)
+
+// Incase the submitted slots conflict with each other or the existing schedule
+var ErrScheduleConflict = errors.New("schedule has conflicts")
+
+// A slot of the day schedule of a semester of a program
+type ScheduleSlot struct {
+	ProgramID   int
+	SemesterID  int
+	Day         string
+	IntervalID  int
+	CourseID    int
+	Description string
+}
+
+// A conflict of a submitted slot
+type ScheduleConflict struct {
+	Kind       string `json:"kind"`
+	Day        string `json:"day"`
+	IntervalID int    `json:"interval_id"`
+	CourseID   int    `json:"course_id"`
+	Message    string `json:"-"` // reported as the message of the error response
+}
+
+// Slots returns the slots of the schedule with the days in upper case
+func (s *Schedule) Slots() []ScheduleSlot {
+
+	var slots []ScheduleSlot
+
+	for _, day := range s.Days {
+		for _, interval := range day.Intervals {
+			slots = append(slots, ScheduleSlot{
+				ProgramID:   s.ProgramID,
+				SemesterID:  s.SemesterID,
+				Day:         strings.ToUpper(strings.TrimSpace(day.Day)),
+				IntervalID:  interval.IntervalID,
+				CourseID:    interval.CourseID,
+				Description: interval.Description,
+			})
+		}
+	}
+
+	return slots
+}
+
+// Start and end of an interval in minutes since midnight
+type intervalSpan struct {
+	label      string
+	start, end int
+	parsed     bool // false if the label is not in HH:MM-HH:MM format
+}
+
+// parseIntervalSpan reads an interval label such as 10:15-11:05
+func parseIntervalSpan(label string) intervalSpan {
+
+	span := intervalSpan{label: label}
+
+	parts := strings.Split(label, "-")
+	if len(parts) != 2 {
+		return span
+	}
+
+	var minutes [2]int
+	for i, part := range parts {
+		clock := strings.Split(strings.TrimSpace(part), ":")
+		if len(clock) != 2 {
+			return span
+		}
+
+		hours, err1 := strconv.Atoi(clock[0])
+		mins, err2 := strconv.Atoi(clock[1])
+		if err1 != nil || err2 != nil {
+			return span
+		}
+
+		minutes[i] = hours*60 + mins
+	}
+
+	span.start, span.end, span.parsed = minutes[0], minutes[1], true
+	return span
+}
+
+// A teacher currently assigned to a course
+type slotTeacher struct {
+	teacherID int64
+	name      string
+}
+
+// A slot along with the details of its course
+type slotCourse struct {
+	ScheduleSlot
+	code     string
+	elective bool
+}
+
+// Everything needed to look for the conflicts of slots
+type scheduleState struct {
+	intervals map[int]intervalSpan
+	offerings map[[2]int]map[int]slotCourse // courses offered by (program_id, semester_id)
+	teachers  map[int][]slotTeacher         // current teachers of courses
+	existing  []slotCourse                  // slots already in the day schedule
+}
+
+// overlaps reports whether two intervals share any time
+// Intervals with labels in an unknown format only overlap themselves
+func (s *scheduleState) overlaps(a, b int) bool {
+
+	if a == b {
+		return true
+	}
+
+	x, y := s.intervals[a], s.intervals[b]
+	if !x.parsed || !y.parsed {
+		return false
+	}
+
+	return x.start < y.end && y.start < x.end
+}
+
+// teaches reports whether a teacher is currently assigned to a course
+func (s *scheduleState) teaches(teacherID int64, courseID int) bool {
+
+	for _, t := range s.teachers[courseID] {
+		if t.teacherID == teacherID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// loadScheduleState reads the intervals, offerings, current teachers and the day schedule.
+// Existing slots in ignored are left out, so that they can be moved or replaced.
+func loadScheduleState(ctx context.Context, tx *sql.Tx, slots, ignored []ScheduleSlot) (*scheduleState, error) {
+
+	state := scheduleState{
+		intervals: map[int]intervalSpan{},
+		offerings: map[[2]int]map[int]slotCourse{},
+		teachers:  map[int][]slotTeacher{},
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT interval_id, interval FROM intervals`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var label string
+		if err := rows.Scan(&id, &label); err != nil {
+			return nil, err
+		}
+		state.intervals[id] = parseIntervalSpan(label)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT program_courses.course_id, courses.course_code, COALESCE(courses.elective, false)
+	FROM program_courses
+	INNER JOIN courses ON courses.course_id = program_courses.course_id
+	WHERE program_courses.program_id = $1 AND program_courses.semester_id = $2`
+
+	for _, slot := range slots {
+		key := [2]int{slot.ProgramID, slot.SemesterID}
+		if _, exists := state.offerings[key]; exists {
+			continue
+		}
+
+		courses := map[int]slotCourse{}
+
+		rows, err := tx.QueryContext(ctx, query, slot.ProgramID, slot.SemesterID)
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			var temp slotCourse
+			if err := rows.Scan(&temp.CourseID, &temp.code, &temp.elective); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			courses[temp.CourseID] = temp
+		}
+
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		state.offerings[key] = courses
+	}
+
+	query = `SELECT teacher_courses.course_id, teachers.teacher_id, teachers.name
+	FROM teacher_courses
+	INNER JOIN teachers ON teachers.teacher_id = teacher_courses.teacher_id
+	WHERE teacher_courses.expires_at >= CURRENT_DATE`
+
+	teacherRows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer teacherRows.Close()
+
+	for teacherRows.Next() {
+		var courseID int
+		var temp slotTeacher
+		if err := teacherRows.Scan(&courseID, &temp.teacherID, &temp.name); err != nil {
+			return nil, err
+		}
+		state.teachers[courseID] = append(state.teachers[courseID], temp)
+	}
+
+	if err = teacherRows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `SELECT day_schedule.program_id, day_schedule.semester_id, day_schedule.day,
+	day_schedule.interval_id, day_schedule.course_id, courses.course_code, COALESCE(courses.elective, false)
+	FROM day_schedule
+	INNER JOIN courses ON courses.course_id = day_schedule.course_id`
+
+	slotRows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer slotRows.Close()
+
+	for slotRows.Next() {
+		var temp slotCourse
+		err := slotRows.Scan(&temp.ProgramID, &temp.SemesterID, &temp.Day, &temp.IntervalID,
+			&temp.CourseID, &temp.code, &temp.elective)
+		if err != nil {
+			return nil, err
+		}
+
+		if !containsSlot(ignored, temp.ScheduleSlot) {
+			state.existing = append(state.existing, temp)
+		}
+	}
+
+	if err = slotRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// containsSlot reports whether a slot is in the list, descriptions are not compared
+func containsSlot(slots []ScheduleSlot, slot ScheduleSlot) bool {
+
+	for _, val := range slots {
+		if val.ProgramID == slot.ProgramID && val.SemesterID == slot.SemesterID && val.Day == slot.Day &&
+			val.IntervalID == slot.IntervalID && val.CourseID == slot.CourseID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isWeekDay reports whether the day is one of weekDays
+func isWeekDay(day string) bool {
+
+	for _, val := range weekDays {
+		if val == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scheduleConflicts checks every slot against the days, intervals, program courses,
+// current teachers, the existing day schedule (except the ignored slots) and the slots before it.
+// It returns all the conflicts found, which is empty if the slots can be scheduled.
+func scheduleConflicts(ctx context.Context, tx *sql.Tx, slots, ignored []ScheduleSlot) ([]ScheduleConflict, error) {
+
+	state, err := loadScheduleState(ctx, tx, slots, ignored)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts := []ScheduleConflict{}
+
+	// Valid slots checked so far, later slots are checked against them
+	var checked []slotCourse
+
+	for _, slot := range slots {
+
+		conflict := func(kind, format string, args ...interface{}) {
+			conflicts = append(conflicts, ScheduleConflict{
+				Kind:       kind,
+				Day:        slot.Day,
+				IntervalID: slot.IntervalID,
+				CourseID:   slot.CourseID,
+				Message:    fmt.Sprintf(format, args...),
+			})
+		}
+
+		if !isWeekDay(slot.Day) {
+			conflict(ConflictInvalidDay, "%q is not a day of the week.", slot.Day)
+			continue
+		}
+
+		span, exists := state.intervals[slot.IntervalID]
+		if !exists {
+			conflict(ConflictInvalidInterval, "The interval_id %d does not exist.", slot.IntervalID)
+			continue
+		}
+
+		current, offered := state.offerings[[2]int{slot.ProgramID, slot.SemesterID}][slot.CourseID]
+		if !offered {
+			conflict(ConflictNotOffered, "The course_id %d is not offered in semester %d of the program.",
+				slot.CourseID, slot.SemesterID)
+			continue
+		}
+		current.ScheduleSlot = slot
+
+		teachers := state.teachers[slot.CourseID]
+		if len(teachers) == 0 {
+			conflict(ConflictNoTeacher, "%s has no teacher currently assigned to it.", current.code)
+		}
+
+		where := fmt.Sprintf("on %s at %s", slot.Day, span.label)
+
+		// compare with the existing slots first and then with the submitted ones
+		others := append(append([]slotCourse{}, state.existing...), checked...)
+
+		for i, other := range others {
+
+			if other.Day != slot.Day || !state.overlaps(other.IntervalID, slot.IntervalID) {
+				continue
+			}
+
+			source := "already scheduled"
+			if i >= len(state.existing) {
+				source = "also submitted"
+			}
+
+			sameClass := other.ProgramID == slot.ProgramID && other.SemesterID == slot.SemesterID
+
+			if sameClass && other.CourseID == slot.CourseID && other.IntervalID == slot.IntervalID {
+				conflict(ConflictDuplicateSlot, "%s is %s %s.", current.code, source, where)
+				continue
+			}
+
+			// Elective courses of a class may run in parallel
+			if sameClass && (other.CourseID == slot.CourseID || !(current.elective && other.elective)) {
+				conflict(ConflictClassOverlap, "%s overlaps %s (%s) which is %s for the class %s.", current.code,
+					other.code, state.intervals[other.IntervalID].label, source, where)
+				continue
+			}
+
+			for _, teacher := range teachers {
+				if state.teaches(teacher.teacherID, other.CourseID) {
+					conflict(ConflictTeacherBusy, "%s, the teacher of %s, also teaches %s (%s) to semester %d of program_id %d %s.",
+						teacher.name, current.code, other.code, state.intervals[other.IntervalID].label,
+						other.SemesterID, other.ProgramID, where)
+				}
+			}
+		}
+
+		checked = append(checked, current)
+	}
+
+	return conflicts, nil
+}
@@ -0,0 +1,274 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Struct to read a slot to be added to the schedule of a running semester
+type ScheduleSlotInput struct {
+	ProgramID   int    `json:"program_id" binding:"required,min=1"`
+	SemesterID  int    `json:"semester_id" binding:"required,min=1"`
+	Day         string `json:"day" binding:"required"`
+	IntervalID  int    `json:"interval_id" binding:"required,min=1"`
+	CourseID    int    `json:"course_id" binding:"required,min=1"`
+	Description string `json:"description" binding:"max=200"`
+}
+
+// Struct to read the changes to a slot, omitted values are kept as they are
+type ScheduleSlotUpdate struct {
+	Day         string  `json:"day"`                                     // moves the slot to another day
+	IntervalID  int     `json:"interval_id" binding:"min=0"`             // moves the slot to another interval
+	Description *string `json:"description" binding:"omitempty,max=200"` // replaces the description
+}
+
+// A slot of the day schedule along with its interval and course
+type ScheduledSlot struct {
+	ProgramID   int    `json:"program_id"`
+	SemesterID  int    `json:"semester_id"`
+	Day         string `json:"day"`
+	IntervalID  int    `json:"interval_id"`
+	Interval    string `json:"interval"`
+	CourseID    int    `json:"course_id"`
+	CourseCode  string `json:"course_code"`
+	CourseTitle string `json:"course_title"`
+	Description string `json:"description"`
+}
+
+// A change to a slot, Before is nil for an added slot and After is nil for a removed slot
+type SlotChange struct {
+	Before *ScheduledSlot `json:"before"`
+	After  *ScheduledSlot `json:"after"`
+}
+
+// Slot returns the slot of the input with the day in upper case
+func (input *ScheduleSlotInput) Slot() ScheduleSlot {
+	return ScheduleSlot{
+		ProgramID:   input.ProgramID,
+		SemesterID:  input.SemesterID,
+		Day:         strings.ToUpper(strings.TrimSpace(input.Day)),
+		IntervalID:  input.IntervalID,
+		CourseID:    input.CourseID,
+		Description: strings.TrimSpace(input.Description),
+	}
+}
+
+// isRunning reports whether the semester of the program is running
+func isRunning(ctx context.Context, tx *sql.Tx, programID, semesterID int) (bool, error) {
+
+	query := `SELECT EXISTS (SELECT 1 FROM running_semesters
+	WHERE program_id = $1 AND semester_id = $2)`
+
+	var exists bool
+	err := tx.QueryRowContext(ctx, query, programID, semesterID).Scan(&exists)
+
+	return exists, err
+}
+
+// scheduledSlot returns a slot of the day schedule along with its interval and course
+func scheduledSlot(ctx context.Context, tx *sql.Tx, slot ScheduleSlot) (*ScheduledSlot, error) {
+
+	query := `SELECT day_schedule.program_id, day_schedule.semester_id, day_schedule.day,
+	day_schedule.interval_id, intervals.interval, day_schedule.course_id, courses.course_code, courses.title,
+	COALESCE(day_schedule.description, '')
+	FROM day_schedule
+	INNER JOIN intervals ON intervals.interval_id = day_schedule.interval_id
+	INNER JOIN courses ON courses.course_id = day_schedule.course_id
+	WHERE day_schedule.program_id = $1 AND day_schedule.semester_id = $2 AND day_schedule.day = $3
+	AND day_schedule.interval_id = $4 AND day_schedule.course_id = $5`
+
+	var s ScheduledSlot
+
+	err := tx.QueryRowContext(ctx, query, slot.ProgramID, slot.SemesterID, slot.Day, slot.IntervalID, slot.CourseID).Scan(
+		&s.ProgramID, &s.SemesterID, &s.Day, &s.IntervalID, &s.Interval, &s.CourseID, &s.CourseCode,
+		&s.CourseTitle, &s.Description)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &s, nil
+}
+
+// AddSlot adds a slot to the schedule of a running semester, keeping the rest of the schedule
+// Incase of conflicts, they are returned along with ErrScheduleConflict
+func (m ScheduleModel) AddSlot(slot ScheduleSlot) (*SlotChange, []ScheduleConflict, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	running, err := isRunning(ctx, tx, slot.ProgramID, slot.SemesterID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !running {
+		return nil, nil, ErrNoRecords
+	}
+
+	conflicts, err := scheduleConflicts(ctx, tx, []ScheduleSlot{slot}, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(conflicts) != 0 {
+		return nil, conflicts, ErrScheduleConflict
+	}
+
+	query := `INSERT INTO day_schedule (program_id, semester_id, day, interval_id, course_id, description)
+	VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err = tx.ExecContext(ctx, query, slot.ProgramID, slot.SemesterID, slot.Day, slot.IntervalID, slot.CourseID, slot.Description)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	after, err := scheduledSlot(ctx, tx, slot)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &SlotChange{After: after}, nil, tx.Commit()
+}
+
+// UpdateSlot moves a slot to another day or interval and/or replaces its description
+// A moved slot is checked for conflicts, which are returned along with ErrScheduleConflict
+func (m ScheduleModel) UpdateSlot(slot ScheduleSlot, update *ScheduleSlotUpdate) (*SlotChange, []ScheduleConflict, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := scheduledSlot(ctx, tx, slot)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	moved := slot
+	moved.Description = before.Description
+
+	if day := strings.ToUpper(strings.TrimSpace(update.Day)); day != "" {
+		moved.Day = day
+	}
+
+	if update.IntervalID != 0 {
+		moved.IntervalID = update.IntervalID
+	}
+
+	if update.Description != nil {
+		moved.Description = strings.TrimSpace(*update.Description)
+	}
+
+	// The slot itself is ignored, so that it does not conflict with its old place
+	if moved.Day != slot.Day || moved.IntervalID != slot.IntervalID {
+		conflicts, err := scheduleConflicts(ctx, tx, []ScheduleSlot{moved}, []ScheduleSlot{slot})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(conflicts) != 0 {
+			return nil, conflicts, ErrScheduleConflict
+		}
+	}
+
+	query := `UPDATE day_schedule SET day = $6, interval_id = $7, description = $8
+	WHERE program_id = $1 AND semester_id = $2 AND day = $3 AND interval_id = $4 AND course_id = $5`
+
+	_, err = tx.ExecContext(ctx, query, slot.ProgramID, slot.SemesterID, slot.Day, slot.IntervalID, slot.CourseID,
+		moved.Day, moved.IntervalID, moved.Description)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	after, err := scheduledSlot(ctx, tx, moved)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &SlotChange{Before: before, After: after}, nil, tx.Commit()
+}
+
+// RemoveSlot removes a slot from the schedule, keeping the rest of the schedule
+func (m ScheduleModel) RemoveSlot(slot ScheduleSlot) (*SlotChange, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := scheduledSlot(ctx, tx, slot)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `DELETE FROM day_schedule
+	WHERE program_id = $1 AND semester_id = $2 AND day = $3 AND interval_id = $4 AND course_id = $5`
+
+	_, err = tx.ExecContext(ctx, query, slot.ProgramID, slot.SemesterID, slot.Day, slot.IntervalID, slot.CourseID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SlotChange{Before: before}, tx.Commit()
+}
+
+// GetSlotAudience returns the emails of the students of a semester of a program
+// and of the teachers currently assigned to a course
+func (m ScheduleModel) GetSlotAudience(programID, semesterID, courseID int) ([]string, error) {
+
+	query := `SELECT users.email FROM students
+	INNER JOIN users ON users.user_id = students.user_id
+	WHERE students.program_id = $1 AND students.semester_id = $2
+	UNION
+	SELECT users.email FROM teacher_courses
+	INNER JOIN teachers ON teachers.teacher_id = teacher_courses.teacher_id
+	INNER JOIN users ON users.user_id = teachers.user_id
+	WHERE teacher_courses.course_id = $3 AND teacher_courses.expires_at >= CURRENT_DATE`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, programID, semesterID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	emails := []string{}
+
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, err
+		}
+		emails = append(emails, email)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return emails, nil
+}
@@ -17,6 +17,7 @@ import (
 const (
 	ScopeActivation     = "activation"
 	ScopeAuthentication = "authentication"
+	ScopePasswordReset  = "password-reset"
 )
 
 // A struct to hold information about token
@@ -114,6 +115,58 @@ func (m TokenModel) NewAuthenticationToken(userID int64, ttl time.Duration, scop
 	return token, err
 }
 
+// NewPasswordResetToken creates a new password reset token for a user and inserts into tokens table.
+// Only the hash of the token is stored, the plaintext is to be mailed to the user.
+func (m TokenModel) NewPasswordResetToken(userID int64, ttl time.Duration) (*Token, error) {
+
+	token, err := generateToken(userID, ttl, ScopePasswordReset)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Insert(token)
+
+	return token, err
+}
+
+// GetForToken returns the unexpired token of a scope that matches the provided plaintext
+func (m TokenModel) GetForToken(scope, plaintext string) (*Token, error) {
+
+	// The stored hash is the upper cased hex encoding of md5 sum of plaintext
+	hash := md5.Sum([]byte(plaintext))
+	hashVal := strings.ToUpper(hex.EncodeToString(hash[:]))
+
+	var obj Token
+
+	query := `
+	SELECT hash, user_id, expires_at, scope
+	FROM tokens
+	WHERE hash = $1 AND scope = $2 AND expires_at > NOW()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, hashVal, scope).Scan(
+		&obj.Hash,
+		&obj.UserID,
+		&obj.Expiry,
+		&obj.Scope)
+
+	// Incase of errors
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	obj.Plaintext = plaintext
+
+	return &obj, nil
+}
+
 // Creates a new activation token for an email account and inserts into tokens table
 func (m TokenModel) GenAndInsertActivationToken(email string, ttl time.Duration) (*Token, error) {
 
@@ -167,6 +220,21 @@ func (m TokenModel) DeleteAllForUser(scope string, userID int64) error {
 	return nil
 }
 
+// DeleteAllTokensForUser deletes tokens of every scope for a user,
+// i.e. all the sessions and pending links are revoked
+func (m TokenModel) DeleteAllTokensForUser(userID int64) error {
+	query := `
+	DELETE FROM tokens
+	WHERE user_id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 // Checks if a token exists in db.
 // Returns the token detail if user has that token
 func (m TokenModel) LoggedIn(token string) (*Token, error) {
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/lib/pq"
@@ -74,6 +75,9 @@ type UserDetails struct {
 	Name    string `json:"name"`
 	Role    string `json:"role"`
 	Profile string `json:"profile"` // Profile , like "/v1/students/:user_id","/v1/teacher/:user_id"
+
+	UnreadMessages      int `json:"unread_messages"`      // Messages not yet read by the user
+	UnreadConversations int `json:"unread_conversations"` // Conversations having unread messages
 }
 
 // Struct to hold details of student
@@ -85,11 +89,41 @@ type Student struct {
 	SymbolNo   int64     `json:"symbol_no"`
 	PURegdNo   string    `json:"pu_regd_no"`
 	EnrolledAt time.Time `json:"enrolled_at"`
+	ContactNo  string    `json:"contact_no"`
 	Faculty    string    `json:"faculty"`
 	Department string    `json:"department"`
+	ProgramID  int       `json:"program_id"`
 	Program    string    `json:"program"`
 	Level      string    `json:"level"`
 	Semester   int       `json:"semester"`
+	Version    int       `json:"version"` // used for optimistic concurrency control while updating
+}
+
+// Struct to hold partial update of student details.
+// Nil fields are left unchanged.
+type StudentUpdate struct {
+	Name      *string `json:"name"`
+	ContactNo *string `json:"contact_no"`
+
+	// Only superusers can update these fields
+	SymbolNo   *int64  `json:"symbol_no"`
+	PURegdNo   *string `json:"pu_regd_no"`
+	ProgramID  *int    `json:"program_id"`
+	SemesterID *int    `json:"semester_id"`
+
+	// The version of record the update is based upon
+	Version *int `json:"version" binding:"required"`
+}
+
+// Struct to hold partial update of teacher details.
+// Nil fields are left unchanged.
+type TeacherUpdate struct {
+	Name      *string  `json:"name"`
+	ContactNo *string  `json:"contact_no"`
+	Academics []string `json:"academics"`
+
+	// The version of record the update is based upon
+	Version *int `json:"version" binding:"required"`
 }
 
 // A substruct for teacher group
@@ -113,6 +147,7 @@ type Teacher struct {
 	ContactNo string      `json:"contact_no"`
 	Academics []string    `json:"academics"`
 	TeachesAt []TeachesAt `json:"teaches_at"`
+	Version   int         `json:"version"` // used for optimistic concurrency control while updating
 }
 
 //  A struct to hold info about superuser
@@ -121,9 +156,17 @@ type SuperUser struct {
 	Email       string `json:"email"`
 	SuperUserID int64  `json:"superuser_id"`
 	Name        string `json:"name"`
+	Main        bool   `json:"main"`
 	AddedBy     string `json:"added_by"`
 }
 
+// Struct to hold superuser registration details
+type SuperUserRegistration struct {
+	Email    string `json:"email" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+	Password string `json:"password" binding:"required,min=8,max=72"`
+}
+
 // Wrapper around *sql.DB connection
 type UserModel struct {
 	DB *sql.DB
@@ -306,8 +349,9 @@ func (m UserModel) GetStudentDetails(userID int64) (*Student, error) {
 
 	// Construct query
 	query := `SELECT users.user_id, users.email, students.student_id, students.name,
-	students.symbol_no, students.pu_regd_no, students.enrolled_at,
-	faculties.name, departments.name, programs.name, levels.name, students.semester_id
+	students.symbol_no, students.pu_regd_no, students.enrolled_at, COALESCE(students.contact_no, ''),
+	faculties.name, departments.name, programs.program_id, programs.name, levels.name,
+	students.semester_id, students.version
 	FROM users 
 	INNER JOIN students ON students.user_id = users.user_id
 	INNER JOIN programs ON programs.program_id = students.program_id
@@ -328,11 +372,14 @@ func (m UserModel) GetStudentDetails(userID int64) (*Student, error) {
 		&student.SymbolNo,
 		&student.PURegdNo,
 		&student.EnrolledAt,
+		&student.ContactNo,
 		&student.Faculty,
 		&student.Department,
+		&student.ProgramID,
 		&student.Program,
 		&student.Level,
-		&student.Semester)
+		&student.Semester,
+		&student.Version)
 
 	// If errors
 	if err != nil {
@@ -349,6 +396,70 @@ func (m UserModel) GetStudentDetails(userID int64) (*Student, error) {
 	return &student, nil
 }
 
+// GetStudentsOf returns the details of all students of a semester of a program
+func (m UserModel) GetStudentsOf(programID, semesterID int) (*[]Student, error) {
+
+	query := `SELECT users.user_id, users.email, students.student_id, students.name,
+	students.symbol_no, students.pu_regd_no, students.enrolled_at, COALESCE(students.contact_no, ''),
+	faculties.name, departments.name, programs.program_id, programs.name, levels.name,
+	students.semester_id, students.version
+	FROM users
+	INNER JOIN students ON students.user_id = users.user_id
+	INNER JOIN programs ON programs.program_id = students.program_id
+	INNER JOIN departments ON departments.department_id = programs.department_id
+	INNER JOIN faculties ON faculties.faculty_id = departments.faculty_id
+	INNER JOIN levels ON levels.level_id = programs.level_id
+	WHERE students.program_id = $1 AND students.semester_id = $2
+	ORDER BY students.symbol_no`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, programID, semesterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	students := []Student{}
+
+	for rows.Next() {
+		var student Student
+
+		err := rows.Scan(
+			&student.UserID,
+			&student.Email,
+			&student.StudentID,
+			&student.Name,
+			&student.SymbolNo,
+			&student.PURegdNo,
+			&student.EnrolledAt,
+			&student.ContactNo,
+			&student.Faculty,
+			&student.Department,
+			&student.ProgramID,
+			&student.Program,
+			&student.Level,
+			&student.Semester,
+			&student.Version)
+		if err != nil {
+			return nil, err
+		}
+
+		students = append(students, student)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(students) == 0 {
+		return &students, ErrNoRecords
+	}
+
+	return &students, nil
+}
+
 // Get Teacher Details
 func (m UserModel) GetTeacherDetails(userID int64) (*Teacher, error) {
 	/*
@@ -380,7 +491,7 @@ func (m UserModel) GetTeacherDetails(userID int64) (*Teacher, error) {
 
 	// Construct query
 	basicDetailsQuery := `SELECT users.user_id,users.email, teachers.teacher_id, teachers.name,
-	 teachers.joined_at, teachers.contact_no, teachers.academics
+	 teachers.joined_at, COALESCE(teachers.contact_no, ''), teachers.academics, teachers.version
 	FROM users
 	INNER JOIN teachers ON users.user_id = teachers.user_id
 	WHERE users.user_id = $1 `
@@ -410,7 +521,8 @@ func (m UserModel) GetTeacherDetails(userID int64) (*Teacher, error) {
 		&teacher.Name,
 		&teacher.JoinedAt,
 		&teacher.ContactNo,
-		pq.Array(&teacher.Academics))
+		pq.Array(&teacher.Academics),
+		&teacher.Version)
 
 	// If errors
 	if err != nil {
@@ -493,9 +605,10 @@ func (m UserModel) GetSuperUserDetails(userID int64) (*SuperUser, error) {
 
 	// Construct query
 	query := `SELECT users.user_id,users.email, superusers.superuser_id, superusers.name,
-	(SELECT superusers.name FROM superusers WHERE superusers.superuser_id = superusers.added_by)
+	COALESCE(superusers.main, false), COALESCE(adder.name, '')
 	FROM users
 	INNER JOIN superusers ON users.user_id = superusers.user_id
+	LEFT JOIN superusers AS adder ON adder.superuser_id = superusers.added_by
 	WHERE users.user_id = $1`
 
 	// 5 sec timeout
@@ -508,6 +621,7 @@ func (m UserModel) GetSuperUserDetails(userID int64) (*SuperUser, error) {
 		&su.Email,
 		&su.SuperUserID,
 		&su.Name,
+		&su.Main,
 		&su.AddedBy,
 	)
 
@@ -529,55 +643,76 @@ func (m UserModel) GetSuperUserDetails(userID int64) (*SuperUser, error) {
 // RegisterStudent registers a student user
 func (m UserModel) RegisterStudent(studentReg *StudentRegistration) error {
 
-	query1 := `INSERT INTO users(email, password) VALUES($1, $2) RETURNING user_id`
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	tx, err := m.DB.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	_, err = registerStudent(ctx, tx, studentReg)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// registerStudent inserts the user, students and user_roles rows of a student within the transaction
+// and returns the user_id of the student
+func registerStudent(ctx context.Context, tx *sql.Tx, studentReg *StudentRegistration) (int64, error) {
+
+	query1 := `INSERT INTO users(email, password) VALUES($1, $2) RETURNING user_id`
+
 	// Role of a student
 	var role string = "student"
 
 	// hold user id
 	var userID int64
 
-	err := m.DB.QueryRowContext(ctx, query1, studentReg.Email, studentReg.Password).Scan(&userID)
+	err := tx.QueryRowContext(ctx, query1, studentReg.Email, studentReg.Password).Scan(&userID)
 
 	// Incase of errors
 	if err != nil {
 		switch {
 		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
-			return ErrDuplicateEmail
+			return 0, ErrDuplicateEmail
 		default:
-			return err
+			return 0, err
 		}
 	}
 
 	// Now, with this user_id we can insert data into students table
 	query2 := `INSERT INTO students(name,symbol_no,pu_regd_no,enrolled_at,contact_no,program_id,semester_id,user_id) 
 	VALUES( $1, $2, $3, $4, $5, $6, $7, $8)`
-	ctx1, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
 
 	// Args value
 	args := []interface{}{studentReg.Name, studentReg.SymbolNo, studentReg.PURegdNo, studentReg.EnrolledAt, studentReg.ContactNo,
 		studentReg.ProgramID, studentReg.Semester, userID}
 
-	_, err = m.DB.ExecContext(ctx1, query2, args...)
+	_, err = tx.ExecContext(ctx, query2, args...)
 
 	if err != nil {
-
-		return err
+		return 0, err
 	}
 
 	// Add user_id to user_roles table
+	query3 := `INSERT INTO user_roles(user_id,role_id) VALUES ($1, ( SELECT role_id FROM roles WHERE LOWER(roles.name) = LOWER($2) ) )`
 
-	err = RoleModel(m).AddRoleToUser(role, userID)
+	_, err = tx.ExecContext(ctx, query3, userID, role)
 
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Success
-	return nil
+	return userID, nil
 }
 
 // RegisterTeacher registers a teacher user
@@ -717,3 +852,637 @@ func (m UserModel) ActivateUser(token string) error {
 	// Return success
 	return nil
 }
+
+// Struct to hold filters for listing users
+type UserFilters struct {
+	Role       string // student, teacher or superuser
+	Activated  *bool  // nil means both activated and unactivated
+	Expired    *bool  // nil means both expired and unexpired
+	ProgramID  int    // only for students, 0 means any program
+	SemesterID int    // only for students, 0 means any semester
+	Search     string // matches with email or name
+	Filters
+}
+
+// Struct to hold a user account in admin listing
+type UserSummary struct {
+	UserID     int64  `json:"user_id"`
+	Email      string `json:"email"`
+	Name       string `json:"name"`
+	Role       string `json:"role"`
+	Activated  bool   `json:"activated"`
+	Expired    bool   `json:"expired"`
+	ProgramID  *int   `json:"program_id,omitempty"`
+	SemesterID *int   `json:"semester_id,omitempty"`
+	Profile    string `json:"profile"`
+}
+
+// ListUsers returns a page of user accounts matching the filters
+func (m UserModel) ListUsers(filters UserFilters) ([]UserSummary, Metadata, error) {
+
+	query := `SELECT COUNT(*) OVER(), users.user_id, users.email,
+	COALESCE(students.name, teachers.name, superusers.name, ''), COALESCE(roles.name, ''),
+	users.activated, users.expired, students.program_id, students.semester_id
+	FROM users
+	LEFT JOIN user_roles ON user_roles.user_id = users.user_id
+	LEFT JOIN roles ON roles.role_id = user_roles.role_id
+	LEFT JOIN students ON students.user_id = users.user_id
+	LEFT JOIN teachers ON teachers.user_id = users.user_id
+	LEFT JOIN superusers ON superusers.user_id = users.user_id
+	WHERE (LOWER(roles.name) = LOWER($1) OR $1 = '')
+	AND ($2::boolean IS NULL OR users.activated = $2)
+	AND ($3::boolean IS NULL OR users.expired = $3)
+	AND (students.program_id = $4 OR $4 = 0)
+	AND (students.semester_id = $5 OR $5 = 0)
+	AND ($6 = '' OR users.email ILIKE '%' || $6 || '%'
+		OR COALESCE(students.name, teachers.name, superusers.name, '') ILIKE '%' || $6 || '%')
+	ORDER BY users.user_id
+	LIMIT $7 OFFSET $8`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	args := []interface{}{filters.Role, filters.Activated, filters.Expired, filters.ProgramID,
+		filters.SemesterID, filters.Search, filters.limit(), filters.offset()}
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	var users []UserSummary
+
+	for rows.Next() {
+		var temp UserSummary
+		var programID, semesterID sql.NullInt64
+
+		err := rows.Scan(&totalRecords,
+			&temp.UserID,
+			&temp.Email,
+			&temp.Name,
+			&temp.Role,
+			&temp.Activated,
+			&temp.Expired,
+			&programID,
+			&semesterID)
+
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		// program and semester are only present for students
+		if programID.Valid {
+			val := int(programID.Int64)
+			temp.ProgramID = &val
+		}
+		if semesterID.Valid {
+			val := int(semesterID.Int64)
+			temp.SemesterID = &val
+		}
+
+		if link, exists := profileLinks[temp.Role]; exists {
+			temp.Profile = fmt.Sprintf("%s%d", link, temp.UserID)
+		}
+
+		users = append(users, temp)
+	}
+
+	// If any error happened during rows scanning
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return users, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// SetActivated sets the activation state of a user account
+func (m UserModel) SetActivated(userID int64, activated bool) error {
+
+	query := `UPDATE users SET activated = $1, version = version + 1 WHERE user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, activated, userID)
+
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	// If affected rows = 0 then no such user exists
+	if affected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// SetExpired sets the expiry state of a user account.
+// An expired user cannot login.
+func (m UserModel) SetExpired(userID int64, expired bool) error {
+
+	query := `UPDATE users SET expired = $1, version = version + 1 WHERE user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, expired, userID)
+
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	// If affected rows = 0 then no such user exists
+	if affected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Returned when a user still has marks, exam forms, open loans or unpaid fines
+var ErrUserHasRecords = errors.New("user has academic or library records")
+
+// DeleteUser deletes a student or teacher account along with their records.
+// Superuser accounts cannot be deleted this way, neither can users with
+// academic or library records, which must be expired instead.
+// It returns the paths of the attachments of the deleted issues, so that the files can be removed.
+func (m UserModel) DeleteUser(userID int64) ([]string, error) {
+
+	role, err := RoleModel(m).GetUserRole(userID)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNoRecords):
+			// a user without role, can still be deleted
+		default:
+			return nil, err
+		}
+	}
+
+	if role != nil && role.Role.Name == "superuser" {
+		return nil, ErrNotPermitted
+	}
+
+	// records that refer to the user, deleted in order
+	queries := []string{
+		`DELETE FROM issues WHERE user_id = $1`,
+		`DELETE FROM teacher_profiles WHERE teacher_id IN (SELECT teacher_id FROM teachers WHERE user_id = $1)`,
+		`DELETE FROM teacher_courses WHERE teacher_id IN (SELECT teacher_id FROM teachers WHERE user_id = $1)`,
+		`DELETE FROM teachers WHERE user_id = $1`,
+		`DELETE FROM students WHERE user_id = $1`,
+		`DELETE FROM user_roles WHERE user_id = $1`,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	// marks and exam forms would be removed by ON DELETE CASCADE,
+	// so the history is checked before deleting anything
+	query := `SELECT
+	EXISTS (SELECT 1 FROM marks INNER JOIN students ON students.student_id = marks.student_id
+		WHERE students.user_id = $1) OR
+	EXISTS (SELECT 1 FROM exam_forms INNER JOIN students ON students.student_id = exam_forms.student_id
+		WHERE students.user_id = $1) OR
+	EXISTS (SELECT 1 FROM book_loans WHERE user_id = $1
+		AND (returned_at IS NULL OR (fine > 0 AND NOT fine_paid)))`
+
+	var hasRecords bool
+
+	err = tx.QueryRowContext(ctx, query, userID).Scan(&hasRecords)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if hasRecords {
+		return nil, ErrUserHasRecords
+	}
+
+	// attachment rows are removed along with the issues by ON DELETE CASCADE, the files are not
+	rows, err := tx.QueryContext(ctx, `SELECT issue_attachments.path FROM issue_attachments
+	INNER JOIN issues ON issues.issue_id = issue_attachments.issue_id
+	WHERE issues.user_id = $1`, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var paths []string
+
+	for rows.Next() {
+		var path string
+
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+
+		paths = append(paths, path)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, query := range queries {
+		_, err = tx.ExecContext(ctx, query, userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// tokens are removed by ON DELETE CASCADE
+	result, err := tx.ExecContext(ctx, `DELETE FROM users WHERE user_id = $1`, userID)
+
+	if err != nil {
+		switch {
+		// the loan history restricts the deletion
+		case strings.Contains(err.Error(), "update or delete on table"):
+			return nil, ErrUserHasRecords
+		default:
+			return nil, err
+		}
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// If affected rows = 0 then no such user exists
+	if affected == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	return paths, tx.Commit()
+}
+
+// RegisterSuperUser creates a superuser account added by another superuser.
+// The user, user_roles and superusers rows are inserted in a single transaction.
+func (m UserModel) RegisterSuperUser(suReg *SuperUserRegistration, addedByUserID int64) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	// The account is created by a superuser, so it is activated already
+	query1 := `INSERT INTO users(email, password, activated) VALUES($1, $2, 't') RETURNING user_id`
+
+	// hold user id
+	var userID int64
+
+	err = tx.QueryRowContext(ctx, query1, suReg.Email, suReg.Password).Scan(&userID)
+
+	// Incase of errors
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+			return ErrDuplicateEmail
+		default:
+			return err
+		}
+	}
+
+	query2 := `INSERT INTO user_roles(user_id,role_id) VALUES ($1, ( SELECT role_id FROM roles WHERE LOWER(roles.name) = 'superuser' ) )`
+
+	_, err = tx.ExecContext(ctx, query2, userID)
+
+	if err != nil {
+		return err
+	}
+
+	query3 := `INSERT INTO superusers(name, main, added_by, user_id)
+	VALUES ($1, 'f', (SELECT superuser_id FROM superusers WHERE user_id = $2), $3)`
+
+	_, err = tx.ExecContext(ctx, query3, suReg.Name, addedByUserID, userID)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetAllSuperUsers returns the list of superusers along with who added them
+func (m UserModel) GetAllSuperUsers() ([]SuperUser, error) {
+
+	query := `SELECT users.user_id,users.email, superusers.superuser_id, superusers.name,
+	COALESCE(superusers.main, false), COALESCE(adder.name, '')
+	FROM users
+	INNER JOIN superusers ON users.user_id = superusers.user_id
+	LEFT JOIN superusers AS adder ON adder.superuser_id = superusers.added_by
+	ORDER BY superusers.superuser_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var superusers []SuperUser
+
+	for rows.Next() {
+		var temp SuperUser
+
+		err := rows.Scan(&temp.UserID,
+			&temp.Email,
+			&temp.SuperUserID,
+			&temp.Name,
+			&temp.Main,
+			&temp.AddedBy)
+
+		if err != nil {
+			return nil, err
+		}
+
+		superusers = append(superusers, temp)
+	}
+
+	// If any error happened during rows scanning
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(superusers) == 0 {
+		return nil, ErrNoRecords
+	}
+
+	return superusers, nil
+}
+
+// DeleteSuperUser deletes a superuser account.
+// The main superuser cannot be deleted. Superusers added by the deleted
+// one are handed over to the superuser who added it.
+func (m UserModel) DeleteSuperUser(userID int64) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	var superUserID int64
+	var main bool
+	var addedBy sql.NullInt64
+
+	// Lock the row so that it is not modified while being deleted
+	query1 := `SELECT superuser_id, COALESCE(main, false), added_by FROM superusers WHERE user_id = $1 FOR UPDATE`
+
+	err = tx.QueryRowContext(ctx, query1, userID).Scan(&superUserID, &main, &addedBy)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	// main superuser cannot be deleted
+	if main {
+		return ErrNotPermitted
+	}
+
+	queries := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`UPDATE superusers SET added_by = $1 WHERE added_by = $2`, []interface{}{addedBy, superUserID}},
+		{`DELETE FROM superusers WHERE superuser_id = $1`, []interface{}{superUserID}},
+		{`DELETE FROM user_roles WHERE user_id = $1`, []interface{}{userID}},
+		// tokens are removed by ON DELETE CASCADE
+		{`DELETE FROM users WHERE user_id = $1`, []interface{}{userID}},
+	}
+
+	for _, q := range queries {
+		_, err = tx.ExecContext(ctx, q.query, q.args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ValidateStudentUpdate checks the values of a student update
+func ValidateStudentUpdate(v *validator.Validator, input *StudentUpdate) {
+	if input.Name != nil {
+		v.Check(strings.TrimSpace(*input.Name) != "", "name", "must not be empty")
+		v.Check(len(*input.Name) <= 500, "name", "must not be more than 500 bytes long")
+	}
+	if input.ContactNo != nil {
+		v.Check(len(*input.ContactNo) <= 20, "contact_no", "must not be more than 20 bytes long")
+	}
+	if input.SymbolNo != nil {
+		v.Check(*input.SymbolNo > 0, "symbol_no", "must be a positive number")
+	}
+	if input.PURegdNo != nil {
+		v.Check(strings.TrimSpace(*input.PURegdNo) != "", "pu_regd_no", "must not be empty")
+	}
+	if input.ProgramID != nil {
+		v.Check(*input.ProgramID > 0, "program_id", "must be a positive number")
+	}
+	if input.SemesterID != nil {
+		v.Check(*input.SemesterID > 0, "semester_id", "must be a positive number")
+	}
+}
+
+// ValidateTeacherUpdate checks the values of a teacher update
+func ValidateTeacherUpdate(v *validator.Validator, input *TeacherUpdate) {
+	if input.Name != nil {
+		v.Check(strings.TrimSpace(*input.Name) != "", "name", "must not be empty")
+		v.Check(len(*input.Name) <= 500, "name", "must not be more than 500 bytes long")
+	}
+	if input.ContactNo != nil {
+		v.Check(len(*input.ContactNo) <= 20, "contact_no", "must not be more than 20 bytes long")
+	}
+	if input.Academics != nil {
+		for _, val := range input.Academics {
+			v.Check(strings.TrimSpace(val) != "", "academics", "must not contain empty values")
+		}
+		v.Check(validator.Unique(input.Academics), "academics", "must not contain duplicate values")
+	}
+}
+
+// UpdateStudent partially updates the details of a student.
+// ErrEditConflict is returned if the version does not match with the record.
+func (m UserModel) UpdateStudent(userID int64, input *StudentUpdate) error {
+
+	query1 := `SELECT name, symbol_no, pu_regd_no, COALESCE(contact_no, ''), program_id, semester_id, version
+	FROM students WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var name, puRegdNo, contactNo string
+	var symbolNo int64
+	var programID, semesterID, version int
+
+	err := m.DB.QueryRowContext(ctx, query1, userID).Scan(&name, &symbolNo, &puRegdNo, &contactNo,
+		&programID, &semesterID, &version)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	// The record was changed after the client read it
+	if version != *input.Version {
+		return ErrEditConflict
+	}
+
+	// Only update the provided fields
+	if input.Name != nil {
+		name = strings.TrimSpace(*input.Name)
+	}
+	if input.ContactNo != nil {
+		contactNo = *input.ContactNo
+	}
+	if input.SymbolNo != nil {
+		symbolNo = *input.SymbolNo
+	}
+	if input.PURegdNo != nil {
+		puRegdNo = *input.PURegdNo
+	}
+	if input.ProgramID != nil {
+		programID = *input.ProgramID
+	}
+	if input.SemesterID != nil {
+		semesterID = *input.SemesterID
+	}
+
+	// The version check in WHERE clause guards against a concurrent update
+	query2 := `UPDATE students SET name = $1, symbol_no = $2, pu_regd_no = $3, contact_no = $4,
+	program_id = $5, semester_id = $6, version = version + 1
+	WHERE user_id = $7 AND version = $8
+	RETURNING version`
+
+	args := []interface{}{name, symbolNo, puRegdNo, contactNo, programID, semesterID, userID, version}
+
+	err = m.DB.QueryRowContext(ctx, query2, args...).Scan(&version)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		case strings.Contains(err.Error(), "duplicate key value violates unique constraint"):
+			return ErrDuplicateEntry
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateTeacher partially updates the details of a teacher.
+// ErrEditConflict is returned if the version does not match with the record.
+func (m UserModel) UpdateTeacher(userID int64, input *TeacherUpdate) error {
+
+	query1 := `SELECT name, COALESCE(contact_no, ''), academics, version
+	FROM teachers WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var name, contactNo string
+	var academics []string
+	var version int
+
+	err := m.DB.QueryRowContext(ctx, query1, userID).Scan(&name, &contactNo, pq.Array(&academics), &version)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	// The record was changed after the client read it
+	if version != *input.Version {
+		return ErrEditConflict
+	}
+
+	// Only update the provided fields
+	if input.Name != nil {
+		name = strings.TrimSpace(*input.Name)
+	}
+	if input.ContactNo != nil {
+		contactNo = *input.ContactNo
+	}
+	if input.Academics != nil {
+		academics = input.Academics
+	}
+
+	// The version check in WHERE clause guards against a concurrent update
+	query2 := `UPDATE teachers SET name = $1, contact_no = $2, academics = $3, version = version + 1
+	WHERE user_id = $4 AND version = $5
+	RETURNING version`
+
+	err = m.DB.QueryRowContext(ctx, query2, name, contactNo, pq.Array(academics), userID, version).Scan(&version)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1 @@
+-- nothing to undo, the sequence keeps its value
@@ -0,0 +1,3 @@
+-- the main superuser was inserted with an explicit superuser_id,
+-- so move the sequence past it before new superusers are added
+SELECT setval('superusers_superuser_id_seq', COALESCE((SELECT MAX(superuser_id) FROM superusers), 0) + 1, false);
@@ -0,0 +1 @@
+ALTER TABLE running_semesters ADD CONSTRAINT running_semesters_semester_id_key UNIQUE (semester_id);
@@ -0,0 +1,3 @@
+-- a semester can be running for more than one program at a time,
+-- the (program_id, semester_id) primary key is enough
+ALTER TABLE running_semesters DROP CONSTRAINT IF EXISTS running_semesters_semester_id_key;
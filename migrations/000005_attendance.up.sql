@@ -0,0 +1,30 @@
+-- an attendance session is a class held on a date for a day_schedule slot
+CREATE TABLE IF NOT EXISTS attendance_sessions (
+	session_id bigserial NOT NULL PRIMARY KEY,
+
+	-- the day_schedule slot (program_id, semester_id, day, interval_id, course_id)
+	-- no foreign key to day_schedule, so that schedules can be replaced without losing attendance
+	program_id integer NOT NULL REFERENCES programs(program_id),
+	semester_id integer NOT NULL REFERENCES semesters(semester_id),
+	day varchar(10) NOT NULL REFERENCES days(day),
+	interval_id integer NOT NULL REFERENCES intervals(interval_id),
+	course_id bigint NOT NULL REFERENCES courses(course_id),
+
+	held_on date NOT NULL DEFAULT CURRENT_DATE,
+
+	-- who took the attendance
+	teacher_id bigint REFERENCES teachers(teacher_id) ON DELETE SET NULL,
+	created_at timestamp(0) with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
+
+	-- a slot can be held only once a day
+	CONSTRAINT attendance_sessions_slot_key UNIQUE (program_id, semester_id, day, interval_id, course_id, held_on)
+);
+
+-- attendance of students in a session
+CREATE TABLE IF NOT EXISTS attendance_records (
+	session_id bigint NOT NULL REFERENCES attendance_sessions(session_id) ON DELETE CASCADE,
+	student_id bigint NOT NULL REFERENCES students(student_id) ON DELETE CASCADE,
+	status text NOT NULL CHECK (status IN ('present', 'absent', 'late')),
+
+	CONSTRAINT attendance_records_pkey PRIMARY KEY (session_id, student_id)
+);
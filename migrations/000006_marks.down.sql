@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS marks_locks CASCADE;
+DROP TABLE IF EXISTS marks CASCADE;
@@ -0,0 +1,32 @@
+-- marks obtained by a student in a course
+CREATE TABLE IF NOT EXISTS marks (
+	student_id bigint NOT NULL REFERENCES students(student_id) ON DELETE CASCADE,
+	course_id bigint NOT NULL REFERENCES courses(course_id),
+
+	-- the offering (program, semester) in which the marks were obtained
+	program_id integer NOT NULL REFERENCES programs(program_id),
+	semester_id integer NOT NULL REFERENCES semesters(semester_id),
+
+	-- internal (out of 50) and final (out of 50) marks, null until uploaded
+	internal numeric(5,2) CHECK (internal BETWEEN 0 AND 50),
+	final numeric(5,2) CHECK (final BETWEEN 0 AND 50),
+
+	-- who uploaded the marks last
+	teacher_id bigint REFERENCES teachers(teacher_id) ON DELETE SET NULL,
+	updated_at timestamp(0) with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
+
+	CONSTRAINT marks_pkey PRIMARY KEY (student_id, course_id)
+);
+
+-- published marks of a course offering, teachers cannot change locked marks
+CREATE TABLE IF NOT EXISTS marks_locks (
+	program_id integer NOT NULL REFERENCES programs(program_id),
+	semester_id integer NOT NULL REFERENCES semesters(semester_id),
+	course_id bigint NOT NULL REFERENCES courses(course_id),
+
+	-- the superuser who locked the marks
+	locked_by bigint REFERENCES users(user_id) ON DELETE SET NULL,
+	locked_at timestamp(0) with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
+
+	CONSTRAINT marks_locks_pkey PRIMARY KEY (program_id, semester_id, course_id)
+);
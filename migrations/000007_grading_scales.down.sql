@@ -0,0 +1 @@
+DROP TABLE IF EXISTS grading_scales CASCADE;
@@ -0,0 +1,29 @@
+-- grading scale of a level, a percentage maps to the grade with the highest min_percent below it
+CREATE TABLE IF NOT EXISTS grading_scales (
+	level_id integer NOT NULL REFERENCES levels(level_id) ON DELETE CASCADE,
+	grade varchar(5) NOT NULL,
+	grade_point numeric(4,2) NOT NULL CHECK (grade_point >= 0),
+	min_percent numeric(5,2) NOT NULL CHECK (min_percent BETWEEN 0 AND 100),
+
+	CONSTRAINT grading_scales_pkey PRIMARY KEY (level_id, grade),
+	CONSTRAINT grading_scales_min_percent_key UNIQUE (level_id, min_percent)
+);
+
+-- default scales
+INSERT INTO grading_scales (level_id, grade, grade_point, min_percent)
+SELECT levels.level_id, scale.grade, scale.grade_point, scale.min_percent
+FROM levels, (VALUES
+	('A', 4.0, 90), ('A-', 3.7, 80), ('B+', 3.3, 70), ('B', 3.0, 60),
+	('B-', 2.7, 50), ('C+', 2.3, 45), ('F', 0, 0)
+) AS scale (grade, grade_point, min_percent)
+WHERE levels.name = 'Bachelors'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO grading_scales (level_id, grade, grade_point, min_percent)
+SELECT levels.level_id, scale.grade, scale.grade_point, scale.min_percent
+FROM levels, (VALUES
+	('A', 4.0, 90), ('A-', 3.7, 80), ('B+', 3.3, 70), ('B', 3.0, 60),
+	('B-', 2.7, 50), ('F', 0, 0)
+) AS scale (grade, grade_point, min_percent)
+WHERE levels.name = 'Masters'
+ON CONFLICT DO NOTHING;
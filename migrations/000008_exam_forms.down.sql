@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS exam_form_courses CASCADE;
+DROP TABLE IF EXISTS exam_forms CASCADE;
@@ -0,0 +1,30 @@
+-- examination form of a student for a semester
+CREATE TABLE IF NOT EXISTS exam_forms (
+	form_id bigserial NOT NULL PRIMARY KEY,
+	student_id bigint NOT NULL REFERENCES students(student_id) ON DELETE CASCADE,
+
+	-- the program and semester of the student while submitting the form
+	program_id integer NOT NULL REFERENCES programs(program_id),
+	semester_id integer NOT NULL REFERENCES semesters(semester_id),
+
+	-- pending -> verified or rejected, a rejected form can be submitted again
+	status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'verified', 'rejected')),
+	remarks text,
+
+	-- the superuser who verified or rejected the form
+	verified_by bigint REFERENCES users(user_id) ON DELETE SET NULL,
+	verified_at timestamp(0) with time zone,
+	submitted_at timestamp(0) with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
+
+	-- one form per semester
+	CONSTRAINT exam_forms_student_semester_key UNIQUE (student_id, program_id, semester_id)
+);
+
+-- courses a student sits for in an exam form
+CREATE TABLE IF NOT EXISTS exam_form_courses (
+	form_id bigint NOT NULL REFERENCES exam_forms(form_id) ON DELETE CASCADE,
+	course_id bigint NOT NULL REFERENCES courses(course_id),
+	type text NOT NULL CHECK (type IN ('regular', 'elective', 'back')),
+
+	CONSTRAINT exam_form_courses_pkey PRIMARY KEY (form_id, course_id)
+);
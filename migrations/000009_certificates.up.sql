@@ -0,0 +1,24 @@
+-- certificates issued to students
+-- student details are copied so that a certificate can be verified even if the student changes or is deleted
+CREATE TABLE IF NOT EXISTS certificates (
+	certificate_id bigserial NOT NULL PRIMARY KEY,
+	serial text NOT NULL UNIQUE,
+	type text NOT NULL CHECK (type IN ('character', 'completion', 'enrollment')),
+
+	student_id bigint REFERENCES students(student_id) ON DELETE SET NULL,
+	name text NOT NULL,
+	symbol_no bigint NOT NULL,
+	pu_regd_no text NOT NULL,
+	program text NOT NULL,
+	level text NOT NULL,
+
+	-- the rendered content of the certificate
+	body text NOT NULL,
+
+	issued_by bigint REFERENCES users(user_id) ON DELETE SET NULL,
+	issued_at timestamp(0) with time zone NOT NULL,
+
+	-- HMAC of the details above, a mismatch means the record was tampered with
+	signature text NOT NULL,
+	revoked boolean NOT NULL DEFAULT false
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS lesson_plan_references CASCADE;
+DROP TABLE IF EXISTS lesson_plans CASCADE;
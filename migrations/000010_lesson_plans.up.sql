@@ -0,0 +1,28 @@
+-- week by week lesson plan of a course
+CREATE TABLE IF NOT EXISTS lesson_plans (
+	plan_id bigserial NOT NULL PRIMARY KEY,
+	course_id bigint NOT NULL REFERENCES courses(course_id) ON DELETE CASCADE,
+	week integer NOT NULL CHECK (week BETWEEN 1 AND 30),
+	topics text NOT NULL,
+	objectives text NOT NULL DEFAULT '',
+
+	-- completion status of the week
+	completed boolean NOT NULL DEFAULT false,
+	completed_at timestamp(0) with time zone,
+
+	-- the teacher who last changed the plan
+	teacher_id bigint REFERENCES teachers(teacher_id) ON DELETE SET NULL,
+	updated_at timestamp(0) with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
+
+	CONSTRAINT lesson_plans_course_week_key UNIQUE (course_id, week)
+);
+
+-- books (from course_books) referred in a week of a lesson plan
+CREATE TABLE IF NOT EXISTS lesson_plan_references (
+	plan_id bigint NOT NULL REFERENCES lesson_plans(plan_id) ON DELETE CASCADE,
+	book_id bigint NOT NULL REFERENCES books(book_id) ON DELETE CASCADE,
+	-- chapters or pages to refer
+	pages text NOT NULL DEFAULT '',
+
+	CONSTRAINT lesson_plan_references_pkey PRIMARY KEY (plan_id, book_id)
+);
@@ -0,0 +1,30 @@
+-- physical copies of books in the library
+CREATE TABLE IF NOT EXISTS book_copies (
+	copy_id bigserial NOT NULL PRIMARY KEY,
+	book_id bigint NOT NULL REFERENCES books(book_id) ON DELETE CASCADE,
+	-- the number written on the copy by the library
+	accession_no text NOT NULL UNIQUE,
+	added_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+	-- withdrawn copies (lost, damaged..) are kept for the loan history
+	withdrawn boolean NOT NULL DEFAULT false
+);
+
+-- copies issued to students and teachers
+CREATE TABLE IF NOT EXISTS book_loans (
+	loan_id bigserial NOT NULL PRIMARY KEY,
+	copy_id bigint NOT NULL REFERENCES book_copies(copy_id) ON DELETE CASCADE,
+	user_id bigint NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	issued_by bigint REFERENCES users(user_id) ON DELETE SET NULL,
+	issued_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+	due_date date NOT NULL,
+	returned_at timestamp(0) with time zone,
+	-- fine charged on return of an overdue copy
+	fine numeric(10, 2) NOT NULL DEFAULT 0 CHECK (fine >= 0),
+	fine_paid boolean NOT NULL DEFAULT false,
+	-- date of the last overdue reminder mail
+	reminded_on date
+);
+
+-- a copy can only be issued once at a time
+CREATE UNIQUE INDEX IF NOT EXISTS book_loans_open_copy_key ON book_loans (copy_id) WHERE returned_at IS NULL;
+CREATE INDEX IF NOT EXISTS book_loans_user_id_idx ON book_loans (user_id);
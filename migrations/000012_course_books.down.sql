@@ -0,0 +1,6 @@
+ALTER TABLE course_books DROP CONSTRAINT IF EXISTS course_books_book_id_fkey;
+ALTER TABLE course_books ADD CONSTRAINT course_books_book_id_fkey
+	FOREIGN KEY (book_id) REFERENCES books(book_id);
+
+-- fails if a course has more than one book
+ALTER TABLE course_books ADD CONSTRAINT course_books_course_id_key UNIQUE (course_id);
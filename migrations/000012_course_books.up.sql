@@ -0,0 +1,7 @@
+-- a course can have many text and reference books
+ALTER TABLE course_books DROP CONSTRAINT IF EXISTS course_books_course_id_key;
+
+-- deleting a book removes it from the courses
+ALTER TABLE course_books DROP CONSTRAINT IF EXISTS course_books_book_id_fkey;
+ALTER TABLE course_books ADD CONSTRAINT course_books_book_id_fkey
+	FOREIGN KEY (book_id) REFERENCES books(book_id) ON DELETE CASCADE;
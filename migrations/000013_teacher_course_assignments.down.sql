@@ -0,0 +1,2 @@
+ALTER TABLE teacher_courses DROP COLUMN IF EXISTS expiry_warned;
+ALTER TABLE teacher_courses DROP COLUMN IF EXISTS assigned_at;
@@ -0,0 +1,5 @@
+-- date of the assignment of a teacher to a course
+ALTER TABLE teacher_courses ADD COLUMN IF NOT EXISTS assigned_at date NOT NULL DEFAULT CURRENT_DATE;
+
+-- whether superusers have been warned of the coming expiry, reset on renewal
+ALTER TABLE teacher_courses ADD COLUMN IF NOT EXISTS expiry_warned boolean NOT NULL DEFAULT false;
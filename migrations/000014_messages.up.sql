@@ -0,0 +1,29 @@
+-- a conversation thread between two users
+CREATE TABLE IF NOT EXISTS conversations (
+	conversation_id bigserial NOT NULL PRIMARY KEY,
+	-- the participants, stored in ascending order so that a pair has a single thread
+	user_a bigint NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	user_b bigint NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+	-- time of the latest message
+	updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+	CONSTRAINT conversations_users_check CHECK (user_a < user_b),
+	CONSTRAINT conversations_users_key UNIQUE (user_a, user_b)
+);
+
+CREATE INDEX IF NOT EXISTS conversations_user_b_idx ON conversations (user_b);
+
+-- messages of a conversation
+CREATE TABLE IF NOT EXISTS messages (
+	message_id bigserial NOT NULL PRIMARY KEY,
+	conversation_id bigint NOT NULL REFERENCES conversations(conversation_id) ON DELETE CASCADE,
+	sender_id bigint NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+	body text NOT NULL,
+	sent_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+	-- read receipt, set when the recipient reads the message
+	read_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS messages_conversation_id_idx ON messages (conversation_id, message_id);
+CREATE INDEX IF NOT EXISTS messages_unread_idx ON messages (conversation_id) WHERE read_at IS NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS applicants CASCADE;
+DROP TABLE IF EXISTS enquiries CASCADE;
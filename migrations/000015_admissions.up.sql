@@ -0,0 +1,36 @@
+-- enquiries about programs from the public
+CREATE TABLE IF NOT EXISTS enquiries (
+	enquiry_id bigserial NOT NULL PRIMARY KEY,
+	program_id integer NOT NULL REFERENCES programs(program_id) ON DELETE CASCADE,
+	name text NOT NULL,
+	email text NOT NULL,
+	contact_no text NOT NULL,
+	message text NOT NULL,
+	submitted_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+-- applications for admission into programs
+CREATE TABLE IF NOT EXISTS applicants (
+	applicant_id bigserial NOT NULL PRIMARY KEY,
+	program_id integer NOT NULL REFERENCES programs(program_id),
+	name text NOT NULL,
+	email text NOT NULL,
+	contact_no text NOT NULL,
+	-- previous academic qualification, such as the +2 stream and grade
+	qualification text NOT NULL,
+	message text NOT NULL DEFAULT '',
+
+	-- admission pipeline, admitted is set only by the admit action
+	status text NOT NULL DEFAULT 'received' CHECK (status IN ('received', 'shortlisted', 'admitted', 'rejected')),
+	-- notes of superusers
+	remarks text NOT NULL DEFAULT '',
+	-- the student account of an admitted applicant
+	user_id bigint REFERENCES users(user_id) ON DELETE SET NULL,
+
+	submitted_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+	updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+-- an email can have only one open application for a program
+CREATE UNIQUE INDEX IF NOT EXISTS applicants_open_email_key ON applicants (lower(email), program_id) WHERE status <> 'rejected';
+CREATE INDEX IF NOT EXISTS applicants_status_idx ON applicants (status);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS issue_replies CASCADE;
+
+ALTER TABLE issues DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE issues DROP COLUMN IF EXISTS priority;
+ALTER TABLE issues DROP COLUMN IF EXISTS category;
+ALTER TABLE issues DROP COLUMN IF EXISTS status;
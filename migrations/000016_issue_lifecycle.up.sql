@@ -0,0 +1,24 @@
+-- lifecycle of an issue
+ALTER TABLE issues ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'open'
+	CHECK (status IN ('open', 'in-progress', 'resolved', 'rejected'));
+
+-- category chosen by the reporter, can be corrected by superusers
+ALTER TABLE issues ADD COLUMN IF NOT EXISTS category text NOT NULL DEFAULT 'general'
+	CHECK (category IN ('general', 'academic', 'examination', 'fees', 'library', 'facilities', 'harassment'));
+
+-- priority set by superusers
+ALTER TABLE issues ADD COLUMN IF NOT EXISTS priority text NOT NULL DEFAULT 'normal'
+	CHECK (priority IN ('low', 'normal', 'high', 'urgent'));
+
+ALTER TABLE issues ADD COLUMN IF NOT EXISTS updated_at timestamp(0) with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP(0);
+
+-- replies of superusers to issues, visible to the reporter
+CREATE TABLE IF NOT EXISTS issue_replies (
+	reply_id bigserial NOT NULL PRIMARY KEY,
+	issue_id bigint NOT NULL REFERENCES issues(issue_id) ON DELETE CASCADE,
+	user_id bigint REFERENCES users(user_id) ON DELETE SET NULL,
+	reply text NOT NULL,
+	created_at timestamp(0) with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP(0)
+);
+
+CREATE INDEX IF NOT EXISTS issue_replies_issue_id_idx ON issue_replies (issue_id);
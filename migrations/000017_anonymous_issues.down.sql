@@ -0,0 +1,8 @@
+-- anonymous issues can not be linked to a user
+DELETE FROM issues WHERE anonymous;
+
+ALTER TABLE issues DROP CONSTRAINT IF EXISTS issues_reporter_check;
+ALTER TABLE issues DROP COLUMN IF EXISTS tracking_hash;
+ALTER TABLE issues DROP COLUMN IF EXISTS anonymous;
+
+ALTER TABLE issues ALTER COLUMN user_id SET NOT NULL;
@@ -0,0 +1,12 @@
+-- anonymous issues are stored without the user, the reporter follows them with a tracking code
+ALTER TABLE issues ALTER COLUMN user_id DROP NOT NULL;
+
+ALTER TABLE issues ADD COLUMN IF NOT EXISTS anonymous boolean NOT NULL DEFAULT false;
+
+-- sha-256 hash of the tracking code, the code itself is only shown to the reporter
+ALTER TABLE issues ADD COLUMN IF NOT EXISTS tracking_hash text UNIQUE;
+
+ALTER TABLE issues ADD CONSTRAINT issues_reporter_check CHECK (
+	(anonymous AND user_id IS NULL AND tracking_hash IS NOT NULL)
+	OR (NOT anonymous AND user_id IS NOT NULL)
+);
@@ -0,0 +1,14 @@
+-- files attached to issues as evidence, served only to the reporter and superusers
+CREATE TABLE IF NOT EXISTS issue_attachments (
+	attachment_id bigserial NOT NULL PRIMARY KEY,
+	issue_id bigint NOT NULL REFERENCES issues(issue_id) ON DELETE CASCADE,
+	-- name of the file as uploaded
+	file_name text NOT NULL,
+	-- path of the file inside the issues uploads folder
+	path text NOT NULL UNIQUE,
+	content_type text NOT NULL,
+	size bigint NOT NULL CHECK (size > 0),
+	created_at timestamp(0) with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP(0)
+);
+
+CREATE INDEX IF NOT EXISTS issue_attachments_issue_id_idx ON issue_attachments (issue_id);
@@ -0,0 +1,6 @@
+-- random key of the calendar subscription url of a user, regenerating it revokes the old url
+CREATE TABLE IF NOT EXISTS calendar_feeds (
+	user_id bigint NOT NULL PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+	feed_key text NOT NULL,
+	created_at timestamp(0) with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP(0)
+);
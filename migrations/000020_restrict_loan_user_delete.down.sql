@@ -0,0 +1,3 @@
+ALTER TABLE book_loans DROP CONSTRAINT IF EXISTS book_loans_user_id_fkey;
+ALTER TABLE book_loans ADD CONSTRAINT book_loans_user_id_fkey
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE;
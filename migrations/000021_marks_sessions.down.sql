@@ -0,0 +1,14 @@
+-- only the latest attempt of a course is kept
+DELETE FROM marks WHERE EXISTS (SELECT 1 FROM marks AS later
+	WHERE later.student_id = marks.student_id AND later.course_id = marks.course_id
+	AND (later.session, later.semester_id) > (marks.session, marks.semester_id));
+ALTER TABLE marks DROP CONSTRAINT IF EXISTS marks_pkey;
+ALTER TABLE marks DROP COLUMN IF EXISTS session;
+ALTER TABLE marks ADD CONSTRAINT marks_pkey PRIMARY KEY (student_id, course_id);
+
+DELETE FROM marks_locks WHERE EXISTS (SELECT 1 FROM marks_locks AS later
+	WHERE later.program_id = marks_locks.program_id AND later.semester_id = marks_locks.semester_id
+	AND later.course_id = marks_locks.course_id AND later.session > marks_locks.session);
+ALTER TABLE marks_locks DROP CONSTRAINT IF EXISTS marks_locks_pkey;
+ALTER TABLE marks_locks DROP COLUMN IF EXISTS session;
+ALTER TABLE marks_locks ADD CONSTRAINT marks_locks_pkey PRIMARY KEY (program_id, semester_id, course_id);
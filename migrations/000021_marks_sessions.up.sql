@@ -0,0 +1,17 @@
+-- marks and their locks belong to the academic session (year) of the offering,
+-- so that a student re-taking a course keeps the marks of the earlier attempt
+ALTER TABLE marks_locks ADD COLUMN IF NOT EXISTS session integer;
+UPDATE marks_locks SET session = EXTRACT(YEAR FROM locked_at);
+ALTER TABLE marks_locks ALTER COLUMN session SET NOT NULL;
+ALTER TABLE marks_locks DROP CONSTRAINT IF EXISTS marks_locks_pkey;
+ALTER TABLE marks_locks ADD CONSTRAINT marks_locks_pkey PRIMARY KEY (program_id, semester_id, course_id, session);
+
+-- existing marks take the session of their lock, or the year they were last updated in
+ALTER TABLE marks ADD COLUMN IF NOT EXISTS session integer;
+UPDATE marks SET session = marks_locks.session FROM marks_locks
+WHERE marks_locks.program_id = marks.program_id AND marks_locks.semester_id = marks.semester_id
+AND marks_locks.course_id = marks.course_id;
+UPDATE marks SET session = EXTRACT(YEAR FROM updated_at) WHERE session IS NULL;
+ALTER TABLE marks ALTER COLUMN session SET NOT NULL;
+ALTER TABLE marks DROP CONSTRAINT IF EXISTS marks_pkey;
+ALTER TABLE marks ADD CONSTRAINT marks_pkey PRIMARY KEY (student_id, program_id, semester_id, course_id, session);
@@ -0,0 +1,6 @@
+-- random key signed into the QR code of the id card of a student, reissuing the card revokes the old one
+CREATE TABLE IF NOT EXISTS id_cards (
+	user_id bigint NOT NULL PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+	card_key text NOT NULL,
+	issued_at timestamp(0) with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP(0)
+);